@@ -5,6 +5,8 @@ import (
 	"stock-api/internal/api/handlers"
 	"stock-api/internal/api/middleware"
 	"stock-api/internal/database"
+	"stock-api/internal/inventory"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -18,6 +20,9 @@ func main() {
 		log.Fatal(err)
 	}
 
+	// Süresi dolan rezervasyonları düzenli olarak serbest bırak
+	go releaseExpiredReservations(db, time.Minute)
+
 	log.Println("Router ayarlanıyor...")
 	// Özel router yapılandırması
 	r := gin.New()
@@ -38,23 +43,167 @@ func main() {
 	}
 }
 
+// releaseExpiredReservations süresi dolan rezervasyonları her interval'de bir işaretler.
+// Stok hesapları süresi dolmuş rezervasyonları zaten saymaz; bu yalnızca durumlarını günceller.
+func releaseExpiredReservations(db *gorm.DB, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := range ticker.C {
+		released, err := inventory.ExpireReservations(db, now)
+		if err != nil {
+			log.Printf("Rezervasyon süresi kontrol hatası: %v", err)
+			continue
+		}
+		if released > 0 {
+			log.Printf("%d rezervasyonun süresi doldu", released)
+		}
+	}
+}
+
 func setupRoutes(v1 *gin.RouterGroup, db *gorm.DB) {
 	// Handlers'ları oluştur
 	productHandler := handlers.NewProductHandler(db)
 	saleHandler := handlers.NewSaleHandler(db)
 	recipeHandler := handlers.NewRecipeHandler(db)
+	purchaseLotHandler := handlers.NewPurchaseLotHandler(db)
+	supplierHandler := handlers.NewSupplierHandler(db)
+	customerHandler := handlers.NewCustomerHandler(db)
+	purchaseInvoiceHandler := handlers.NewPurchaseInvoiceHandler(db)
+	unitHandler := handlers.NewUnitHandler(db)
+	categoryHandler := handlers.NewCategoryHandler(db)
+	barcodeHandler := handlers.NewBarcodeHandler(db)
+	reportHandler := handlers.NewReportHandler(db)
+	stockMovementHandler := handlers.NewStockMovementHandler(db)
+	alertHandler := handlers.NewAlertHandler(db)
+	stockAdjustmentHandler := handlers.NewStockAdjustmentHandler(db)
+	countSessionHandler := handlers.NewCountSessionHandler(db)
+	locationHandler := handlers.NewLocationHandler(db)
+	stockTransferHandler := handlers.NewStockTransferHandler(db)
+	reservationHandler := handlers.NewReservationHandler(db)
+	salesOrderHandler := handlers.NewSalesOrderHandler(db)
+	saleReturnHandler := handlers.NewSaleReturnHandler(db)
 
 	// Products endpoints
 	v1.POST("/products", productHandler.CreateProduct)
 	v1.GET("/products", productHandler.GetProducts)
 	v1.GET("/products/average-price", productHandler.GetAveragePrice)
+	v1.GET("/products/lookup", barcodeHandler.LookupProduct)
 	v1.GET("/products/:id", productHandler.GetProduct)
+	v1.PUT("/products/:id", productHandler.UpdateProduct)
 	v1.DELETE("/products/:id", productHandler.DeleteProduct)
+	v1.POST("/products/:id/restore", productHandler.RestoreProduct)
+
+	// Barcode endpoints
+	v1.POST("/products/:id/barcodes", barcodeHandler.AddBarcode)
+	v1.DELETE("/products/:id/barcodes/:barcodeId", barcodeHandler.DeleteBarcode)
+
+	// Purchase lot endpoints
+	v1.POST("/products/:id/lots", purchaseLotHandler.CreateLot)
+	v1.GET("/products/:id/lots", purchaseLotHandler.GetLots)
+	v1.GET("/lots/:id", purchaseLotHandler.GetLot)
+	v1.PATCH("/lots/:id", purchaseLotHandler.UpdateLot)
+	v1.DELETE("/lots/:id", purchaseLotHandler.DeleteLot)
+	v1.POST("/lots/:id/restore", purchaseLotHandler.RestoreLot)
+
+	// Supplier endpoints
+	v1.POST("/suppliers", supplierHandler.CreateSupplier)
+	v1.GET("/suppliers", supplierHandler.GetSuppliers)
+	v1.GET("/suppliers/:id", supplierHandler.GetSupplier)
+	v1.PUT("/suppliers/:id", supplierHandler.UpdateSupplier)
+	v1.DELETE("/suppliers/:id", supplierHandler.DeleteSupplier)
+	v1.GET("/suppliers/:id/purchases", supplierHandler.GetSupplierPurchases)
+
+	// Customer endpoints
+	v1.POST("/customers", customerHandler.CreateCustomer)
+	v1.GET("/customers", customerHandler.GetCustomers)
+	v1.GET("/customers/:id", customerHandler.GetCustomer)
+	v1.PUT("/customers/:id", customerHandler.UpdateCustomer)
+	v1.DELETE("/customers/:id", customerHandler.DeleteCustomer)
+	v1.GET("/customers/:id/sales", customerHandler.GetCustomerSales)
+
+	// Purchase invoice endpoints
+	v1.POST("/purchase-invoices", purchaseInvoiceHandler.CreatePurchaseInvoice)
+	v1.GET("/purchase-invoices", purchaseInvoiceHandler.GetPurchaseInvoices)
+	v1.GET("/purchase-invoices/:id", purchaseInvoiceHandler.GetPurchaseInvoice)
+
+	// Unit endpoints
+	v1.GET("/units", unitHandler.GetUnits)
+	v1.POST("/units", unitHandler.CreateUnit)
+	v1.DELETE("/units/:id", unitHandler.DeleteUnit)
+	v1.GET("/products/:id/units", unitHandler.GetProductUnits)
+	v1.POST("/products/:id/units", unitHandler.CreateProductUnit)
+	v1.DELETE("/products/:id/units/:unitId", unitHandler.DeleteProductUnit)
+
+	// Category endpoints
+	v1.POST("/categories", categoryHandler.CreateCategory)
+	v1.GET("/categories", categoryHandler.GetCategories)
+	v1.GET("/categories/:id", categoryHandler.GetCategory)
+	v1.PUT("/categories/:id", categoryHandler.UpdateCategory)
+	v1.POST("/categories/:id/move", categoryHandler.MoveCategory)
+	v1.DELETE("/categories/:id", categoryHandler.DeleteCategory)
+
+	// Report endpoints
+	v1.GET("/reports/categories", reportHandler.GetCategoryReport)
+	v1.GET("/inventory/as-of", reportHandler.GetInventoryAsOf)
+	v1.GET("/inventory/journal", reportHandler.GetInventoryJournal)
+
+	// Stock endpoints
+	v1.GET("/stock/expiring", stockMovementHandler.GetExpiringStock)
+
+	// Stock adjustment endpoints
+	v1.POST("/stock-adjustments", stockAdjustmentHandler.CreateAdjustment)
+	v1.GET("/stock-adjustments", stockAdjustmentHandler.GetAdjustments)
+	v1.GET("/stock-adjustments/:id", stockAdjustmentHandler.GetAdjustment)
+
+	// Location endpoints
+	v1.GET("/locations", locationHandler.GetLocations)
+	v1.POST("/locations", locationHandler.CreateLocation)
+	v1.PUT("/locations/:id", locationHandler.UpdateLocation)
+	v1.DELETE("/locations/:id", locationHandler.DeleteLocation)
+	v1.GET("/products/:id/location-levels", locationHandler.GetProductLevels)
+	v1.PUT("/products/:id/location-levels", locationHandler.SetProductLevel)
+
+	// Stock transfer endpoints
+	v1.POST("/stock-transfers", stockTransferHandler.CreateTransfer)
+	v1.GET("/stock-transfers", stockTransferHandler.GetTransfers)
+	v1.GET("/stock-transfers/in-transit", stockTransferHandler.GetInTransit)
+	v1.GET("/stock-transfers/:id", stockTransferHandler.GetTransfer)
+	v1.POST("/stock-transfers/:id/ship", stockTransferHandler.ShipTransfer)
+	v1.POST("/stock-transfers/:id/receive", stockTransferHandler.ReceiveTransfer)
+	v1.POST("/stock-transfers/:id/cancel", stockTransferHandler.CancelTransfer)
+
+	// Reservation endpoints
+	v1.POST("/reservations", reservationHandler.CreateReservation)
+	v1.GET("/reservations", reservationHandler.GetReservations)
+	v1.GET("/reservations/:id", reservationHandler.GetReservation)
+	v1.POST("/reservations/:id/release", reservationHandler.ReleaseReservation)
+
+	// Sales order endpoints
+	v1.POST("/sales-orders", salesOrderHandler.CreateSalesOrder)
+	v1.GET("/sales-orders", salesOrderHandler.GetSalesOrders)
+	v1.GET("/sales-orders/:id", salesOrderHandler.GetSalesOrder)
+	v1.DELETE("/sales-orders/:id", salesOrderHandler.DeleteSalesOrder)
+
+	// Count session endpoints
+	v1.POST("/count-sessions", countSessionHandler.OpenSession)
+	v1.GET("/count-sessions", countSessionHandler.GetSessions)
+	v1.GET("/count-sessions/:id", countSessionHandler.GetSession)
+	v1.POST("/count-sessions/:id/counts", countSessionHandler.SubmitCounts)
+	v1.POST("/count-sessions/:id/approve", countSessionHandler.ApproveSession)
+	v1.POST("/count-sessions/:id/cancel", countSessionHandler.CancelSession)
+
+	// Alert endpoints
+	v1.GET("/alerts/low-stock", alertHandler.GetLowStock)
 
 	// Sales endpoints
 	v1.POST("/sales", saleHandler.CreateSale)
 	v1.GET("/sales", saleHandler.GetSales)
+	v1.PATCH("/sales/:id", saleHandler.UpdateSale)
 	v1.DELETE("/sales/:id", saleHandler.DeleteSale)
+	v1.GET("/sales/:id/revisions", saleHandler.GetSaleRevisions)
+	v1.POST("/sales/:id/returns", saleReturnHandler.CreateSaleReturn)
+	v1.GET("/sales/:id/returns", saleReturnHandler.GetSaleReturns)
 
 	// Recipe Sales endpoint
 	v1.POST("/sales/recipe", saleHandler.CreateRecipeSale)
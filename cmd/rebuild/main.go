@@ -0,0 +1,30 @@
+package main
+
+import (
+	"log"
+	"stock-api/internal/database"
+	"stock-api/internal/inventory"
+
+	"gorm.io/gorm"
+)
+
+// Envanter defterinden parti kalanlarını ve ürün stoklarını yeniden hesaplar.
+// Defterle uyuşmayan satırlar düzeltilir ve sayıları yazdırılır.
+func main() {
+	log.Println("Stok önbellekleri defterden yeniden hesaplanıyor...")
+	db, err := database.InitDB()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var result inventory.RebuildResult
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result, err = inventory.Rebuild(tx)
+		return err
+	})
+	if err != nil {
+		log.Fatalf("Yeniden hesaplama hatası: %v", err)
+	}
+
+	log.Printf("%d stok hareketi ve %d ürün stoğu düzeltildi", result.Movements, result.Products)
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"stock-api/internal/inventory"
+	"stock-api/internal/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type AlertHandler struct {
+	db *gorm.DB
+}
+
+func NewAlertHandler(db *gorm.DB) *AlertHandler {
+	return &AlertHandler{db: db}
+}
+
+// lowStockAlert bir ürünün stok seviyesi ve beklenen tüketimidir
+type lowStockAlert struct {
+	ProductID       uint     `json:"productId"`
+	ProductName     string   `json:"productName"`
+	Unit            string   `json:"unit"`
+	SupplierID      *uint    `json:"supplierId,omitempty"`
+	MinStock        float64  `json:"minStock"`
+	ReorderQuantity float64  `json:"reorderQuantity"`
+	TargetStock     float64  `json:"targetStock"`
+	ReservedStock   float64  `json:"reservedStock"`
+	AvailableStock  float64  `json:"availableStock"`
+	DirectDemand    float64  `json:"directDemand"`
+	RecipeDemand    float64  `json:"recipeDemand"`
+	ProjectedStock  float64  `json:"projectedStock"`
+	OutOfStock      bool     `json:"outOfStock"`
+	SuggestedOrder  float64  `json:"suggestedOrder"`
+	Recipes         []string `json:"recipes,omitempty"`
+}
+
+// GetLowStock - kullanılabilir stoğu son days günün tüketimi kadar düşüldüğünde minimum
+// seviyenin altına inen ürünleri listeler. Tüketime reçete satışlarındaki malzeme
+// kullanımları da dahildir; rezervasyonlarla ayrılan stok kullanılabilir sayılmaz.
+// Seviyesi tanımlı olmayan ürün, tüketimi stoğu aşarsa uyarı verir.
+// locationId verilirse stok ve tüketim o konumdan, seviyeler konumun kendi seviyelerinden
+// (tanımlı değilse ürünün genel seviyelerinden) alınır.
+func (h *AlertHandler) GetLowStock(c *gin.Context) {
+	days := 7
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz gün sayısı"})
+			return
+		}
+		days = parsed
+	}
+
+	locationID, ok := queryLocationID(c)
+	if !ok {
+		return
+	}
+
+	var products []models.Product
+	query := h.db.Order("product_name asc")
+	if categoryID := c.Query("categoryId"); categoryID != "" {
+		subtree, err := categorySubtree(h.db, categoryID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kategori bulunamadı"})
+			return
+		}
+		query = query.Where("category_id IN (?)", subtree)
+	}
+	if err := query.Find(&products).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürünler listelenemedi"})
+		return
+	}
+
+	// Kullanılabilir stok: arşivlenmemiş ve süresi geçmemiş partilerin kalanı
+	today := inventory.ExpiryDay(time.Now())
+	var stockRows []struct {
+		ProductID uint
+		Available float64
+	}
+	if err := inventory.AtLocation(h.db.Model(&models.StockMovement{}), locationID).
+		Select("product_id, SUM(remaining_quantity) AS available").
+		Where("remaining_quantity > 0 AND (expiry_date IS NULL OR expiry_date >= ?)", today).
+		Group("product_id").
+		Scan(&stockRows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok miktarları alınamadı"})
+		return
+	}
+	available := make(map[uint]float64, len(stockRows))
+	for _, row := range stockRows {
+		available[row.ProductID] = row.Available
+	}
+
+	// Rezervasyonlarla ayrılan stok kullanılabilir sayılmaz
+	reserved, err := inventory.ReservedByProduct(h.db, locationID, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rezerve stok alınamadı"})
+		return
+	}
+	for productID, quantity := range reserved {
+		available[productID] -= quantity
+	}
+
+	// Tüketim: son days gündeki satışların ürün birimindeki stok kullanımları (stoğa geri
+	// alınan iadeler hariç)
+	since := today.AddDate(0, 0, -days)
+	var demandRows []struct {
+		ProductID    uint
+		DirectDemand float64
+		RecipeDemand float64
+	}
+	demandQuery := h.db.Table("stock_usages")
+	if locationID != 0 {
+		demandQuery = demandQuery.Where("stock_movements.location_id = ?", locationID)
+	}
+	if err := demandQuery.
+		Select("stock_movements.product_id, "+
+			"SUM(CASE WHEN sales.recipe_id IS NULL THEN stock_usages.used_quantity - "+restockedQuantity+" ELSE 0 END) AS direct_demand, "+
+			"SUM(CASE WHEN sales.recipe_id IS NOT NULL THEN stock_usages.used_quantity - "+restockedQuantity+" ELSE 0 END) AS recipe_demand").
+		Joins("JOIN sales ON sales.id = stock_usages.sale_id").
+		Joins("JOIN stock_movements ON stock_movements.id = stock_usages.stock_movement_id").
+		Where("sales.sale_date >= ?", since).
+		Group("stock_movements.product_id").
+		Scan(&demandRows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tüketim miktarları alınamadı"})
+		return
+	}
+	directDemand := make(map[uint]float64, len(demandRows))
+	recipeDemand := make(map[uint]float64, len(demandRows))
+	for _, row := range demandRows {
+		directDemand[row.ProductID] = row.DirectDemand
+		recipeDemand[row.ProductID] = row.RecipeDemand
+	}
+
+	// Ürünü malzeme olarak kullanan reçeteler
+	var recipeRows []struct {
+		ProductID uint
+		Name      string
+	}
+	if err := h.db.Table("recipe_items").
+		Select("DISTINCT recipe_items.product_id, recipes.name").
+		Joins("JOIN recipes ON recipes.id = recipe_items.recipe_id").
+		Order("recipes.name asc").
+		Scan(&recipeRows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Reçeteler alınamadı"})
+		return
+	}
+	recipes := make(map[uint][]string)
+	for _, row := range recipeRows {
+		recipes[row.ProductID] = append(recipes[row.ProductID], row.Name)
+	}
+
+	// Konumun kendi seviyeleri ürünün genel seviyelerinin yerine geçer
+	if locationID != 0 {
+		var levels []models.ProductLocationLevel
+		if err := h.db.Where("location_id = ?", locationID).Find(&levels).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok seviyeleri alınamadı"})
+			return
+		}
+		byProduct := make(map[uint]models.ProductLocationLevel, len(levels))
+		for _, level := range levels {
+			byProduct[level.ProductID] = level
+		}
+		for i := range products {
+			if level, ok := byProduct[products[i].ID]; ok {
+				products[i].MinStock = level.MinStock
+				products[i].ReorderQuantity = level.ReorderQuantity
+				products[i].TargetStock = level.TargetStock
+			}
+		}
+	}
+
+	alerts := make([]lowStockAlert, 0)
+	for _, product := range products {
+		demand := directDemand[product.ID] + recipeDemand[product.ID]
+		if product.MinStock <= 0 && demand <= 0 {
+			continue
+		}
+
+		projected := available[product.ID] - demand
+		if projected > product.MinStock {
+			continue
+		}
+
+		// Hedef tanımlıysa hedefe tamamlanır, yoksa en az sipariş miktarı kadar sipariş verilir
+		suggested := math.Max(product.ReorderQuantity, product.MinStock-projected)
+		if product.TargetStock > 0 {
+			suggested = product.TargetStock - projected
+		}
+
+		alerts = append(alerts, lowStockAlert{
+			ProductID:       product.ID,
+			ProductName:     product.ProductName,
+			Unit:            product.Unit,
+			SupplierID:      product.SupplierID,
+			MinStock:        product.MinStock,
+			ReorderQuantity: product.ReorderQuantity,
+			TargetStock:     product.TargetStock,
+			ReservedStock:   reserved[product.ID],
+			AvailableStock:  available[product.ID],
+			DirectDemand:    directDemand[product.ID],
+			RecipeDemand:    recipeDemand[product.ID],
+			ProjectedStock:  projected,
+			OutOfStock:      available[product.ID] <= 0,
+			SuggestedOrder:  suggested,
+			Recipes:         recipes[product.ID],
+		})
+	}
+
+	// En acil olan (minimumun en çok altına inen) önce gelir
+	sort.SliceStable(alerts, func(i, j int) bool {
+		return alerts[i].ProjectedStock-alerts[i].MinStock < alerts[j].ProjectedStock-alerts[j].MinStock
+	})
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"days":       days,
+		"locationId": locationID,
+		"alerts":     alerts,
+	}})
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"stock-api/internal/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type BarcodeHandler struct {
+	db *gorm.DB
+}
+
+func NewBarcodeHandler(db *gorm.DB) *BarcodeHandler {
+	return &BarcodeHandler{db: db}
+}
+
+// barcodeType EAN-8, UPC-A ve EAN-13 barkodlarının kontrol hanesini doğrular ve türünü döner
+func barcodeType(code string) (string, bool) {
+	var barcodeType string
+	switch len(code) {
+	case 8:
+		barcodeType = "EAN-8"
+	case 12:
+		barcodeType = "UPC-A"
+	case 13:
+		barcodeType = "EAN-13"
+	default:
+		return "", false
+	}
+
+	// Sağdan ilk veri hanesi 3, sonraki 1 ağırlıklıdır
+	sum := 0
+	for i := 0; i < len(code)-1; i++ {
+		if code[i] < '0' || code[i] > '9' {
+			return "", false
+		}
+		digit := int(code[i] - '0')
+		if (len(code)-1-i)%2 == 1 {
+			sum += 3 * digit
+		} else {
+			sum += digit
+		}
+	}
+
+	last := code[len(code)-1]
+	if last < '0' || last > '9' {
+		return "", false
+	}
+	return barcodeType, (10-sum%10)%10 == int(last-'0')
+}
+
+// prepareBarcode barkodu doğrular, türünü ve ambalaj birimini ayarlar; hata 400 olarak döner
+func prepareBarcode(db *gorm.DB, product models.Product, barcode *models.ProductBarcode) error {
+	barcode.Barcode = strings.TrimSpace(barcode.Barcode)
+	kind, ok := barcodeType(barcode.Barcode)
+	if !ok {
+		return fmt.Errorf("geçersiz EAN/UPC barkodu: %s", barcode.Barcode)
+	}
+	barcode.Type = kind
+
+	var count int64
+	if err := db.Model(&models.ProductBarcode{}).Where("barcode = ?", barcode.Barcode).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return fmt.Errorf("%s barkodu başka bir ürüne tanımlı", barcode.Barcode)
+	}
+
+	barcode.UnitCode = strings.TrimSpace(barcode.UnitCode)
+	if barcode.UnitCode != "" && product.ID != 0 {
+		if _, err := unitFactor(db, product, barcode.UnitCode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findProductByBarcode barkodun ait olduğu aktif ürünü bulur
+func findProductByBarcode(db *gorm.DB, code string) (models.Product, models.ProductBarcode, error) {
+	var barcode models.ProductBarcode
+	var product models.Product
+	if err := db.Where("barcode = ?", strings.TrimSpace(code)).First(&barcode).Error; err != nil {
+		return product, barcode, err
+	}
+	err := db.First(&product, barcode.ProductID).Error
+	return product, barcode, err
+}
+
+// AddBarcode - ürüne barkod ekler
+func (h *BarcodeHandler) AddBarcode(c *gin.Context) {
+	var product models.Product
+	if err := h.db.First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ürün bulunamadı"})
+		return
+	}
+
+	var barcode models.ProductBarcode
+	if err := c.ShouldBindJSON(&barcode); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+	barcode.ID = 0
+	barcode.ProductID = product.ID
+
+	if err := prepareBarcode(h.db, product, &barcode); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.db.Create(&barcode).Error; err != nil {
+		log.Printf("Barkod oluşturma hatası: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Barkod kaydedilemedi"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": barcode})
+}
+
+func (h *BarcodeHandler) DeleteBarcode(c *gin.Context) {
+	result := h.db.Where("product_id = ?", c.Param("id")).
+		Delete(&models.ProductBarcode{}, c.Param("barcodeId"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Barkod silinemedi"})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Barkod bulunamadı"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Barkod başarıyla silindi"})
+}
+
+// LookupProduct - barkod veya SKU ile ürün arar
+func (h *BarcodeHandler) LookupProduct(c *gin.Context) {
+	var product models.Product
+	var barcode models.ProductBarcode
+	var err error
+
+	switch {
+	case c.Query("barcode") != "":
+		product, barcode, err = findProductByBarcode(h.db, c.Query("barcode"))
+	case c.Query("sku") != "":
+		err = h.db.Where("sku = ?", strings.TrimSpace(c.Query("sku"))).First(&product).Error
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Barkod veya SKU gerekli"})
+		return
+	}
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ürün bulunamadı"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün aranamadı"})
+		return
+	}
+
+	if err := h.db.Preload("Category").Preload("Barcodes").First(&product, product.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün detayları alınamadı"})
+		return
+	}
+
+	result := gin.H{"product": product}
+	if barcode.ID != 0 {
+		result["barcode"] = barcode
+	}
+	c.JSON(http.StatusOK, gin.H{"data": result})
+}
@@ -0,0 +1,347 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"stock-api/internal/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CategoryHandler struct {
+	db *gorm.DB
+}
+
+func NewCategoryHandler(db *gorm.DB) *CategoryHandler {
+	return &CategoryHandler{db: db}
+}
+
+// categorySubtree kategori ve tüm alt kategorilerinin ID'lerini seçen alt sorguyu döner
+func categorySubtree(db *gorm.DB, categoryID interface{}) (*gorm.DB, error) {
+	var category models.Category
+	if err := db.First(&category, categoryID).Error; err != nil {
+		return nil, err
+	}
+	return db.Model(&models.Category{}).Select("id").Where("path LIKE ?", category.Path+"%"), nil
+}
+
+// categoryFullNames her kategori için "İçecekler > Sıcak > Kahve" biçiminde tam ad üretir
+func categoryFullNames(categories []models.Category) map[uint]string {
+	names := make(map[uint]string, len(categories))
+	for _, category := range categories {
+		names[category.ID] = category.Name
+	}
+
+	fullNames := make(map[uint]string, len(categories))
+	for _, category := range categories {
+		var parts []string
+		for _, id := range strings.Split(strings.Trim(category.Path, "/"), "/") {
+			var categoryID uint
+			if _, err := fmt.Sscan(id, &categoryID); err == nil {
+				parts = append(parts, names[categoryID])
+			}
+		}
+		fullNames[category.ID] = strings.Join(parts, " > ")
+	}
+	return fullNames
+}
+
+// buildCategoryTree düz listeyi kök düğümlerden başlayan ağaca çevirir
+func buildCategoryTree(categories []models.Category, parentID *uint) []models.Category {
+	var nodes []models.Category
+	for _, category := range categories {
+		if (parentID == nil && category.ParentID == nil) ||
+			(parentID != nil && category.ParentID != nil && *category.ParentID == *parentID) {
+			id := category.ID
+			category.Children = buildCategoryTree(categories, &id)
+			nodes = append(nodes, category)
+		}
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].Name < nodes[j].Name })
+	return nodes
+}
+
+func (h *CategoryHandler) CreateCategory(c *gin.Context) {
+	var category models.Category
+	if err := c.ShouldBindJSON(&category); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+	category.ID = 0
+	category.Children = nil
+	category.Name = strings.TrimSpace(category.Name)
+	if category.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kategori adı gerekli"})
+		return
+	}
+
+	parentPath := "/"
+	if category.ParentID != nil {
+		var parent models.Category
+		if err := h.db.First(&parent, *category.ParentID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Üst kategori bulunamadı"})
+			return
+		}
+		parentPath = parent.Path
+	}
+
+	if !h.checkSiblingName(c, category.ParentID, category.Name, 0) {
+		return
+	}
+
+	// Transaction başlat
+	tx := h.db.Begin()
+
+	if err := tx.Create(&category).Error; err != nil {
+		log.Printf("Kategori oluşturma hatası: %v", err)
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kategori kaydedilemedi"})
+		return
+	}
+
+	// Yol ID'ye bağlı olduğu için kayıttan sonra yazılır
+	category.Path = fmt.Sprintf("%s%d/", parentPath, category.ID)
+	if err := tx.Model(&category).Update("path", category.Path).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kategori kaydedilemedi"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusCreated, gin.H{"data": category})
+}
+
+// checkSiblingName aynı üst kategori altında aynı isimde ikinci düğüm açılmasını engeller
+func (h *CategoryHandler) checkSiblingName(c *gin.Context, parentID *uint, name string, excludeID uint) bool {
+	query := h.db.Model(&models.Category{}).Where("LOWER(name) = LOWER(?) AND id != ?", name, excludeID)
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kategori kontrol edilemedi"})
+		return false
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu üst kategori altında aynı isimde bir kategori var"})
+		return false
+	}
+	return true
+}
+
+// GetCategories - kategori ağacını, flat=true ile düz listeyi döner
+func (h *CategoryHandler) GetCategories(c *gin.Context) {
+	var categories []models.Category
+	if err := h.db.Order("path asc").Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kategoriler listelenemedi"})
+		return
+	}
+
+	fullNames := categoryFullNames(categories)
+	for i := range categories {
+		categories[i].FullName = fullNames[categories[i].ID]
+	}
+
+	if c.Query("flat") == "true" {
+		c.JSON(http.StatusOK, gin.H{"data": categories})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": buildCategoryTree(categories, nil)})
+}
+
+// GetCategory - kategoriyi alt ağacıyla birlikte getirir
+func (h *CategoryHandler) GetCategory(c *gin.Context) {
+	var category models.Category
+	if err := h.db.First(&category, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kategori bulunamadı"})
+		return
+	}
+
+	var categories []models.Category
+	if err := h.db.Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kategoriler alınamadı"})
+		return
+	}
+
+	id := category.ID
+	category.FullName = categoryFullNames(categories)[category.ID]
+	category.Children = buildCategoryTree(categories, &id)
+
+	c.JSON(http.StatusOK, gin.H{"data": category})
+}
+
+// UpdateCategory - kategori adını, bozulabilirlik işaretini ve maliyet yöntemini değiştirir;
+// taşıma için MoveCategory kullanılır
+func (h *CategoryHandler) UpdateCategory(c *gin.Context) {
+	var category models.Category
+	if err := h.db.First(&category, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kategori bulunamadı"})
+		return
+	}
+
+	var input struct {
+		Name          *string `json:"name"`
+		Perishable    *bool   `json:"perishable"`
+		CostingMethod *string `json:"costingMethod"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Kategori adı gerekli"})
+			return
+		}
+
+		if !h.checkSiblingName(c, category.ParentID, name, category.ID) {
+			return
+		}
+		category.Name = name
+	}
+	if input.Perishable != nil {
+		category.Perishable = *input.Perishable
+	}
+	if input.CostingMethod != nil {
+		if !models.ValidCostingMethod(*input.CostingMethod) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz maliyet yöntemi: " + *input.CostingMethod})
+			return
+		}
+		category.CostingMethod = *input.CostingMethod
+	}
+
+	if err := h.db.Model(&category).Select("name", "perishable", "costing_method").Updates(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kategori güncellenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": category})
+}
+
+// MoveCategory - düğümü alt ağacıyla birlikte başka bir üst kategoriye (veya köke) taşır
+func (h *CategoryHandler) MoveCategory(c *gin.Context) {
+	var input struct {
+		ParentID *uint `json:"parentId"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+
+	// Transaction başlat
+	tx := h.db.Begin()
+
+	var category models.Category
+	if err := tx.First(&category, c.Param("id")).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kategori bulunamadı"})
+		return
+	}
+
+	newPath := fmt.Sprintf("/%d/", category.ID)
+	if input.ParentID != nil {
+		var parent models.Category
+		if err := tx.First(&parent, *input.ParentID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Üst kategori bulunamadı"})
+			return
+		}
+
+		// Düğüm kendi altına taşınamaz
+		if strings.HasPrefix(parent.Path, category.Path) {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Kategori kendisinin veya alt kategorisinin altına taşınamaz"})
+			return
+		}
+		newPath = fmt.Sprintf("%s%d/", parent.Path, category.ID)
+	}
+
+	var count int64
+	query := tx.Model(&models.Category{}).Where("LOWER(name) = LOWER(?) AND id != ?", category.Name, category.ID)
+	if input.ParentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *input.ParentID)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kategori kontrol edilemedi"})
+		return
+	}
+	if count > 0 {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Hedef kategori altında aynı isimde bir kategori var"})
+		return
+	}
+
+	// Alt ağaçtaki tüm yolların önekini değiştir
+	oldPath := category.Path
+	if err := tx.Model(&models.Category{}).
+		Where("path LIKE ?", oldPath+"%").
+		Update("path", gorm.Expr("? || SUBSTR(path, ?)", newPath, len(oldPath)+1)).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Alt kategoriler taşınamadı"})
+		return
+	}
+
+	if err := tx.Model(&category).Update("parent_id", input.ParentID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kategori taşınamadı"})
+		return
+	}
+
+	tx.Commit()
+
+	category.ParentID = input.ParentID
+	category.Path = newPath
+	c.JSON(http.StatusOK, gin.H{"data": category})
+}
+
+// DeleteCategory - alt kategorisi, ürünü veya reçetesi olmayan kategoriyi siler
+func (h *CategoryHandler) DeleteCategory(c *gin.Context) {
+	var category models.Category
+	if err := h.db.First(&category, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Kategori bulunamadı"})
+		return
+	}
+
+	var childCount, productCount, recipeCount int64
+	if err := h.db.Model(&models.Category{}).Where("parent_id = ?", category.ID).Count(&childCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kategori kontrol edilemedi"})
+		return
+	}
+	if err := h.db.Unscoped().Model(&models.Product{}).Where("category_id = ?", category.ID).Count(&productCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kategori kontrol edilemedi"})
+		return
+	}
+	if err := h.db.Model(&models.Recipe{}).Where("category_id = ?", category.ID).Count(&recipeCount).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kategori kontrol edilemedi"})
+		return
+	}
+
+	if childCount > 0 || productCount > 0 || recipeCount > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"error": fmt.Sprintf("Kategori silinemez: %d alt kategori, %d ürün ve %d reçete bağlı",
+				childCount, productCount, recipeCount),
+		})
+		return
+	}
+
+	if err := h.db.Delete(&category).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kategori silinemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Kategori başarıyla silindi"})
+}
@@ -0,0 +1,537 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"stock-api/internal/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type CountSessionHandler struct {
+	db *gorm.DB
+}
+
+func NewCountSessionHandler(db *gorm.DB) *CountSessionHandler {
+	return &CountSessionHandler{db: db}
+}
+
+// countEntry sayım turunda girilen tek bir miktardır. Satır lineId ile ya da ürün ve
+// stok hareketiyle seçilir; stok hareketi verilmezse ürünün partisiz satırına yazılır
+// (sayımda bulunan, hiçbir partiye ait olmayan stok).
+type countEntry struct {
+	LineID          uint     `json:"lineId"`
+	ProductID       uint     `json:"productId"`
+	StockMovementID *uint    `json:"stockMovementId"`
+	Quantity        *float64 `json:"quantity" binding:"required,gte=0"`
+	Unit            string   `json:"unit"`
+	UnitCost        *float64 `json:"unitCost" binding:"omitempty,gte=0"`
+}
+
+// countSummary bir ürünün sayım satırlarının toplamıdır; BookStock ürün kartındaki (tüm konumların) stoğudur
+type countSummary struct {
+	ProductID        uint    `json:"productId"`
+	ProductName      string  `json:"productName"`
+	Unit             string  `json:"unit"`
+	BookStock        float64 `json:"bookStock"`
+	ExpectedQuantity float64 `json:"expectedQuantity"`
+	CountedQuantity  float64 `json:"countedQuantity"`
+	VarianceQuantity float64 `json:"varianceQuantity"`
+	VarianceCost     float64 `json:"varianceCost"`
+	UncountedLines   int     `json:"uncountedLines"`
+}
+
+// lastUnitCost ürünün en son alış maliyetini döner; partisi hiç olmayan üründe 0'dır
+func lastUnitCost(tx *gorm.DB, productID uint) (float64, error) {
+	var movements []models.StockMovement
+	if err := tx.Unscoped().
+		Where("product_id = ? AND type = ?", productID, models.MovementPurchase).
+		Order("movement_date desc").
+		Limit(1).
+		Find(&movements).Error; err != nil {
+		return 0, err
+	}
+	if len(movements) == 0 {
+		return 0, nil
+	}
+	return movements[0].UnitCost, nil
+}
+
+// OpenSession - sayım oturumu açar ve kapsamdaki ürünlerin beklenen miktarlarını parti bazında kopyalar
+func (h *CountSessionHandler) OpenSession(c *gin.Context) {
+	var input struct {
+		Name       string `json:"name" binding:"required"`
+		CategoryID *uint  `json:"categoryId"`
+		LocationID *uint  `json:"locationId"`
+		Note       string `json:"note"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+
+	// Transaction başlat
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	// Sayım tek bir konumda yapılır; verilmemişse varsayılan konum sayılır
+	location, err := resolveLocation(tx, input.LocationID)
+	if err != nil {
+		tx.Rollback()
+		respondLocationError(c, err)
+		return
+	}
+
+	query := tx.Order("id asc")
+	if input.CategoryID != nil {
+		subtree, err := categorySubtree(tx, *input.CategoryID)
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Kategori bulunamadı"})
+			return
+		}
+		query = query.Where("category_id IN (?)", subtree)
+	}
+
+	var products []models.Product
+	if err := query.Find(&products).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürünler listelenemedi"})
+		return
+	}
+	if len(products) == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sayılacak ürün bulunamadı"})
+		return
+	}
+
+	productIDs := make([]uint, len(products))
+	for i, product := range products {
+		productIDs[i] = product.ID
+	}
+
+	// Süresi geçmiş partiler de rafta olabileceğinden sayıma dahildir
+	var movements []models.StockMovement
+	if err := tx.Where("product_id IN ? AND location_id = ? AND remaining_quantity > 0", productIDs, location.ID).
+		Order("movement_date asc, id asc").
+		Find(&movements).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketleri alınamadı"})
+		return
+	}
+
+	lots := make(map[uint][]models.StockMovement)
+	for _, movement := range movements {
+		lots[movement.ProductID] = append(lots[movement.ProductID], movement)
+	}
+
+	session := models.CountSession{
+		Name:       input.Name,
+		CategoryID: input.CategoryID,
+		LocationID: &location.ID,
+		Status:     models.CountOpen,
+		Note:       input.Note,
+		OpenedAt:   time.Now(),
+	}
+	for _, product := range products {
+		for _, movement := range lots[product.ID] {
+			movementID := movement.ID
+			session.Lines = append(session.Lines, models.CountLine{
+				ProductID:        product.ID,
+				StockMovementID:  &movementID,
+				PurchaseLotID:    movement.PurchaseLotID,
+				ExpiryDate:       movement.ExpiryDate,
+				ExpectedQuantity: movement.RemainingQuantity,
+				UnitCost:         movement.UnitCost,
+			})
+		}
+
+		// Partisi olmayan ürün için sıfır beklenen miktarlı partisiz satır açılır
+		if len(lots[product.ID]) == 0 {
+			unitCost, err := lastUnitCost(tx, product.ID)
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün maliyeti alınamadı"})
+				return
+			}
+			session.Lines = append(session.Lines, models.CountLine{ProductID: product.ID, UnitCost: unitCost})
+		}
+	}
+
+	if err := tx.Create(&session).Error; err != nil {
+		log.Printf("Sayım oturumu oluşturma hatası: %v", err)
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Sayım oturumu açılamadı"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": session})
+}
+
+// GetSessions - sayım oturumlarını satırları olmadan listeler
+func (h *CountSessionHandler) GetSessions(c *gin.Context) {
+	var sessions []models.CountSession
+	query := h.db.Order("opened_at desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Sayım oturumları listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": sessions})
+}
+
+// loadSession oturumu satırları ve ürünleriyle birlikte yükler
+func loadSession(db *gorm.DB, id interface{}) (models.CountSession, error) {
+	var session models.CountSession
+	err := db.Preload("Lines", func(db *gorm.DB) *gorm.DB {
+		return db.Order("product_id asc, id asc")
+	}).
+		Preload("Lines.Product", withArchived).
+		First(&session, id).Error
+	return session, err
+}
+
+// GetSession - sayım oturumunu satırları ve ürün bazında miktar ve maliyet farklarıyla getirir
+func (h *CountSessionHandler) GetSession(c *gin.Context) {
+	session, err := loadSession(h.db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sayım oturumu bulunamadı"})
+		return
+	}
+
+	var summaries []*countSummary
+	byProduct := make(map[uint]*countSummary)
+	var varianceCost float64
+	uncounted := 0
+	for _, line := range session.Lines {
+		summary, ok := byProduct[line.ProductID]
+		if !ok {
+			summary = &countSummary{ProductID: line.ProductID}
+			if line.Product != nil {
+				summary.ProductName = line.Product.ProductName
+				summary.Unit = line.Product.Unit
+				summary.BookStock = line.Product.CurrentStock
+			}
+			byProduct[line.ProductID] = summary
+			summaries = append(summaries, summary)
+		}
+
+		summary.ExpectedQuantity += line.ExpectedQuantity
+		summary.VarianceQuantity += line.VarianceQuantity
+		summary.VarianceCost += line.VarianceCost
+		varianceCost += line.VarianceCost
+		if line.CountedQuantity == nil {
+			summary.UncountedLines++
+			uncounted++
+			continue
+		}
+		summary.CountedQuantity += *line.CountedQuantity
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"session":        session,
+		"products":       summaries,
+		"varianceCost":   varianceCost,
+		"uncountedLines": uncounted,
+	}})
+}
+
+// SubmitCounts - açık oturuma sayılan miktarları girer. Sayım birden çok turda yapılabilir;
+// add=true ise miktar satırdaki sayıma eklenir, değilse önceki sayımın yerine geçer.
+func (h *CountSessionHandler) SubmitCounts(c *gin.Context) {
+	var input struct {
+		Add    bool         `json:"add"`
+		Counts []countEntry `json:"counts" binding:"required,min=1,dive"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+
+	// Transaction başlat
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	var session models.CountSession
+	if err := tx.First(&session, c.Param("id")).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sayım oturumu bulunamadı"})
+		return
+	}
+	if session.Status != models.CountOpen {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Sayım oturumu açık değil"})
+		return
+	}
+
+	now := time.Now()
+	for _, entry := range input.Counts {
+		var line models.CountLine
+		query := tx.Where("count_session_id = ?", session.ID)
+		switch {
+		case entry.LineID != 0:
+			query = query.Where("id = ?", entry.LineID)
+		case entry.StockMovementID != nil:
+			query = query.Where("product_id = ? AND stock_movement_id = ?", entry.ProductID, *entry.StockMovementID)
+		default:
+			query = query.Where("product_id = ? AND stock_movement_id IS NULL", entry.ProductID)
+		}
+
+		err := query.First(&line).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) && entry.LineID == 0 && entry.StockMovementID == nil && entry.ProductID != 0 {
+			// Sayımda bulunan partisiz stok için yeni satır açılır
+			line = models.CountLine{CountSessionID: session.ID, ProductID: entry.ProductID}
+			line.UnitCost, err = lastUnitCost(tx, entry.ProductID)
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Sayım satırı bulunamadı"})
+			return
+		}
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Sayım satırı alınamadı"})
+			return
+		}
+
+		var product models.Product
+		if err := tx.First(&product, line.ProductID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ürün bulunamadı"})
+			return
+		}
+
+		// Sayılan miktarı ürün birimine çevir
+		factor, err := unitFactor(tx, product, entry.Unit)
+		if errors.Is(err, errUnitConversion) {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Birim dönüşümü yapılamadı"})
+			return
+		}
+
+		counted := *entry.Quantity * factor
+		if input.Add && line.CountedQuantity != nil {
+			counted += *line.CountedQuantity
+		}
+		line.CountedQuantity = &counted
+		line.CountedAt = &now
+
+		// Parti satırlarının maliyeti partiden gelir; partisiz satırda maliyet girilebilir
+		if entry.UnitCost != nil && line.StockMovementID == nil {
+			line.UnitCost = *entry.UnitCost / factor
+		}
+		line.CalculateVariance()
+
+		if err := tx.Omit("Product", "StockAdjustment").Save(&line).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Sayım kaydedilemedi"})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	session, err := loadSession(h.db, session.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Sayım oturumu alınamadı"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": session})
+}
+
+// expectedAtCount partinin sayıldığı andaki miktarını döner: güncel kalandan sayımdan
+// sonra deftere işlenen kayıtlar çıkarılır
+func expectedAtCount(tx *gorm.DB, movementID uint, countedAt time.Time) (float64, error) {
+	var movement models.StockMovement
+	if err := tx.Unscoped().First(&movement, movementID).Error; err != nil {
+		return 0, err
+	}
+
+	var after float64
+	if err := tx.Model(&models.InventoryEntry{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("stock_movement_id = ? AND created_at > ?", movementID, countedAt).
+		Scan(&after).Error; err != nil {
+		return 0, err
+	}
+	return movement.RemainingQuantity - after, nil
+}
+
+// ApproveSession - sayılan satırlardaki farkları sayım düzeltmesi olarak stoğa işler.
+// Parti satırlarının farkı onay anında, partinin sayıldığı andaki miktarına göre bulunur.
+// Eksikler ilgili partiden düşülür, fazlalar partinin maliyeti ve son kullanma tarihiyle
+// yeni parti açar; sayılmamış satırlar değişmez. Ardından ürün kartındaki stok partilerin
+// toplamıyla eşitlenir.
+func (h *CountSessionHandler) ApproveSession(c *gin.Context) {
+	// Transaction başlat
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	session, err := loadSession(tx, c.Param("id"))
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sayım oturumu bulunamadı"})
+		return
+	}
+	if session.Status != models.CountOpen {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Sayım oturumu açık değil"})
+		return
+	}
+
+	now := time.Now()
+	session.VarianceCost = 0
+	var productIDs []uint
+	seen := make(map[uint]bool)
+	for i := range session.Lines {
+		line := &session.Lines[i]
+		if !seen[line.ProductID] {
+			seen[line.ProductID] = true
+			productIDs = append(productIDs, line.ProductID)
+		}
+		if line.CountedQuantity == nil {
+			continue
+		}
+
+		// Açılıştaki beklenen miktar, açılış ile onay arasında partiye işlenen satış,
+		// transfer ve düzeltmeleri içermez; fark partinin sayıldığı andaki miktarına
+		// göre yeniden hesaplanır
+		if line.StockMovementID != nil {
+			expected, err := expectedAtCount(tx, *line.StockMovementID, *line.CountedAt)
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Parti miktarı alınamadı"})
+				return
+			}
+			line.ExpectedQuantity = expected
+			line.CalculateVariance()
+			if err := tx.Model(line).
+				Select("expected_quantity", "variance_quantity", "variance_cost").
+				Updates(line).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Sayım satırı güncellenemedi"})
+				return
+			}
+		}
+		if math.Abs(line.VarianceQuantity) < 1e-9 {
+			continue
+		}
+
+		var product models.Product
+		if err := tx.First(&product, line.ProductID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Ürün bulunamadı"})
+			return
+		}
+
+		adjustment := models.StockAdjustment{
+			ProductID:      line.ProductID,
+			LocationID:     session.LocationID,
+			Reason:         models.AdjustmentCountCorrection,
+			Quantity:       line.VarianceQuantity,
+			UnitCost:       line.UnitCost,
+			ExpiryDate:     line.ExpiryDate,
+			Note:           fmt.Sprintf("Sayım #%d: %s", session.ID, session.Name),
+			AdjustmentDate: now,
+		}
+		if line.VarianceQuantity < 0 {
+			adjustment.SourceMovementID = line.StockMovementID
+		}
+
+		if err := applyAdjustment(tx, &adjustment, product); err != nil {
+			tx.Rollback()
+			respondAllocationError(c, err)
+			return
+		}
+
+		line.StockAdjustmentID = &adjustment.ID
+		line.VarianceCost = adjustment.TotalCost
+		if err := tx.Model(line).
+			Select("stock_adjustment_id", "variance_cost").
+			Updates(line).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Sayım satırı güncellenemedi"})
+			return
+		}
+		session.VarianceCost += adjustment.TotalCost
+	}
+
+	// Ürün kartındaki stok, sayımla doğrulanan partilerin toplamına eşitlenir
+	if err := tx.Model(&models.Product{}).
+		Where("id IN ?", productIDs).
+		Update("current_stock", gorm.Expr(
+			"(SELECT COALESCE(SUM(remaining_quantity), 0) FROM stock_movements WHERE stock_movements.product_id = products.id AND stock_movements.deleted_at IS NULL)",
+		)).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün stokları güncellenemedi"})
+		return
+	}
+
+	session.Status = models.CountApproved
+	session.ApprovedAt = &now
+	if err := tx.Model(&session).
+		Select("status", "approved_at", "variance_cost").
+		Updates(&session).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Sayım oturumu onaylanamadı"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": session})
+}
+
+// CancelSession - açık sayım oturumunu stoğa dokunmadan iptal eder
+func (h *CountSessionHandler) CancelSession(c *gin.Context) {
+	var session models.CountSession
+	if err := h.db.First(&session, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sayım oturumu bulunamadı"})
+		return
+	}
+	if session.Status != models.CountOpen {
+		c.JSON(http.StatusConflict, gin.H{"error": "Sayım oturumu açık değil"})
+		return
+	}
+
+	if err := h.db.Model(&session).Update("status", models.CountCancelled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Sayım oturumu iptal edilemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": session})
+}
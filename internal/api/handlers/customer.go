@@ -0,0 +1,412 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"stock-api/internal/database"
+	"stock-api/internal/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errCustomerNotFound = errors.New("müşteri bulunamadı")
+
+type CustomerHandler struct {
+	db *gorm.DB
+}
+
+func NewCustomerHandler(db *gorm.DB) *CustomerHandler {
+	return &CustomerHandler{db: db}
+}
+
+// resolveCustomer satışın müşterisini bulur. customerID verilmişse o müşteri, verilmemişse
+// telefon numarasıyla kayıtlı müşteri döner; telefonla kayıt yoksa ve ad verilmişse müşteri
+// oluşturulur. Telefon verilmemişse müşteri yoktur.
+func resolveCustomer(tx *gorm.DB, customerID *uint, name, phone string) (*models.Customer, error) {
+	var customer models.Customer
+	if customerID != nil {
+		if err := tx.First(&customer, *customerID).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, errCustomerNotFound
+			}
+			return nil, err
+		}
+		return &customer, nil
+	}
+
+	phone = models.NormalizePhone(phone)
+	if phone == "" {
+		return nil, nil
+	}
+	err := tx.Where("phone = ?", phone).First(&customer).Error
+	if err == nil {
+		return &customer, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+	customer = models.Customer{Name: name, Phone: phone}
+	if err := tx.Create(&customer).Error; err != nil {
+		return nil, err
+	}
+	return &customer, nil
+}
+
+// assignCustomer satışı müşteriye bağlar; ad ve telefon satış anındaki haliyle saklanır
+func assignCustomer(sale *models.Sale, customer *models.Customer) {
+	if customer == nil {
+		return
+	}
+	sale.CustomerID = &customer.ID
+	sale.CustomerName = customer.Name
+	sale.CustomerPhone = customer.Phone
+}
+
+// respondCustomerError satışın müşterisi bulunamadığında ya da kaydedilemediğinde yanıtı yazar
+func respondCustomerError(c *gin.Context, err error) {
+	if errors.Is(err, errCustomerNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Müşteri bulunamadı"})
+		return
+	}
+	log.Printf("Müşteri eşleştirme hatası: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Müşteri kaydedilemedi"})
+}
+
+// validateCustomer zorunlu alanları ve adresleri kontrol eder; telefon ve vergi numarasını
+// karşılaştırılabilir biçime getirir
+func (h *CustomerHandler) validateCustomer(c *gin.Context, customer *models.Customer) bool {
+	customer.Name = strings.TrimSpace(customer.Name)
+	customer.Phone = models.NormalizePhone(customer.Phone)
+	customer.TaxNumber = strings.TrimSpace(customer.TaxNumber)
+	if customer.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Müşteri adı gereklidir"})
+		return false
+	}
+
+	defaults := 0
+	for i := range customer.Addresses {
+		customer.Addresses[i].ID = 0
+		customer.Addresses[i].CustomerID = customer.ID
+		if strings.TrimSpace(customer.Addresses[i].Address) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Adres boş olamaz"})
+			return false
+		}
+		if customer.Addresses[i].IsDefault {
+			defaults++
+		}
+	}
+	if defaults > 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Yalnızca bir varsayılan adres olabilir"})
+		return false
+	}
+	if defaults == 0 && len(customer.Addresses) > 0 {
+		customer.Addresses[0].IsDefault = true
+	}
+
+	return true
+}
+
+// checkCustomerUnique telefon ve vergi numarası tekilliğini kaydın yazılacağı transaction
+// içinde kontrol eder. Aynı telefon ya da vergi numarasıyla kayıtlı müşteri varsa 409 ile
+// mevcut müşterinin ID'si döner.
+func checkCustomerUnique(c *gin.Context, tx *gorm.DB, customer *models.Customer) bool {
+	for _, check := range []struct {
+		column, value, message string
+	}{
+		{"phone", customer.Phone, "Bu telefon numarasıyla kayıtlı bir müşteri zaten var"},
+		{"tax_number", customer.TaxNumber, "Bu vergi numarasıyla kayıtlı bir müşteri zaten var"},
+	} {
+		if check.value == "" {
+			continue
+		}
+
+		var existing models.Customer
+		err := tx.Where(check.column+" = ? AND id != ?", check.value, customer.ID).First(&existing).Error
+		if err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": check.message, "customerId": existing.ID})
+			return false
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Müşteri kontrol edilemedi"})
+			return false
+		}
+	}
+
+	return true
+}
+
+// respondCustomerConflict kaydı telefon ya da vergi numarası tekil indeksine takılan
+// müşteri için 409 yazar; hata bir tekillik ihlali değilse false döner
+func respondCustomerConflict(c *gin.Context, err error) bool {
+	switch {
+	case database.IsUniqueViolation(err, "customers.phone"):
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu telefon numarasıyla kayıtlı bir müşteri zaten var"})
+	case database.IsUniqueViolation(err, "customers.tax_number"):
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu vergi numarasıyla kayıtlı bir müşteri zaten var"})
+	default:
+		return false
+	}
+	return true
+}
+
+func (h *CustomerHandler) CreateCustomer(c *gin.Context) {
+	var customer models.Customer
+	if err := c.ShouldBindJSON(&customer); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+	customer.ID = 0
+
+	if !h.validateCustomer(c, &customer) {
+		return
+	}
+
+	// Tekillik kontrolü ve kayıt aynı transaction'da yapılır; yazma kilidi baştan alındığı
+	// için aynı numarayla eşzamanlı gelen ikinci istek ilkinin kaydını görür
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	if !checkCustomerUnique(c, tx, &customer) {
+		tx.Rollback()
+		return
+	}
+
+	// Müşteri adresleriyle birlikte kaydedilir
+	if err := tx.Create(&customer).Error; err != nil {
+		tx.Rollback()
+		if respondCustomerConflict(c, err) {
+			return
+		}
+		log.Printf("Müşteri oluşturma hatası: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Müşteri kaydedilemedi"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": customer})
+}
+
+// GetCustomers - müşterileri ada göre listeler; q ad, telefon ya da vergi numarasında arar
+func (h *CustomerHandler) GetCustomers(c *gin.Context) {
+	var customers []models.Customer
+	query := h.db.Preload("Addresses").Order("name asc")
+
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		condition := h.db.Where("name LIKE ?", "%"+q+"%").Or("tax_number = ?", q)
+		if phone := models.NormalizePhone(q); phone != "" {
+			condition = condition.Or("phone LIKE ?", "%"+phone+"%")
+		}
+		query = query.Where(condition)
+	}
+
+	if err := query.Find(&customers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Müşteriler listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": customers})
+}
+
+// GetCustomer - ID ile müşteriyi adresleriyle getirir
+func (h *CustomerHandler) GetCustomer(c *gin.Context) {
+	var customer models.Customer
+	if err := h.db.Preload("Addresses").First(&customer, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Müşteri bulunamadı"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": customer})
+}
+
+// UpdateCustomer - müşteri bilgilerini günceller; verilen adresler mevcut adreslerin yerini alır
+func (h *CustomerHandler) UpdateCustomer(c *gin.Context) {
+	var customer models.Customer
+	if err := h.db.First(&customer, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Müşteri bulunamadı"})
+		return
+	}
+
+	var input models.Customer
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+	input.ID = customer.ID
+	input.CreatedAt = customer.CreatedAt
+
+	if !h.validateCustomer(c, &input) {
+		return
+	}
+
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	if !checkCustomerUnique(c, tx, &input) {
+		tx.Rollback()
+		return
+	}
+
+	if err := tx.Omit("Addresses").Save(&input).Error; err != nil {
+		tx.Rollback()
+		if respondCustomerConflict(c, err) {
+			return
+		}
+		log.Printf("Müşteri güncelleme hatası: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Müşteri güncellenemedi"})
+		return
+	}
+
+	if err := tx.Where("customer_id = ?", input.ID).Delete(&models.CustomerAddress{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Adresler güncellenemedi"})
+		return
+	}
+	if len(input.Addresses) > 0 {
+		if err := tx.Create(&input.Addresses).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Adresler güncellenemedi"})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": input})
+}
+
+// DeleteCustomer - satış geçmişi olmayan müşteriyi adresleriyle birlikte siler
+func (h *CustomerHandler) DeleteCustomer(c *gin.Context) {
+	id := c.Param("id")
+
+	// Satış geçmişi olan müşteri silinemez
+	var count int64
+	if err := h.db.Model(&models.Sale{}).Where("customer_id = ?", id).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Müşteri kontrol edilemedi"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu müşteriye yapılmış satışlar var, silinemez"})
+		return
+	}
+
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	if err := tx.Where("customer_id = ?", id).Delete(&models.CustomerAddress{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Adresler silinemedi"})
+		return
+	}
+
+	result := tx.Delete(&models.Customer{}, id)
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Müşteri silinemedi"})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Müşteri bulunamadı"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Müşteri başarıyla silindi"})
+}
+
+// GetCustomerSales - müşterinin satışlarını, siparişlerini ve iadelerini toplam harcama,
+// ziyaret sayısı ve son alış tarihiyle listeler. Her tekil satış ve her sipariş bir
+// ziyarettir; iade tutarları harcamadan düşülür.
+func (h *CustomerHandler) GetCustomerSales(c *gin.Context) {
+	var customer models.Customer
+	if err := h.db.Preload("Addresses").First(&customer, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Müşteri bulunamadı"})
+		return
+	}
+
+	var sales []models.Sale
+	if err := withSaleDetails(h.db).
+		Where("customer_id = ?", customer.ID).
+		Order("sale_date asc, id asc").
+		Find(&sales).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Satışlar listelenemedi"})
+		return
+	}
+	for i := range sales {
+		presentSale(&sales[i])
+	}
+
+	standalone, orders, err := groupSalesByOrder(h.db, sales)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Siparişler listelenemedi"})
+		return
+	}
+
+	var spend float64
+	var lastPurchase *time.Time
+	visit := func(total float64, at time.Time) {
+		spend += total
+		if lastPurchase == nil || at.After(*lastPurchase) {
+			lastPurchase = &at
+		}
+	}
+	for _, sale := range standalone {
+		visit(sale.TotalPrice, sale.SaleDate)
+	}
+	for _, order := range orders {
+		visit(order.TotalPrice, order.OrderDate)
+	}
+
+	// İade belgeleri harcamadan düşülür
+	var returns []models.SaleReturn
+	if err := h.db.Where("customer_id = ?", customer.ID).Order("return_date asc, id asc").Find(&returns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "İadeler listelenemedi"})
+		return
+	}
+	var credited float64
+	for _, r := range returns {
+		credited += r.TotalAmount
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"customer": customer,
+		"sales":    standalone,
+		"orders":   orders,
+		"returns":  returns,
+		"totals": gin.H{
+			"visitCount":     len(standalone) + len(orders),
+			"lifetimeSpend":  spend - credited,
+			"returnedAmount": credited,
+			"lastPurchase":   lastPurchase,
+		},
+	}})
+}
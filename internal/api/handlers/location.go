@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"stock-api/internal/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errLocationNotFound = errors.New("konum bulunamadı")
+
+type LocationHandler struct {
+	db *gorm.DB
+}
+
+func NewLocationHandler(db *gorm.DB) *LocationHandler {
+	return &LocationHandler{db: db}
+}
+
+// resolveLocation verilen konumu, boşsa varsayılan konumu döner
+func resolveLocation(db *gorm.DB, locationID *uint) (models.Location, error) {
+	var location models.Location
+	query := db.Where("is_default = ?", true)
+	if locationID != nil {
+		query = db.Where("id = ?", *locationID)
+	}
+
+	err := query.First(&location).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return location, errLocationNotFound
+	}
+	return location, err
+}
+
+// respondLocationError konum çözümleme hatasını yanıta yazar
+func respondLocationError(c *gin.Context, err error) {
+	if errors.Is(err, errLocationNotFound) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Konum bulunamadı"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Konum alınamadı"})
+}
+
+// queryLocationID ?locationId parametresini okur; yoksa 0 (tüm konumlar) döner
+func queryLocationID(c *gin.Context) (uint, bool) {
+	value := c.Query("locationId")
+	if value == "" {
+		return 0, true
+	}
+
+	var locationID uint
+	if _, err := fmt.Sscan(value, &locationID); err != nil || locationID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz konum"})
+		return 0, false
+	}
+	return locationID, true
+}
+
+func (h *LocationHandler) GetLocations(c *gin.Context) {
+	var locations []models.Location
+	if err := h.db.Order("name asc").Find(&locations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Konumlar listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": locations})
+}
+
+func (h *LocationHandler) CreateLocation(c *gin.Context) {
+	var location models.Location
+	if err := c.ShouldBindJSON(&location); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+	location.ID = 0
+	location.IsDefault = false
+	location.Name = strings.TrimSpace(location.Name)
+	if location.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Konum adı gereklidir"})
+		return
+	}
+
+	var count int64
+	if err := h.db.Unscoped().Model(&models.Location{}).Where("name = ?", location.Name).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Konum kontrol edilemedi"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu adla bir konum zaten var"})
+		return
+	}
+
+	if err := h.db.Create(&location).Error; err != nil {
+		log.Printf("Konum oluşturma hatası: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Konum kaydedilemedi"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": location})
+}
+
+// UpdateLocation - konumun adını ve açıklamasını günceller
+func (h *LocationHandler) UpdateLocation(c *gin.Context) {
+	var location models.Location
+	if err := h.db.First(&location, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Konum bulunamadı"})
+		return
+	}
+
+	var input struct {
+		Name        *string `json:"name"`
+		Description *string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+
+	if input.Name != nil {
+		name := strings.TrimSpace(*input.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Konum adı gereklidir"})
+			return
+		}
+
+		var count int64
+		if err := h.db.Unscoped().Model(&models.Location{}).
+			Where("name = ? AND id != ?", name, location.ID).
+			Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Konum kontrol edilemedi"})
+			return
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Bu adla bir konum zaten var"})
+			return
+		}
+		location.Name = name
+	}
+	if input.Description != nil {
+		location.Description = *input.Description
+	}
+
+	if err := h.db.Model(&location).Select("name", "description").Updates(&location).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Konum güncellenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": location})
+}
+
+// DeleteLocation - stoğu olmayan konumu arşivler; varsayılan konum silinemez
+func (h *LocationHandler) DeleteLocation(c *gin.Context) {
+	var location models.Location
+	if err := h.db.First(&location, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Konum bulunamadı"})
+		return
+	}
+	if location.IsDefault {
+		c.JSON(http.StatusConflict, gin.H{"error": "Varsayılan konum silinemez"})
+		return
+	}
+
+	var count int64
+	if err := h.db.Model(&models.StockMovement{}).
+		Where("location_id = ? AND remaining_quantity > 0", location.ID).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Konum kontrol edilemedi"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Konumda %d partide stok var, silinemez", count)})
+		return
+	}
+
+	if err := h.db.Delete(&location).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Konum silinemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Konum başarıyla silindi"})
+}
+
+// GetProductLevels - ürünün konum bazındaki stok seviyelerini listeler
+func (h *LocationHandler) GetProductLevels(c *gin.Context) {
+	var levels []models.ProductLocationLevel
+	if err := h.db.Preload("Location").
+		Where("product_id = ?", c.Param("id")).
+		Order("location_id asc").
+		Find(&levels).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok seviyeleri listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": levels})
+}
+
+// SetProductLevel - ürünün bir konumdaki minimum, sipariş ve hedef stok seviyelerini kaydeder
+func (h *LocationHandler) SetProductLevel(c *gin.Context) {
+	var product models.Product
+	if err := h.db.First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ürün bulunamadı"})
+		return
+	}
+
+	var input models.ProductLocationLevel
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+
+	location, err := resolveLocation(h.db, &input.LocationID)
+	if err != nil {
+		respondLocationError(c, err)
+		return
+	}
+
+	if !validStockLevels(c, models.Product{
+		MinStock:        input.MinStock,
+		ReorderQuantity: input.ReorderQuantity,
+		TargetStock:     input.TargetStock,
+	}) {
+		return
+	}
+
+	var level models.ProductLocationLevel
+	err = h.db.Where("product_id = ? AND location_id = ?", product.ID, location.ID).First(&level).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok seviyesi alınamadı"})
+		return
+	}
+
+	level.ProductID = product.ID
+	level.LocationID = location.ID
+	level.MinStock = input.MinStock
+	level.ReorderQuantity = input.ReorderQuantity
+	level.TargetStock = input.TargetStock
+	if err := h.db.Omit("Location").Save(&level).Error; err != nil {
+		log.Printf("Stok seviyesi kaydetme hatası: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok seviyesi kaydedilemedi"})
+		return
+	}
+
+	level.Location = &location
+	c.JSON(http.StatusOK, gin.H{"data": level})
+}
@@ -1,11 +1,17 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"log"
 	"math"
 	"net/http"
+	"stock-api/internal/database"
+	"stock-api/internal/inventory"
 	"stock-api/internal/models"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -19,6 +25,12 @@ func NewProductHandler(db *gorm.DB) *ProductHandler {
 	return &ProductHandler{db: db}
 }
 
+// withArchived arşivlenmiş kayıtları da yükleyen Preload koşuludur; geçmiş kayıtlar
+// arşivlenen ürün ve partileri göstermeye devam eder
+func withArchived(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
 func (h *ProductHandler) CreateProduct(c *gin.Context) {
 	var product models.Product
 	if err := c.ShouldBindJSON(&product); err != nil {
@@ -26,70 +38,349 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		return
 	}
 
-	// Ürün oluşturma validasyonu
-	if product.CompanyName == "" || product.Category == "" || product.ProductName == "" ||
-		product.Unit == "" || product.InvoiceNo == "" || product.InvoiceDate.IsZero() ||
-		product.InitialStock < 0 || product.CurrentStock < 0 || product.UnitPrice < 0 ||
-		product.VAT < 0 || product.TotalCost < 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Tüm alanlar gereklidir ve sayısal değerler 0'dan büyük olmalıdır"})
+	// Katalog kalemi validasyonu
+	product.ProductName = strings.TrimSpace(product.ProductName)
+	if product.ProductName == "" || product.CategoryID == nil || product.Unit == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ürün adı, kategori ve birim gereklidir"})
 		return
 	}
 
-	log.Printf("Ürün oluşturuluyor: %+v", product)
-	// Transaction başlat
-	tx := h.db.Begin()
+	var category models.Category
+	if err := h.db.First(&category, *product.CategoryID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kategori bulunamadı"})
+		return
+	}
+	product.Category = nil
+
+	// Ürün birimi birim kayıtlarından biri olmalı
+	unit, err := findUnit(h.db, product.Unit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Birim tanımlı değil: " + product.Unit})
+		return
+	}
+	product.Unit = unit.Code
+
+	if !validStockLevels(c, product) {
+		return
+	}
+
+	// Aynı isimde ikinci bir katalog kalemi stoğu ikiye böler
+	var count int64
+	if err := h.db.Model(&models.Product{}).
+		Where("name_key = ?", models.ProductNameKey(product.ProductName)).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün kontrol edilemedi"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu isimde bir ürün zaten var"})
+		return
+	}
+
+	// Varsayılan tedarikçi verilmişse var olmalı
+	if product.SupplierID != nil {
+		var supplier models.Supplier
+		if err := h.db.First(&supplier, *product.SupplierID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tedarikçi bulunamadı"})
+			return
+		}
+	}
+	product.Supplier = nil
 
-	if err := tx.Create(&product).Error; err != nil {
+	product.SKU = strings.TrimSpace(product.SKU)
+	if !h.checkSKU(c, product.SKU, 0) {
+		return
+	}
+
+	// Barkodlar ürünle birlikte kaydedilir
+	seen := make(map[string]bool)
+	for i := range product.Barcodes {
+		product.Barcodes[i].ID = 0
+		if err := prepareBarcode(h.db, product, &product.Barcodes[i]); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if seen[product.Barcodes[i].Barcode] {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Aynı barkod birden fazla gönderilmiş: " + product.Barcodes[i].Barcode})
+			return
+		}
+		seen[product.Barcodes[i].Barcode] = true
+	}
+
+	// Stok yalnızca alış partileriyle artar
+	product.CurrentStock = 0
+	product.Lots = nil
+
+	log.Printf("Ürün oluşturuluyor: %+v", product)
+	if err := h.db.Create(&product).Error; err != nil {
+		// Aynı anda aynı isimle açılan ikinci ürün ad anahtarı indeksine takılır
+		if database.IsUniqueViolation(err, "products.name_key") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Bu isimde bir ürün zaten var"})
+			return
+		}
 		log.Printf("Ürün oluşturma hatası: %v", err)
-		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün kaydedilemedi"})
 		return
 	}
 	log.Printf("Ürün oluşturuldu, ID: %d", product.ID)
 
-	// İlk stok hareketini kaydet
-	stockMovement := models.StockMovement{
-		ProductID:         product.ID,
-		InitialQuantity:   product.InitialStock,
-		RemainingQuantity: product.InitialStock,
-		UnitCost:          product.UnitPrice,
-		MovementDate:      product.InvoiceDate,
+	c.JSON(http.StatusCreated, gin.H{"data": product})
+}
+
+// checkSKU boş olmayan SKU'nun arşivdekiler dahil tek bir üründe kullanılmasını sağlar
+func (h *ProductHandler) checkSKU(c *gin.Context, sku string, excludeID uint) bool {
+	if sku == "" {
+		return true
+	}
+
+	var count int64
+	if err := h.db.Unscoped().Model(&models.Product{}).
+		Where("sku = ? AND id != ?", sku, excludeID).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "SKU kontrol edilemedi"})
+		return false
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu SKU başka bir ürüne tanımlı"})
+		return false
+	}
+	return true
+}
+
+// validStockLevels minimum, sipariş ve hedef stok seviyelerini kontrol eder
+func validStockLevels(c *gin.Context, product models.Product) bool {
+	if product.MinStock < 0 || product.ReorderQuantity < 0 || product.TargetStock < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stok seviyeleri negatif olamaz"})
+		return false
+	}
+	if product.TargetStock > 0 && product.TargetStock < product.MinStock {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Hedef stok minimum stoktan küçük olamaz"})
+		return false
+	}
+	return true
+}
+
+// UpdateProduct - katalog bilgilerini günceller; stok alış partileri üzerinden değişir
+func (h *ProductHandler) UpdateProduct(c *gin.Context) {
+	var product models.Product
+	if err := h.db.First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ürün bulunamadı"})
+		return
+	}
+
+	var input models.Product
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+
+	input.ProductName = strings.TrimSpace(input.ProductName)
+	if input.ProductName == "" || input.CategoryID == nil || input.Unit == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ürün adı, kategori ve birim gereklidir"})
+		return
 	}
 
-	log.Printf("Stok hareketi oluşturuluyor: %+v", stockMovement)
+	var category models.Category
+	if err := h.db.First(&category, *input.CategoryID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kategori bulunamadı"})
+		return
+	}
 
-	if err := tx.Create(&stockMovement).Error; err != nil {
-		log.Printf("Stok hareketi oluşturma hatası: %v", err)
-		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketi kaydedilemedi"})
+	unit, err := findUnit(h.db, input.Unit)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Birim tanımlı değil: " + input.Unit})
 		return
 	}
+	input.Unit = unit.Code
 
-	tx.Commit()
-	c.JSON(http.StatusCreated, gin.H{"data": product})
+	if !validStockLevels(c, input) {
+		return
+	}
+
+	// Stoğu olan ürünün birimi değişirse mevcut miktarlar yanlış anlam kazanır
+	if !strings.EqualFold(input.Unit, product.Unit) && product.CurrentStock > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Stoğu olan ürünün birimi değiştirilemez"})
+		return
+	}
+
+	var count int64
+	if err := h.db.Model(&models.Product{}).
+		Where("name_key = ? AND id != ?", models.ProductNameKey(input.ProductName), product.ID).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün kontrol edilemedi"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu isimde bir ürün zaten var"})
+		return
+	}
+
+	if input.SupplierID != nil {
+		var supplier models.Supplier
+		if err := h.db.First(&supplier, *input.SupplierID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tedarikçi bulunamadı"})
+			return
+		}
+	}
+
+	input.SKU = strings.TrimSpace(input.SKU)
+	if !h.checkSKU(c, input.SKU, product.ID) {
+		return
+	}
+
+	product.ProductName = input.ProductName
+	product.SKU = input.SKU
+	product.CategoryID = input.CategoryID
+	product.Unit = input.Unit
+	product.SupplierID = input.SupplierID
+	product.MinStock = input.MinStock
+	product.ReorderQuantity = input.ReorderQuantity
+	product.TargetStock = input.TargetStock
+	product.CostingMethod = input.CostingMethod
+
+	if err := h.db.Model(&product).
+		Select("product_name", "name_key", "sku", "category_id", "unit", "supplier_id", "min_stock", "reorder_quantity", "target_stock", "costing_method").
+		Updates(&product).Error; err != nil {
+		if database.IsUniqueViolation(err, "products.name_key") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Bu isimde bir ürün zaten var"})
+			return
+		}
+		log.Printf("Ürün güncelleme hatası: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün güncellenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": product})
 }
 
+// DeleteProduct - ürünü arşivler; satış, reçete veya partisi olan ürün force=true olmadan arşivlenmez
 func (h *ProductHandler) DeleteProduct(c *gin.Context) {
-	id := c.Param("id")
+	var product models.Product
+	if err := h.db.First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ürün bulunamadı"})
+		return
+	}
+
+	if c.Query("force") != "true" {
+		var saleCount, usageCount, recipeCount, lotCount int64
+		if err := h.db.Model(&models.Sale{}).Where("product_id = ?", product.ID).Count(&saleCount).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün bağlantıları kontrol edilemedi"})
+			return
+		}
+		if err := h.db.Model(&models.StockUsage{}).
+			Joins("JOIN stock_movements ON stock_movements.id = stock_usages.stock_movement_id").
+			Where("stock_movements.product_id = ?", product.ID).
+			Count(&usageCount).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün bağlantıları kontrol edilemedi"})
+			return
+		}
+		if err := h.db.Model(&models.RecipeItem{}).Where("product_id = ?", product.ID).Count(&recipeCount).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün bağlantıları kontrol edilemedi"})
+			return
+		}
+		if err := h.db.Model(&models.PurchaseLot{}).Where("product_id = ?", product.ID).Count(&lotCount).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün bağlantıları kontrol edilemedi"})
+			return
+		}
+
+		var reasons []string
+		if saleCount > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d satışta kullanılmış", saleCount))
+		}
+		if usageCount > 0 {
+			reasons = append(reasons, fmt.Sprintf("stoğundan %d kez düşüm yapılmış", usageCount))
+		}
+		if recipeCount > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d reçete kaleminde kullanılıyor", recipeCount))
+		}
+		if lotCount > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d aktif alış partisi var", lotCount))
+		}
+
+		if len(reasons) > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error": "Ürün silinemez: " + strings.Join(reasons, ", ") +
+					". Geçmiş kayıtlar korunarak arşivlemek için force=true gönderin",
+				"references": gin.H{
+					"sales":       saleCount,
+					"stockUsages": usageCount,
+					"recipeItems": recipeCount,
+					"lots":        lotCount,
+				},
+			})
+			return
+		}
+	}
 
-	result := h.db.Delete(&models.Product{}, id)
-	if result.Error != nil {
+	// Soft delete: satış ve reçete kayıtları ürünü göstermeye devam eder
+	if err := h.db.Delete(&product).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün silinemedi"})
 		return
 	}
 
-	if result.RowsAffected == 0 {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Ürün bulunamadı"})
+	c.JSON(http.StatusOK, gin.H{"message": "Ürün arşivlendi"})
+}
+
+// RestoreProduct - arşivlenmiş ürünü geri yükler
+func (h *ProductHandler) RestoreProduct(c *gin.Context) {
+	var product models.Product
+	if err := h.db.Unscoped().
+		Where("deleted_at IS NOT NULL").
+		First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Arşivlenmiş ürün bulunamadı"})
+		return
+	}
+
+	// Arşivdeyken aynı isimle yeni ürün açılmış olabilir
+	var count int64
+	if err := h.db.Model(&models.Product{}).
+		Where("name_key = ?", models.ProductNameKey(product.ProductName)).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün kontrol edilemedi"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu isimde aktif bir ürün var, geri yüklenemez"})
+		return
+	}
+
+	// Anahtarı atanmamış eski kayıtlar da geri yüklenirken anahtar alır
+	if err := h.db.Unscoped().Model(&product).Updates(map[string]interface{}{
+		"deleted_at": nil,
+		"name_key":   models.ProductNameKey(product.ProductName),
+	}).Error; err != nil {
+		if database.IsUniqueViolation(err, "products.name_key") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Bu isimde aktif bir ürün var, geri yüklenemez"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün geri yüklenemedi"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Ürün başarıyla silindi"})
+	product.DeletedAt = gorm.DeletedAt{}
+	c.JSON(http.StatusOK, gin.H{"data": product})
 }
 
 func (h *ProductHandler) GetProducts(c *gin.Context) {
 	var products []models.Product
+	query := h.db.Preload("Supplier").Preload("Category").Order("product_name asc")
+
+	// Kategoriye göre filtrele (alt kategoriler dahil)
+	if categoryID := c.Query("categoryId"); categoryID != "" {
+		subtree, err := categorySubtree(h.db, categoryID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kategori bulunamadı"})
+			return
+		}
+		query = query.Where("category_id IN (?)", subtree)
+	}
+
+	// Arşivlenmiş ürünleri listele
+	if c.Query("archived") == "true" {
+		query = query.Unscoped().Where("deleted_at IS NOT NULL")
+	}
 
-	if err := h.db.Where("company_name != ?", "").Find(&products).Error; err != nil {
+	if err := query.Find(&products).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürünler listelenemedi"})
 		return
 	}
@@ -98,10 +389,23 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 }
 
 func (h *ProductHandler) GetAveragePrice(c *gin.Context) {
-	// Ürün adını al
+	// Katalog ürününü ID ile, geriye uyumluluk için ad ile bul
+	var product models.Product
+	productID := c.Query("productId")
 	productName := c.Query("name")
-	if productName == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Ürün adı gerekli"})
+	switch {
+	case productID != "":
+		if err := h.db.First(&product, productID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ürün bulunamadı"})
+			return
+		}
+	case productName != "":
+		if err := h.db.Where("name_key = ?", models.ProductNameKey(productName)).First(&product).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Ürün bulunamadı"})
+			return
+		}
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ürün ID veya ürün adı gerekli"})
 		return
 	}
 
@@ -116,57 +420,107 @@ func (h *ProductHandler) GetAveragePrice(c *gin.Context) {
 		}
 	}
 
-	// FIFO mantığına göre stok hareketlerini al
-	var movements []models.StockMovement
-	if err := h.db.Joins("JOIN products ON products.id = stock_movements.product_id").
-		Where("products.product_name = ? AND remaining_quantity > 0", productName).
-		Order("movement_date asc").
-		Find(&movements).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketleri alınamadı"})
+	// Miktar başka birimle verilmişse ürün birimine çevir
+	factor, err := unitFactor(h.db, product, c.Query("unit"))
+	if errors.Is(err, errUnitConversion) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Birim dönüşümü yapılamadı"})
+		return
+	}
+	quantity *= factor
+
+	// Belirli parti seçilmişse maliyet o partiden hesaplanır
+	var lotID *uint
+	if value := c.Query("lotId"); value != "" {
+		id, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz parti ID"})
+			return
+		}
+		lot := uint(id)
+		lotID = &lot
+	}
+
+	// Konum verilirse stok ve maliyet yalnızca o konumdaki partilerden hesaplanır
+	locationID, ok := queryLocationID(c)
+	if !ok {
 		return
 	}
 
-	// Hesaplamaları yap
-	var totalStock, totalValue, fifoValue float64
-	var nextFIFOCost float64
-	remainingQty := quantity
+	method, err := inventory.CostingMethod(h.db, product)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Maliyet yöntemi alınamadı"})
+		return
+	}
 
-	for _, m := range movements {
-		totalStock += m.RemainingQuantity
-		totalValue += m.RemainingQuantity * m.UnitCost
+	// Eldeki stok ve ağırlıklı ortalama maliyet
+	now := time.Now()
+	totalStock, err := inventory.Availability(h.db, product.ID, locationID, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok miktarı alınamadı"})
+		return
+	}
+	averagePrice, err := inventory.AverageCost(h.db, product.ID, locationID, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ortalama maliyet hesaplanamadı"})
+		return
+	}
 
-		// Sonraki FIFO maliyeti
-		if nextFIFOCost == 0 && m.RemainingQuantity > 0 {
-			nextFIFOCost = m.UnitCost
-		}
+	// Rezervasyonlarla ayrılan stok satılabilir stoktan düşülür
+	reservedStock, err := inventory.Reserved(h.db, product.ID, locationID, now)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rezerve stok alınamadı"})
+		return
+	}
 
-		// FIFO değeri hesapla
-		if remainingQty > 0 {
-			use := math.Min(remainingQty, m.RemainingQuantity)
-			fifoValue += use * m.UnitCost
-			remainingQty -= use
+	// Sıradaki birimin maliyeti ürünün yöntemine göre
+	nextCost := 0.0
+	if totalStock > 0 {
+		next, err := inventory.Estimate(h.db, product, inventory.Demand{
+			ProductID:  product.ID,
+			Quantity:   math.Min(1, totalStock),
+			LotID:      lotID,
+			LocationID: locationID,
+		}, now)
+		if err == nil {
+			nextCost = next.UnitCost()
 		}
 	}
 
 	// Sonuçları hazırla
 	result := gin.H{
-		"productName":  productName,
-		"totalStock":   totalStock,
-		"averagePrice": 0.0,
-		"nextFIFOCost": nextFIFOCost,
-	}
-
-	if totalStock > 0 {
-		result["averagePrice"] = totalValue / totalStock
+		"productId":      product.ID,
+		"productName":    product.ProductName,
+		"unit":           product.Unit,
+		"costingMethod":  method,
+		"totalStock":     totalStock,
+		"reservedStock":  reservedStock,
+		"availableStock": totalStock - reservedStock,
+		"averagePrice":   averagePrice,
+		"nextCost":       nextCost,
+		"nextFIFOCost":   nextCost, // eski istemciler için
 	}
 
 	if quantity > 0 {
-		if quantity > totalStock {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Yetersiz stok"})
+		quote, err := inventory.Estimate(h.db, product, inventory.Demand{
+			ProductID:  product.ID,
+			Quantity:   quantity,
+			LotID:      lotID,
+			LocationID: locationID,
+		}, now)
+		if err != nil {
+			respondAllocationError(c, err)
 			return
 		}
-		result["fifoValue"] = fifoValue
-		result["fifoCost"] = fifoValue / quantity
+		result["lots"] = quote.Lines
+		result["costValue"] = quote.Cost
+		result["unitCost"] = quote.UnitCost()
+		// Eski istemciler için
+		result["fifoValue"] = quote.Cost
+		result["fifoCost"] = quote.UnitCost()
 	}
 
 	c.JSON(http.StatusOK, gin.H{"data": result})
@@ -175,7 +529,12 @@ func (h *ProductHandler) GetAveragePrice(c *gin.Context) {
 // GetProduct - ID ile ürün getirme
 func (h *ProductHandler) GetProduct(c *gin.Context) {
 	var product models.Product
-	if err := h.db.First(&product, c.Param("id")).Error; err != nil {
+	if err := h.db.Preload("Supplier").
+		Preload("Category").
+		Preload("Barcodes").
+		Preload("Lots.Supplier").
+		Preload("Lots.StockMovement", lotMovement).
+		First(&product, c.Param("id")).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Ürün bulunamadı"})
 		return
 	}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"stock-api/internal/inventory"
+	"stock-api/internal/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PurchaseInvoiceHandler struct {
+	db *gorm.DB
+}
+
+func NewPurchaseInvoiceHandler(db *gorm.DB) *PurchaseInvoiceHandler {
+	return &PurchaseInvoiceHandler{db: db}
+}
+
+// CreatePurchaseInvoice - fatura başlığı ve tüm satırlarını tek transaction'da kaydeder
+func (h *PurchaseInvoiceHandler) CreatePurchaseInvoice(c *gin.Context) {
+	var invoice models.PurchaseInvoice
+	if err := c.ShouldBindJSON(&invoice); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+
+	invoice.InvoiceNo = strings.TrimSpace(invoice.InvoiceNo)
+	if invoice.InvoiceNo == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fatura numarası gerekli"})
+		return
+	}
+
+	var supplier models.Supplier
+	if err := h.db.First(&supplier, invoice.SupplierID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tedarikçi bulunamadı"})
+		return
+	}
+
+	// Aynı tedarikçinin aynı faturası ikinci kez girilemez
+	var count int64
+	if err := h.db.Model(&models.PurchaseInvoice{}).
+		Where("supplier_id = ? AND invoice_no = ?", invoice.SupplierID, invoice.InvoiceNo).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Fatura kontrol edilemedi"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu fatura daha önce kaydedilmiş"})
+		return
+	}
+
+	// Satır validasyonu
+	for i, line := range invoice.Lines {
+		if line.ProductID == 0 || line.InitialStock <= 0 || line.UnitPrice < 0 || line.VAT < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d. satır geçersiz: ürün, miktar ve fiyat gerekli", i+1)})
+			return
+		}
+		if line.ExpiryDate != nil && inventory.ExpiryDay(*line.ExpiryDate).Before(inventory.ExpiryDay(invoice.InvoiceDate)) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d. satır: son kullanma tarihi fatura tarihinden önce olamaz", i+1)})
+			return
+		}
+	}
+
+	// Satırlar başlıktaki tedarikçi, fatura no, tarih ve (satırda verilmemişse) konumu taşır
+	location, err := resolveLocation(h.db, invoice.LocationID)
+	if err != nil {
+		respondLocationError(c, err)
+		return
+	}
+	invoice.LocationID = &location.ID
+
+	lines := invoice.Lines
+	for i := range lines {
+		if lines[i].LocationID == nil {
+			lines[i].LocationID = invoice.LocationID
+		}
+		lines[i].ID = 0
+		lines[i].SupplierID = invoice.SupplierID
+		lines[i].InvoiceNo = invoice.InvoiceNo
+		lines[i].InvoiceDate = invoice.InvoiceDate
+		lines[i].Product = nil
+		lines[i].Supplier = nil
+		lines[i].StockMovement = nil
+	}
+	invoice.CalculateTotals()
+	invoice.Lines = nil
+	invoice.Supplier = nil
+
+	// Transaction başlat
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	if err := tx.Create(&invoice).Error; err != nil {
+		log.Printf("Fatura oluşturma hatası: %v", err)
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Fatura kaydedilemedi"})
+		return
+	}
+
+	for i := range lines {
+		var product models.Product
+		if err := tx.First(&product, lines[i].ProductID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d. satır: ürün bulunamadı", i+1)})
+			return
+		}
+
+		factor, err := unitFactor(tx, product, lines[i].Unit)
+		if errors.Is(err, errUnitConversion) {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d. satır: %s", i+1, err.Error())})
+			return
+		}
+		if err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Birim dönüşümü yapılamadı"})
+			return
+		}
+		lines[i].UnitFactor = factor
+
+		lines[i].PurchaseInvoiceID = &invoice.ID
+		stockMovement, err := createLotWithMovement(tx, &lines[i])
+		if errors.Is(err, errLocationNotFound) {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d. satır: konum bulunamadı", i+1)})
+			return
+		}
+		if err != nil {
+			log.Printf("Fatura satırı oluşturma hatası: %v", err)
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("%d. satır kaydedilemedi", i+1)})
+			return
+		}
+		lines[i].StockMovement = stockMovement
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	invoice.Lines = lines
+	invoice.Supplier = &supplier
+	c.JSON(http.StatusCreated, gin.H{"data": invoice})
+}
+
+func (h *PurchaseInvoiceHandler) GetPurchaseInvoices(c *gin.Context) {
+	var invoices []models.PurchaseInvoice
+	query := h.db.Preload("Supplier").Order("invoice_date asc")
+
+	// Tedarikçiye göre filtrele
+	if supplierID := c.Query("supplierId"); supplierID != "" {
+		query = query.Where("supplier_id = ?", supplierID)
+	}
+
+	if err := query.Find(&invoices).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Faturalar listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": invoices})
+}
+
+// GetPurchaseInvoice - ID ile fatura ve satırlarını getirme
+func (h *PurchaseInvoiceHandler) GetPurchaseInvoice(c *gin.Context) {
+	var invoice models.PurchaseInvoice
+	if err := h.db.Preload("Supplier").
+		Preload("Lines", withArchived).
+		Preload("Lines.Product", withArchived).
+		Preload("Lines.StockMovement", archivedLotMovement).
+		First(&invoice, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Fatura bulunamadı"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": invoice})
+}
@@ -0,0 +1,570 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"stock-api/internal/inventory"
+	"stock-api/internal/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type PurchaseLotHandler struct {
+	db *gorm.DB
+}
+
+// LotUpdateInput yalnızca gönderilen alanları günceller
+type LotUpdateInput struct {
+	SupplierID   *uint      `json:"supplierId"`
+	InvoiceNo    *string    `json:"invoiceNo"`
+	InvoiceDate  *time.Time `json:"invoiceDate"`
+	ExpiryDate   *time.Time `json:"expiryDate"`
+	InitialStock *float64   `json:"initialStock" binding:"omitempty,gte=0"`
+	UnitPrice    *float64   `json:"unitPrice" binding:"omitempty,gte=0"`
+	VAT          *float64   `json:"vat" binding:"omitempty,gte=0"`
+	TotalCost    *float64   `json:"totalCost" binding:"omitempty,gte=0"`
+}
+
+func NewPurchaseLotHandler(db *gorm.DB) *PurchaseLotHandler {
+	return &PurchaseLotHandler{db: db}
+}
+
+// lotMovement partinin alışta açılan stok hareketini seçer; transferle başka konuma
+// taşınan parçalar da aynı partiye bağlı olduğundan yalnızca alış hareketi alınır
+func lotMovement(db *gorm.DB) *gorm.DB {
+	return db.Where("type = ?", models.MovementPurchase)
+}
+
+// archivedLotMovement arşivlenmiş alış hareketini de seçer
+func archivedLotMovement(db *gorm.DB) *gorm.DB {
+	return lotMovement(withArchived(db))
+}
+
+// CreateLot - katalog ürününe yeni alış partisi ve açılış stok hareketi ekler
+func (h *PurchaseLotHandler) CreateLot(c *gin.Context) {
+	var product models.Product
+	if err := h.db.First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ürün bulunamadı"})
+		return
+	}
+
+	var lot models.PurchaseLot
+	if err := c.ShouldBindJSON(&lot); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+
+	// Tedarikçi verilmemişse ürünün varsayılan tedarikçisini kullan
+	if lot.SupplierID == 0 && product.SupplierID != nil {
+		lot.SupplierID = *product.SupplierID
+	}
+
+	// Parti oluşturma validasyonu
+	if lot.SupplierID == 0 || lot.InvoiceNo == "" || lot.InvoiceDate.IsZero() ||
+		lot.InitialStock < 0 || lot.UnitPrice < 0 || lot.VAT < 0 || lot.TotalCost < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tüm alanlar gereklidir ve sayısal değerler 0'dan büyük olmalıdır"})
+		return
+	}
+	if lot.ExpiryDate != nil && inventory.ExpiryDay(*lot.ExpiryDate).Before(inventory.ExpiryDay(lot.InvoiceDate)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Son kullanma tarihi fatura tarihinden önce olamaz"})
+		return
+	}
+
+	var supplier models.Supplier
+	if err := h.db.First(&supplier, lot.SupplierID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tedarikçi bulunamadı"})
+		return
+	}
+
+	if _, err := resolveLocation(h.db, lot.LocationID); err != nil {
+		respondLocationError(c, err)
+		return
+	}
+
+	// Parti başka birimle alınmışsa (koli, kg) ürün birimine çarpanı sabitlenir
+	factor, err := unitFactor(h.db, product, lot.Unit)
+	if errors.Is(err, errUnitConversion) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Birim dönüşümü yapılamadı"})
+		return
+	}
+	lot.UnitFactor = factor
+
+	lot.ProductID = product.ID
+	lot.Product = nil
+	lot.Supplier = nil
+	lot.StockMovement = nil
+
+	log.Printf("Alış partisi oluşturuluyor: %+v", lot)
+	// Transaction başlat
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	stockMovement, err := createLotWithMovement(tx, &lot)
+	if err != nil {
+		log.Printf("Alış partisi oluşturma hatası: %v", err)
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Alış partisi kaydedilemedi"})
+		return
+	}
+	log.Printf("Alış partisi oluşturuldu, ID: %d", lot.ID)
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	lot.StockMovement = stockMovement
+	lot.Supplier = &supplier
+	c.JSON(http.StatusCreated, gin.H{"data": lot})
+}
+
+// UpdateLot - partiyi günceller ve bağlı stok hareketini senkron tutar. Miktar ve fiyat
+// düzeltmeleri deftere bugünün tarihiyle işlenir; fatura tarihi yalnızca kullanılmamış
+// partide değiştirilebilir ve giriş kaydı yeni tarihe taşınır.
+func (h *PurchaseLotHandler) UpdateLot(c *gin.Context) {
+	var input LotUpdateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+
+	// Transaction başlat
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	var lot models.PurchaseLot
+	if err := tx.Preload("StockMovement", lotMovement).First(&lot, c.Param("id")).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alış partisi bulunamadı"})
+		return
+	}
+
+	if lot.StockMovement == nil {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Partinin stok hareketi bulunamadı"})
+		return
+	}
+	movement := *lot.StockMovement
+	original := movement
+	factor := lot.StockFactor()
+
+	// Fatura satırlarının başlık bilgileri faturadan gelir
+	if lot.PurchaseInvoiceID != nil &&
+		((input.SupplierID != nil && *input.SupplierID != lot.SupplierID) ||
+			(input.InvoiceNo != nil && *input.InvoiceNo != lot.InvoiceNo) ||
+			(input.InvoiceDate != nil && !input.InvoiceDate.Equal(lot.InvoiceDate))) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Fatura satırının tedarikçi, fatura no ve tarihi değiştirilemez"})
+		return
+	}
+
+	if input.SupplierID != nil {
+		var supplier models.Supplier
+		if err := tx.First(&supplier, *input.SupplierID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Tedarikçi bulunamadı"})
+			return
+		}
+		lot.SupplierID = *input.SupplierID
+	}
+	if input.InvoiceNo != nil {
+		if *input.InvoiceNo == "" {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Fatura numarası boş olamaz"})
+			return
+		}
+		lot.InvoiceNo = *input.InvoiceNo
+	}
+	if input.InvoiceDate != nil {
+		if input.InvoiceDate.IsZero() {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Fatura tarihi boş olamaz"})
+			return
+		}
+		if !input.InvoiceDate.Equal(movement.MovementDate) {
+			// Giriş tarihi yalnızca kullanılmamış partide taşınabilir; tüketimler eski
+			// tarihe göre yapılmıştır
+			consumed, err := inventory.Consumed(tx, movement.ID)
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Parti kullanımı kontrol edilemedi"})
+				return
+			}
+			if consumed {
+				tx.Rollback()
+				c.JSON(http.StatusConflict, gin.H{"error": "Kullanılmış partinin fatura tarihi değiştirilemez"})
+				return
+			}
+		}
+		lot.InvoiceDate = *input.InvoiceDate
+		movement.MovementDate = *input.InvoiceDate
+	}
+	if input.ExpiryDate != nil {
+		expiry := inventory.ExpiryDay(*input.ExpiryDate)
+		lot.ExpiryDate = &expiry
+		movement.ExpiryDate = &expiry
+	}
+	if lot.ExpiryDate != nil && lot.ExpiryDate.Before(inventory.ExpiryDay(lot.InvoiceDate)) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Son kullanma tarihi fatura tarihinden önce olamaz"})
+		return
+	}
+
+	// Miktar düzeltmesi partiden çıkan net miktarın altına inemez. Çıkan miktar girişle
+	// kalan arasındaki farktır; stoğa geri alınan iadeler kalana eklendiğinden düşülmüş olur.
+	stockDelta := 0.0
+	if input.InitialStock != nil {
+		used := movement.InitialQuantity - movement.RemainingQuantity
+
+		newQuantity := *input.InitialStock * factor
+		if newQuantity < used {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{
+				"error": fmt.Sprintf("Bu partiden %.2f birim kullanılmış; miktar bundan az olamaz", used),
+			})
+			return
+		}
+
+		stockDelta = newQuantity - movement.InitialQuantity
+		lot.InitialStock = *input.InitialStock
+		movement.InitialQuantity = newQuantity
+		movement.RemainingQuantity += stockDelta
+	}
+	if input.UnitPrice != nil {
+		lot.UnitPrice = *input.UnitPrice
+		movement.UnitCost = *input.UnitPrice / factor
+	}
+	if input.VAT != nil {
+		lot.VAT = *input.VAT
+	}
+
+	// Toplam maliyet verilmemişse miktar, fiyat ve KDV'den yeniden hesaplanır
+	if input.TotalCost != nil {
+		lot.TotalCost = *input.TotalCost
+	} else if input.InitialStock != nil || input.UnitPrice != nil || input.VAT != nil {
+		net := lot.UnitPrice * lot.InitialStock
+		lot.TotalCost = net + net*(lot.VAT/100.0)
+	}
+
+	lot.StockMovement = nil
+	if err := tx.Model(&lot).
+		Select("supplier_id", "invoice_no", "invoice_date", "expiry_date", "initial_stock", "unit_price", "vat", "total_cost").
+		Updates(&lot).Error; err != nil {
+		log.Printf("Alış partisi güncelleme hatası: %v", err)
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Alış partisi güncellenemedi"})
+		return
+	}
+
+	if err := tx.Model(&movement).
+		Select("initial_quantity", "remaining_quantity", "unit_cost", "movement_date", "expiry_date").
+		Updates(&movement).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketi güncellenemedi"})
+		return
+	}
+
+	// Tarih düzeltmesinde giriş yeni tarihe taşınır (miktar ve fiyat dahil). Diğer
+	// düzeltmeler bugüne işlenir: fiyat düzeltmesi kalan miktarı yeni maliyetle yeniden
+	// girer, miktar düzeltmesi partinin ek girişi ya da iadesidir.
+	var journalErr error
+	if !movement.MovementDate.Equal(original.MovementDate) {
+		journalErr = inventory.Redate(tx, movement, movement.RemainingQuantity)
+	} else {
+		now := time.Now()
+		if movement.UnitCost != original.UnitCost && original.RemainingQuantity > 0 {
+			journalErr = inventory.Reprice(tx, movement, original.UnitCost, original.RemainingQuantity, now)
+		}
+		if journalErr == nil && stockDelta != 0 {
+			journalErr = inventory.Record(tx, models.EntryReceipt, inventory.Ref{}, movement, stockDelta, now)
+		}
+	}
+	if journalErr != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Envanter kaydı oluşturulamadı"})
+		return
+	}
+
+	if stockDelta != 0 {
+		if err := tx.Model(&models.Product{}).
+			Where("id = ?", lot.ProductID).
+			Update("current_stock", gorm.Expr("current_stock + ?", stockDelta)).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün stoğu güncellenemedi"})
+			return
+		}
+	}
+
+	// Fatura satırıysa fatura toplamlarını yeniden hesapla
+	if lot.PurchaseInvoiceID != nil {
+		var invoice models.PurchaseInvoice
+		if err := tx.Preload("Lines", withArchived).First(&invoice, *lot.PurchaseInvoiceID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Fatura bulunamadı"})
+			return
+		}
+		invoice.CalculateTotals()
+		if err := tx.Model(&invoice).
+			Select("net_amount", "vat_amount", "total_amount").
+			Updates(&invoice).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Fatura toplamları güncellenemedi"})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	lot.StockMovement = &movement
+	c.JSON(http.StatusOK, gin.H{"data": lot})
+}
+
+// DeleteLot - partiyi ve stok hareketini arşivler; kullanılmış parti force=true olmadan arşivlenmez
+func (h *PurchaseLotHandler) DeleteLot(c *gin.Context) {
+	// Transaction başlat
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	var lot models.PurchaseLot
+	if err := tx.Preload("StockMovement", lotMovement).First(&lot, c.Param("id")).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alış partisi bulunamadı"})
+		return
+	}
+
+	if lot.StockMovement != nil && c.Query("force") != "true" {
+		var usage struct {
+			SaleCount       int64
+			AdjustmentCount int64
+			TransferCount   int64
+			ProductionCount int64
+			Used            float64
+		}
+		if err := tx.Model(&models.StockUsage{}).
+			Select("COUNT(DISTINCT NULLIF(sale_id, 0)) AS sale_count, "+
+				"COUNT(DISTINCT stock_adjustment_id) AS adjustment_count, "+
+				"COUNT(DISTINCT stock_transfer_id) AS transfer_count, "+
+				"COUNT(DISTINCT production_id) AS production_count, "+
+				"COALESCE(SUM(used_quantity - "+restockedQuantity+"), 0) AS used").
+			Where("stock_movement_id = ?", lot.StockMovement.ID).
+			Scan(&usage).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok kullanımları alınamadı"})
+			return
+		}
+
+		// Satış, stok düzeltmesi, transfer ya da üretimde kullanılmış parti korunur
+		if usage.SaleCount+usage.AdjustmentCount+usage.TransferCount+usage.ProductionCount > 0 {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{
+				"error": fmt.Sprintf("Parti silinemez: %d satış, %d stok düzeltmesi, %d transfer ve %d üretimde toplam %.2f birim kullanılmış. "+
+					"Geçmiş korunarak arşivlemek için force=true gönderin",
+					usage.SaleCount, usage.AdjustmentCount, usage.TransferCount, usage.ProductionCount, usage.Used),
+				"references": gin.H{
+					"sales":        usage.SaleCount,
+					"adjustments":  usage.AdjustmentCount,
+					"transfers":    usage.TransferCount,
+					"productions":  usage.ProductionCount,
+					"usedQuantity": usage.Used,
+				},
+			})
+			return
+		}
+	}
+
+	if lot.StockMovement != nil {
+		// Arşivlenen partinin kalan miktarı artık satılabilir stok değildir
+		if err := tx.Model(&models.Product{}).
+			Where("id = ?", lot.ProductID).
+			Update("current_stock", gorm.Expr("current_stock - ?", lot.StockMovement.RemainingQuantity)).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün stoğu güncellenemedi"})
+			return
+		}
+
+		if err := tx.Delete(lot.StockMovement).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketi arşivlenemedi"})
+			return
+		}
+	}
+
+	lot.StockMovement = nil
+	if err := tx.Delete(&lot).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Alış partisi silinemedi"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Alış partisi arşivlendi"})
+}
+
+// RestoreLot - arşivlenmiş partiyi ve stok hareketini geri yükler
+func (h *PurchaseLotHandler) RestoreLot(c *gin.Context) {
+	// Transaction başlat
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	var lot models.PurchaseLot
+	if err := tx.Unscoped().
+		Where("deleted_at IS NOT NULL").
+		First(&lot, c.Param("id")).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Arşivlenmiş alış partisi bulunamadı"})
+		return
+	}
+
+	var product models.Product
+	if err := tx.First(&product, lot.ProductID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Partinin ürünü arşivde, önce ürünü geri yükleyin"})
+		return
+	}
+
+	if err := tx.Unscoped().Model(&lot).Update("deleted_at", nil).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Alış partisi geri yüklenemedi"})
+		return
+	}
+
+	var movement models.StockMovement
+	err := archivedLotMovement(tx).Where("purchase_lot_id = ?", lot.ID).First(&movement).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketi alınamadı"})
+		return
+	}
+
+	if err == nil && movement.DeletedAt.Valid {
+		if err := tx.Unscoped().Model(&movement).Update("deleted_at", nil).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketi geri yüklenemedi"})
+			return
+		}
+
+		if err := tx.Model(&models.Product{}).
+			Where("id = ?", lot.ProductID).
+			Update("current_stock", gorm.Expr("current_stock + ?", movement.RemainingQuantity)).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün stoğu güncellenemedi"})
+			return
+		}
+		movement.DeletedAt = gorm.DeletedAt{}
+		lot.StockMovement = &movement
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	lot.DeletedAt = gorm.DeletedAt{}
+	c.JSON(http.StatusOK, gin.H{"data": lot})
+}
+
+// GetLots - katalog ürününün alış partilerini listeler
+func (h *PurchaseLotHandler) GetLots(c *gin.Context) {
+	var lots []models.PurchaseLot
+	if err := h.db.Preload("Supplier").
+		Preload("StockMovement", lotMovement).
+		Where("product_id = ?", c.Param("id")).
+		Order("invoice_date asc").
+		Find(&lots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Alış partileri listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": lots})
+}
+
+// GetLot - ID ile alış partisi getirme
+func (h *PurchaseLotHandler) GetLot(c *gin.Context) {
+	var lot models.PurchaseLot
+	if err := h.db.Preload("Product").
+		Preload("Supplier").
+		Preload("StockMovement", lotMovement).
+		First(&lot, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alış partisi bulunamadı"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": lot})
+}
+
+// createLotWithMovement partiyi, açılış stok hareketini kaydeder ve ürün stoğunu artırır.
+// Konum verilmemişse parti varsayılan konuma girer.
+func createLotWithMovement(tx *gorm.DB, lot *models.PurchaseLot) (*models.StockMovement, error) {
+	location, err := resolveLocation(tx, lot.LocationID)
+	if err != nil {
+		return nil, err
+	}
+	lot.LocationID = &location.ID
+
+	if lot.ExpiryDate != nil {
+		expiry := inventory.ExpiryDay(*lot.ExpiryDate)
+		lot.ExpiryDate = &expiry
+	}
+
+	if err := tx.Create(lot).Error; err != nil {
+		return nil, err
+	}
+
+	// İlk stok hareketini ürünün kendi birimiyle kaydet
+	factor := lot.StockFactor()
+	stockMovement := models.StockMovement{
+		ProductID:         lot.ProductID,
+		LocationID:        location.ID,
+		PurchaseLotID:     &lot.ID,
+		InitialQuantity:   lot.InitialStock * factor,
+		RemainingQuantity: lot.InitialStock * factor,
+		UnitCost:          lot.UnitPrice / factor,
+		MovementDate:      lot.InvoiceDate,
+		ExpiryDate:        lot.ExpiryDate,
+	}
+
+	log.Printf("Stok hareketi oluşturuluyor: %+v", stockMovement)
+
+	if err := tx.Create(&stockMovement).Error; err != nil {
+		return nil, err
+	}
+
+	if err := inventory.Record(tx, models.EntryReceipt, inventory.Ref{}, stockMovement, stockMovement.InitialQuantity, stockMovement.MovementDate); err != nil {
+		return nil, err
+	}
+
+	// Katalog ürününün toplam stoğunu güncelle
+	if err := tx.Model(&models.Product{}).
+		Where("id = ?", lot.ProductID).
+		Update("current_stock", gorm.Expr("current_stock + ?", stockMovement.InitialQuantity)).Error; err != nil {
+		return nil, err
+	}
+
+	return &stockMovement, nil
+}
@@ -1,14 +1,16 @@
 package handlers
 
 import (
+	"fmt"
 	"log"
 	"net/http"
+	"stock-api/internal/inventory"
 	"stock-api/internal/models"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type RecipeHandler struct {
@@ -26,6 +28,28 @@ func (h *RecipeHandler) CreateRecipe(c *gin.Context) {
 		return
 	}
 
+	if recipe.CategoryID != nil {
+		var category models.Category
+		if err := h.db.First(&category, *recipe.CategoryID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Kategori bulunamadı"})
+			return
+		}
+	}
+	recipe.Category = nil
+
+	// Kalem birimleri ürün birimine çevrilebilmeli
+	for _, item := range recipe.RecipeItems {
+		var product models.Product
+		if err := h.db.First(&product, item.ProductID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%d ID'li ürün bulunamadı", item.ProductID)})
+			return
+		}
+		if _, err := unitFactor(h.db, product, item.Unit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// Transaction başlat
 	tx := h.db.Begin()
 
@@ -57,7 +81,7 @@ func (h *RecipeHandler) CreateRecipe(c *gin.Context) {
 	tx.Commit()
 
 	// İlişkili verileri yükle
-	h.db.Preload("RecipeItems.Product").First(&recipe, recipe.ID)
+	h.db.Preload("RecipeItems.Product", withArchived).First(&recipe, recipe.ID)
 
 	c.JSON(http.StatusCreated, gin.H{"data": recipe})
 }
@@ -65,7 +89,7 @@ func (h *RecipeHandler) CreateRecipe(c *gin.Context) {
 func (h *RecipeHandler) GetRecipes(c *gin.Context) {
 	var recipes []models.Recipe
 
-	if err := h.db.Preload("RecipeItems.Product").Find(&recipes).Error; err != nil {
+	if err := h.db.Preload("RecipeItems.Product", withArchived).Find(&recipes).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Reçeteler listelenemedi"})
 		return
 	}
@@ -73,6 +97,9 @@ func (h *RecipeHandler) GetRecipes(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"data": recipes})
 }
 
+// ProduceFromRecipe - reçetenin verilen adetteki malzemelerini, reçete satışındaki
+// kurallarla üretimin yapıldığı konumdaki partilerden düşer. Tüketim deftere üretim
+// olarak işlenir; üretimin maliyeti tüketilen partilerin maliyetidir.
 func (h *RecipeHandler) ProduceFromRecipe(c *gin.Context) {
 	recipeID, err := strconv.Atoi(c.Param("id"))
 	if err != nil {
@@ -80,30 +107,77 @@ func (h *RecipeHandler) ProduceFromRecipe(c *gin.Context) {
 		return
 	}
 
-	// Reçeteyi getir
+	var input models.ProductionInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı"})
+		return
+	}
+
+	// Transaction başlat; yazma kilidi alınamazsa istemci tekrar dener
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
 	var recipe models.Recipe
-	if err := h.db.Preload("RecipeItems.Product").First(&recipe, recipeID).Error; err != nil {
+	if err := tx.Preload("RecipeItems").
+		Preload("RecipeItems.Product").
+		First(&recipe, recipeID).Error; err != nil {
+		tx.Rollback()
 		c.JSON(http.StatusNotFound, gin.H{"error": "Reçete bulunamadı"})
 		return
 	}
 
-	var input struct {
-		Quantity float64   `json:"quantity" binding:"required,gt=0"`
-		Date     time.Time `json:"date" binding:"required"`
+	location, err := resolveLocation(tx, input.LocationID)
+	if err != nil {
+		tx.Rollback()
+		respondLocationError(c, err)
+		return
 	}
 
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı"})
+	demands, err := recipeDemands(tx, recipe, input.Quantity, location.ID)
+	if err != nil {
+		tx.Rollback()
+		respondRecipeError(c, err)
+		return
+	}
+
+	production := models.Production{
+		RecipeID:       recipe.ID,
+		LocationID:     &location.ID,
+		Quantity:       input.Quantity,
+		ProductionDate: input.Date,
+		Note:           input.Note,
+	}
+	if err := tx.Omit(clause.Associations).Create(&production).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Üretim kaydedilemedi"})
+		return
+	}
+
+	usages, cost, err := inventory.Allocate(tx, inventory.ProductionRef(production.ID), demands, production.ProductionDate)
+	if err != nil {
+		tx.Rollback()
+		respondAllocationError(c, err)
+		return
+	}
+
+	production.CostOfGoods = cost
+	if err := tx.Model(&production).Update("cost_of_goods", cost).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Üretim maliyeti kaydedilemedi"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
 		return
 	}
 
-	// Geçici yanıt
-	c.JSON(http.StatusOK, gin.H{
-		"message":  "Üretim başarılı",
-		"recipe":   recipe,
-		"quantity": input.Quantity,
-		"date":     input.Date,
-	})
+	production.Recipe = &recipe
+	production.Usages = usages
+	c.JSON(http.StatusCreated, gin.H{"data": production})
 }
 
 func (h *RecipeHandler) DeleteRecipe(c *gin.Context) {
@@ -140,7 +214,7 @@ func (h *RecipeHandler) GetRecipe(c *gin.Context) {
 	}
 
 	var recipe models.Recipe
-	if err := h.db.Preload("RecipeItems.Product").First(&recipe, recipeID).Error; err != nil {
+	if err := h.db.Preload("RecipeItems.Product", withArchived).First(&recipe, recipeID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Reçete bulunamadı"})
 		return
 	}
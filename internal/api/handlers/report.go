@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"net/http"
+	"stock-api/internal/inventory"
+	"stock-api/internal/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ReportHandler struct {
+	db *gorm.DB
+}
+
+func NewReportHandler(db *gorm.DB) *ReportHandler {
+	return &ReportHandler{db: db}
+}
+
+// categoryTotals bir kategori için stok ve satış toplamlarıdır
+type categoryTotals struct {
+	ProductCount int64   `json:"productCount"`
+	StockValue   float64 `json:"stockValue"`
+	SaleCount    int64   `json:"saleCount"`
+	NetSales     float64 `json:"netSales"`
+	VatAmount    float64 `json:"vatAmount"`
+	TotalSales   float64 `json:"totalSales"`
+	CostOfGoods  float64 `json:"costOfGoods"`
+}
+
+func (t *categoryTotals) add(o categoryTotals) {
+	t.ProductCount += o.ProductCount
+	t.StockValue += o.StockValue
+	t.SaleCount += o.SaleCount
+	t.NetSales += o.NetSales
+	t.VatAmount += o.VatAmount
+	t.TotalSales += o.TotalSales
+	t.CostOfGoods += o.CostOfGoods
+}
+
+// parseDateRange startDate/endDate (2006-01-02) parametrelerini okur; bitiş günü dahildir
+func parseDateRange(c *gin.Context) (start, end *time.Time, ok bool) {
+	if s := c.Query("startDate"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz başlangıç tarihi"})
+			return nil, nil, false
+		}
+		start = &t
+	}
+	if s := c.Query("endDate"); s != "" {
+		t, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz bitiş tarihi"})
+			return nil, nil, false
+		}
+		t = t.AddDate(0, 0, 1)
+		end = &t
+	}
+	return start, end, true
+}
+
+// orderDiscountFactors satışlar arasındaki sipariş satırlarının siparişlerini yükler ve
+// her sipariş için iskonto çarpanını döner
+func orderDiscountFactors(db *gorm.DB, sales []models.Sale) (map[uint]float64, error) {
+	var orderIDs []uint
+	for _, sale := range sales {
+		if sale.SalesOrderID != nil {
+			orderIDs = append(orderIDs, *sale.SalesOrderID)
+		}
+	}
+
+	factors := make(map[uint]float64)
+	if len(orderIDs) == 0 {
+		return factors, nil
+	}
+	var orders []models.SalesOrder
+	if err := db.Preload("Lines").Where("id IN ?", orderIDs).Find(&orders).Error; err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		orders[i].CalculatePrices()
+		factors[orders[i].ID] = orders[i].DiscountFactor()
+	}
+	return factors, nil
+}
+
+// GetCategoryReport - stok değeri ve satışları kategori ağacında üst kategorilere toplar
+func (h *ReportHandler) GetCategoryReport(c *gin.Context) {
+	start, end, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	var categories []models.Category
+	query := h.db.Order("path asc")
+	if categoryID := c.Query("categoryId"); categoryID != "" {
+		subtree, err := categorySubtree(h.db, categoryID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kategori bulunamadı"})
+			return
+		}
+		query = query.Where("id IN (?)", subtree)
+	}
+	if err := query.Find(&categories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kategoriler alınamadı"})
+		return
+	}
+
+	// Kategorinin doğrudan kendi toplamları; 0 anahtarı kategorisizleri toplar
+	own := make(map[uint]categoryTotals)
+
+	var productRows []struct {
+		CategoryID   *uint
+		ProductCount int64
+	}
+	if err := h.db.Model(&models.Product{}).
+		Select("category_id, COUNT(*) AS product_count").
+		Group("category_id").
+		Scan(&productRows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün sayıları alınamadı"})
+		return
+	}
+	for _, row := range productRows {
+		key := uint(0)
+		if row.CategoryID != nil {
+			key = *row.CategoryID
+		}
+		t := own[key]
+		t.ProductCount += row.ProductCount
+		own[key] = t
+	}
+
+	// Stok değeri maliyet yöntemlerinden bağımsız olarak inventory paketinden gelir
+	values, err := inventory.Valuation(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok değerleri alınamadı"})
+		return
+	}
+	var products []models.Product
+	if err := h.db.Select("id", "category_id").Find(&products).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürünler alınamadı"})
+		return
+	}
+	for _, product := range products {
+		key := uint(0)
+		if product.CategoryID != nil {
+			key = *product.CategoryID
+		}
+		t := own[key]
+		t.StockValue += values[product.ID]
+		own[key] = t
+	}
+
+	// Satış tutarları CalculatePrices ile hesaplandığı için satışlar yüklenir
+	var sales []models.Sale
+	salesQuery := h.db.Preload("Product", withArchived).Preload("Recipe")
+	if start != nil {
+		salesQuery = salesQuery.Where("sale_date >= ?", *start)
+	}
+	if end != nil {
+		salesQuery = salesQuery.Where("sale_date < ?", *end)
+	}
+	if err := salesQuery.Find(&sales).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Satışlar alınamadı"})
+		return
+	}
+
+	// Sipariş iskontosu satırlara net tutarlarıyla orantılı dağıtılır
+	factors, err := orderDiscountFactors(h.db, sales)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Siparişler alınamadı"})
+		return
+	}
+	for _, sale := range sales {
+		var categoryID *uint
+		if sale.Recipe != nil {
+			categoryID = sale.Recipe.CategoryID
+		} else {
+			categoryID = sale.Product.CategoryID
+		}
+		key := uint(0)
+		if categoryID != nil {
+			key = *categoryID
+		}
+
+		// İade edilen kısım satış tutarından, iade edilen maliyet satılan malın
+		// maliyetinden düşülür
+		sale.CalculatePrices()
+		factor := sale.KeptFraction()
+		if sale.SalesOrderID != nil {
+			factor *= factors[*sale.SalesOrderID]
+		}
+		t := own[key]
+		t.SaleCount++
+		t.NetSales += sale.NetPrice * factor
+		t.VatAmount += sale.VatAmount * factor
+		t.TotalSales += sale.TotalPrice * factor
+		t.CostOfGoods += sale.CostOfGoods - sale.ReturnedCost
+		own[key] = t
+	}
+
+	// Her kategori kendi alt ağacındaki tüm toplamları taşır
+	var allCategories []models.Category
+	if err := h.db.Find(&allCategories).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Kategoriler alınamadı"})
+		return
+	}
+	fullNames := categoryFullNames(allCategories)
+
+	type categoryRow struct {
+		CategoryID uint           `json:"categoryId"`
+		ParentID   *uint          `json:"parentId"`
+		Name       string         `json:"name"`
+		FullName   string         `json:"fullName"`
+		Depth      int            `json:"depth"`
+		Own        categoryTotals `json:"own"`
+		Total      categoryTotals `json:"total"`
+	}
+
+	rows := make([]categoryRow, 0, len(categories))
+	for _, category := range categories {
+		var total categoryTotals
+		for _, other := range allCategories {
+			if strings.HasPrefix(other.Path, category.Path) {
+				total.add(own[other.ID])
+			}
+		}
+
+		rows = append(rows, categoryRow{
+			CategoryID: category.ID,
+			ParentID:   category.ParentID,
+			Name:       category.Name,
+			FullName:   fullNames[category.ID],
+			Depth:      strings.Count(category.Path, "/") - 1,
+			Own:        own[category.ID],
+			Total:      total,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"categories":    rows,
+		"uncategorized": own[0],
+	}})
+}
+
+// productBalance bir ürünün geçmiş bir andaki stok miktarı, değeri ve partileridir
+type productBalance struct {
+	ProductID   uint                   `json:"productId"`
+	ProductName string                 `json:"productName"`
+	Unit        string                 `json:"unit"`
+	Quantity    float64                `json:"quantity"`
+	Value       float64                `json:"value"`
+	Lots        []inventory.LotBalance `json:"lots"`
+}
+
+// GetInventoryAsOf - verilen tarihteki (2006-01-02 ise gün sonundaki, RFC3339 ise o andaki)
+// eldeki stoğu ürün ve parti bazında miktar ve parti maliyetiyle değerler. O anda yolda
+// olan transfer partileri ayrıca listelenir; locationId verilirse stok o konumdan,
+// yoldaki stok o konuma gelen transferlerden alınır.
+func (h *ReportHandler) GetInventoryAsOf(c *gin.Context) {
+	value := c.Query("date")
+	if value == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tarih gereklidir"})
+		return
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		day, dayErr := time.Parse("2006-01-02", value)
+		if dayErr != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz tarih"})
+			return
+		}
+		at = day.AddDate(0, 0, 1)
+	}
+
+	locationID, ok := queryLocationID(c)
+	if !ok {
+		return
+	}
+
+	// Arşivlenmiş ürünlerin geçmişteki stoğu da raporlanır
+	var products []models.Product
+	query := h.db.Unscoped().Order("product_name asc")
+	if productID := c.Query("productId"); productID != "" {
+		query = query.Where("id = ?", productID)
+	}
+	if categoryID := c.Query("categoryId"); categoryID != "" {
+		subtree, err := categorySubtree(h.db, categoryID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kategori bulunamadı"})
+			return
+		}
+		query = query.Where("category_id IN (?)", subtree)
+	}
+	if err := query.Find(&products).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürünler alınamadı"})
+		return
+	}
+
+	balances, err := inventory.BalancesAt(h.db, at, locationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Geçmiş stok hesaplanamadı"})
+		return
+	}
+	transits, err := inventory.InTransitAt(h.db, at)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Yoldaki stok hesaplanamadı"})
+		return
+	}
+
+	byProduct := make(map[uint]*productBalance, len(products))
+	for _, product := range products {
+		byProduct[product.ID] = &productBalance{
+			ProductID:   product.ID,
+			ProductName: product.ProductName,
+			Unit:        product.Unit,
+			Lots:        make([]inventory.LotBalance, 0),
+		}
+	}
+
+	var totalValue float64
+	for _, balance := range balances {
+		product, ok := byProduct[balance.ProductID]
+		if !ok {
+			continue
+		}
+		product.Quantity += balance.Quantity
+		product.Value += balance.Value
+		product.Lots = append(product.Lots, balance)
+		totalValue += balance.Value
+	}
+
+	inTransit := make([]inventory.TransitBalance, 0)
+	var inTransitValue float64
+	for _, transit := range transits {
+		if _, ok := byProduct[transit.ProductID]; !ok {
+			continue
+		}
+		if locationID != 0 && transit.ToLocationID != locationID {
+			continue
+		}
+		inTransit = append(inTransit, transit)
+		inTransitValue += transit.Value
+	}
+
+	result := make([]productBalance, 0)
+	for _, product := range products {
+		if balance := byProduct[product.ID]; len(balance.Lots) > 0 {
+			result = append(result, *balance)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"date":           value,
+		"asOf":           at,
+		"locationId":     locationID,
+		"products":       result,
+		"totalValue":     totalValue,
+		"inTransit":      inTransit,
+		"inTransitValue": inTransitValue,
+	}})
+}
+
+// GetInventoryJournal - envanter defterindeki kayıtları gerçekleşme sırasıyla listeler.
+// productId, locationId, stockMovementId, type ve tarih aralığıyla filtrelenebilir.
+func (h *ReportHandler) GetInventoryJournal(c *gin.Context) {
+	start, end, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	var entries []models.InventoryEntry
+	query := h.db.Order("occurred_at asc, id asc")
+
+	if productID := c.Query("productId"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+	if locationID := c.Query("locationId"); locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+	if movementID := c.Query("stockMovementId"); movementID != "" {
+		query = query.Where("stock_movement_id = ?", movementID)
+	}
+	if entryType := c.Query("type"); entryType != "" {
+		query = query.Where("type = ?", entryType)
+	}
+	if start != nil {
+		query = query.Where("occurred_at >= ?", *start)
+	}
+	if end != nil {
+		query = query.Where("occurred_at < ?", *end)
+	}
+
+	if err := query.Find(&entries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Envanter kayıtları listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": entries})
+}
@@ -0,0 +1,223 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"stock-api/internal/inventory"
+	"stock-api/internal/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errReservationUnavailable = errors.New("rezervasyon kullanılamaz")
+
+type ReservationHandler struct {
+	db *gorm.DB
+}
+
+func NewReservationHandler(db *gorm.DB) *ReservationHandler {
+	return &ReservationHandler{db: db}
+}
+
+// convertReservation satışın bağlı olduğu rezervasyonu satışa dönüştürür. Rezervasyon
+// etkin olmalı, satışla aynı ürün ve konuma ait olmalıdır; dönüştürülen rezervasyonun
+// ayırdığı stok satışın düşümünde kullanılabilir hale gelir.
+func convertReservation(tx *gorm.DB, sale *models.Sale) error {
+	var reservation models.Reservation
+	if err := tx.First(&reservation, *sale.ReservationID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: rezervasyon bulunamadı", errReservationUnavailable)
+		}
+		return err
+	}
+	if !reservation.IsActive(time.Now()) {
+		return fmt.Errorf("%w: rezervasyon etkin değil", errReservationUnavailable)
+	}
+	if reservation.ProductID != sale.ProductID ||
+		reservation.LocationID == nil || sale.LocationID == nil || *reservation.LocationID != *sale.LocationID {
+		return fmt.Errorf("%w: rezervasyon satışın ürün ve konumuna ait değil", errReservationUnavailable)
+	}
+
+	now := time.Now()
+	return tx.Model(&reservation).Updates(map[string]interface{}{
+		"status":      models.ReservationConverted,
+		"sale_id":     sale.ID,
+		"released_at": now,
+	}).Error
+}
+
+// respondReservationError rezervasyon dönüştürme hatasını yanıta yazar
+func respondReservationError(c *gin.Context, err error) {
+	if errors.Is(err, errReservationUnavailable) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Rezervasyon güncellenemedi"})
+}
+
+// CreateReservation - konumdaki satılabilir stoktan verilen süreye kadar miktar ayırır
+func (h *ReservationHandler) CreateReservation(c *gin.Context) {
+	var reservation models.Reservation
+	if err := c.ShouldBindJSON(&reservation); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+	reservation.ID = 0
+	reservation.Status = models.ReservationActive
+	reservation.SaleID = nil
+	reservation.ReleasedAt = nil
+
+	now := time.Now()
+	if !reservation.ExpiresAt.After(now) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Rezervasyon bitişi gelecekte olmalıdır"})
+		return
+	}
+
+	// Transaction başlat
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	var product models.Product
+	if err := tx.First(&product, reservation.ProductID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ürün bulunamadı"})
+		return
+	}
+
+	location, err := resolveLocation(tx, reservation.LocationID)
+	if err != nil {
+		tx.Rollback()
+		respondLocationError(c, err)
+		return
+	}
+	reservation.LocationID = &location.ID
+
+	// Rezervasyon miktarını ürün birimine çevir
+	factor, err := unitFactor(tx, product, reservation.Unit)
+	if errors.Is(err, errUnitConversion) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Birim dönüşümü yapılamadı"})
+		return
+	}
+	if reservation.Unit == "" {
+		reservation.Unit = product.Unit
+	}
+	reservation.StockQuantity = reservation.Quantity * factor
+
+	// Başka rezervasyonlarca ayrılmış stok yeniden ayrılamaz
+	free, err := inventory.Unreserved(tx, product.ID, location.ID, now)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok miktarı alınamadı"})
+		return
+	}
+	if free < reservation.StockQuantity {
+		tx.Rollback()
+		respondAllocationError(c, &inventory.ShortageError{
+			ProductID:   product.ID,
+			ProductName: product.ProductName,
+			Requested:   reservation.StockQuantity,
+			Available:   free,
+		})
+		return
+	}
+
+	if err := tx.Omit("Product").Create(&reservation).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Rezervasyon oluşturma hatası: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rezervasyon kaydedilemedi"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	reservation.Product = &product
+	c.JSON(http.StatusCreated, gin.H{"data": reservation})
+}
+
+// GetReservations - rezervasyonları bitiş tarihine göre listeler; süresi dolanlar önce
+// serbest bırakılır. productId, status ve locationId ile filtrelenebilir.
+func (h *ReservationHandler) GetReservations(c *gin.Context) {
+	locationID, ok := queryLocationID(c)
+	if !ok {
+		return
+	}
+
+	if _, err := inventory.ExpireReservations(h.db, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Süresi dolan rezervasyonlar güncellenemedi"})
+		return
+	}
+
+	var reservations []models.Reservation
+	query := inventory.AtLocation(h.db.Preload("Product", withArchived), locationID).Order("expires_at asc")
+	if productID := c.Query("productId"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	if err := query.Find(&reservations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rezervasyonlar listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reservations})
+}
+
+// GetReservation - ID ile rezervasyon getirme
+func (h *ReservationHandler) GetReservation(c *gin.Context) {
+	if _, err := inventory.ExpireReservations(h.db, time.Now()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Süresi dolan rezervasyonlar güncellenemedi"})
+		return
+	}
+
+	var reservation models.Reservation
+	if err := h.db.Preload("Product", withArchived).First(&reservation, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rezervasyon bulunamadı"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reservation})
+}
+
+// ReleaseReservation - etkin rezervasyonu satışa dönüştürmeden serbest bırakır
+func (h *ReservationHandler) ReleaseReservation(c *gin.Context) {
+	var reservation models.Reservation
+	if err := h.db.First(&reservation, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Rezervasyon bulunamadı"})
+		return
+	}
+
+	now := time.Now()
+	if !reservation.IsActive(now) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Rezervasyon etkin değil"})
+		return
+	}
+
+	reservation.Status = models.ReservationReleased
+	reservation.ReleasedAt = &now
+	if err := h.db.Model(&reservation).
+		Select("status", "released_at").
+		Updates(&reservation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rezervasyon serbest bırakılamadı"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": reservation})
+}
@@ -2,18 +2,25 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
-	"math"
 	"net/http"
+	"stock-api/internal/database"
+	"stock-api/internal/inventory"
 	"stock-api/internal/models"
+	"strconv"
 	"time"
 
 	"log"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var errRecipeUnavailable = errors.New("reçete satılamaz")
+
 type SaleHandler struct {
 	db *gorm.DB
 }
@@ -23,7 +30,7 @@ type RecipeSaleInput struct {
 	Quantity  float64   `json:"quantity" binding:"required,gt=0"`
 	SaleDate  time.Time `json:"saleDate" binding:"required"`
 	SalePrice float64   `json:"salePrice" binding:"required,gt=0"`
-	UnitCost  float64   `json:"unitCost" binding:"required,gte=0"`
+	UnitCost  float64   `json:"unitCost" binding:"omitempty,gte=0"`
 	Note      string    `json:"note"`
 	Discount  float64   `json:"discount"`
 	VAT       float64   `json:"vat"`
@@ -33,23 +40,93 @@ func NewSaleHandler(db *gorm.DB) *SaleHandler {
 	return &SaleHandler{db: db}
 }
 
+// respondAllocationError stok düşümü hatasını döner; stok ve parti hataları 400'dür.
+// Veritabanı kilitliyse ya da partiler eşzamanlı tüketildiyse istemcinin tekrar
+// denemesi için 503 döner. Stok değiştiren transaction'lar açılamadığında ya da
+// kaydedilemediğinde de kullanılır.
+func respondAllocationError(c *gin.Context, err error) {
+	if errors.Is(err, inventory.ErrInsufficientStock) ||
+		errors.Is(err, inventory.ErrLotRequired) ||
+		errors.Is(err, inventory.ErrLotUnavailable) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if database.IsBusy(err) || errors.Is(err, inventory.ErrConcurrentUpdate) {
+		log.Printf("Eşzamanlı stok düşümü: %v", err)
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Stok şu anda güncelleniyor, lütfen tekrar deneyin"})
+		return
+	}
+	log.Printf("Stok düşümü hatası: %v", err)
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok düşülemedi"})
+}
+
+// recipeDemands reçetenin verilen miktarda satışı için kalemlerin ürün birimindeki
+// düşüm taleplerini hazırlar. Arşivlenmiş ürün içeren reçete satılamaz.
+func recipeDemands(tx *gorm.DB, recipe models.Recipe, quantity float64, locationID uint) ([]inventory.Demand, error) {
+	demands := make([]inventory.Demand, len(recipe.RecipeItems))
+	for i, item := range recipe.RecipeItems {
+		if item.Product == nil {
+			return nil, fmt.Errorf("%w: reçetedeki %d ID'li ürün arşivlenmiş", errRecipeUnavailable, item.ProductID)
+		}
+
+		factor, err := unitFactor(tx, *item.Product, item.Unit)
+		if err != nil {
+			return nil, err
+		}
+		demands[i] = inventory.Demand{ProductID: item.ProductID, Quantity: item.Quantity * factor * quantity, LocationID: locationID}
+	}
+	return demands, nil
+}
+
+// respondRecipeError reçete kalemlerinin hazırlanma hatasını yanıta yazar
+func respondRecipeError(c *gin.Context, err error) {
+	if errors.Is(err, errRecipeUnavailable) || errors.Is(err, errUnitConversion) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Birim dönüşümü yapılamadı"})
+}
+
 func (h *SaleHandler) CreateSale(c *gin.Context) {
 	var sale models.Sale
 	if err := c.ShouldBindJSON(&sale); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı"})
 		return
 	}
+	// İade toplamları yalnızca iade belgeleriyle değişir; sipariş satırları yalnızca
+	// siparişle oluşturulur
+	sale.ReturnedQuantity = 0
+	sale.ReturnedCost = 0
+	sale.SalesOrderID = nil
+
+	// Barkodla satışta ürün ve (koli barkoduysa) satış birimi barkoddan gelir
+	if sale.ProductID == 0 && sale.Barcode != "" {
+		product, barcode, err := findProductByBarcode(h.db, sale.Barcode)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Barkoda ait ürün bulunamadı"})
+			return
+		}
+		sale.ProductID = product.ID
+		if sale.Unit == "" {
+			sale.Unit = barcode.UnitCode
+		}
+	}
 
-	// Validasyonlar
+	// Validasyonlar; müşteri ID ile ya da ad ve telefonla verilir
 	if sale.ProductID == 0 || sale.Quantity <= 0 || sale.SalePrice < 0 ||
-		sale.CustomerName == "" || sale.CustomerPhone == "" ||
+		(sale.CustomerID == nil && (sale.CustomerName == "" || sale.CustomerPhone == "")) ||
 		sale.UnitCost < 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz değerler"})
 		return
 	}
 
-	// Transaction başlat
+	// Transaction başlat; yazma kilidi alınamazsa istemci tekrar dener
 	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
 
 	// Önce ürünü kontrol et
 	var product models.Product
@@ -59,47 +136,40 @@ func (h *SaleHandler) CreateSale(c *gin.Context) {
 		return
 	}
 
-	// FIFO için stok hareketlerini al
-	var movements []models.StockMovement
-	query := tx.Debug().
-		Raw(`
-			SELECT sm.* 
-			FROM stock_movements sm
-			JOIN products p1 ON sm.product_id = p1.id
-			JOIN products p2 ON p1.product_name = p2.product_name
-			WHERE p2.id = ?
-			AND sm.remaining_quantity > 0
-			ORDER BY sm.movement_date ASC
-		`, sale.ProductID)
-
-	log.Printf("SQL Sorgusu: %v", query.Statement.SQL.String())
-	log.Printf("Parametreler: %v", query.Statement.Vars)
-
-	if err := query.Scan(&movements).Error; err != nil {
+	// Satış birimindeki miktarı ürün birimine çevir (FIFO ürün birimiyle çalışır)
+	factor, err := unitFactor(tx, product, sale.Unit)
+	if errors.Is(err, errUnitConversion) {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketleri alınamadı"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Debug için stok hareketlerini logla
-	log.Printf("Bulunan stok hareketleri: %+v", movements)
-	log.Printf("Toplam hareket sayısı: %d", len(movements))
-
-	// Toplam stok kontrolü
-	var totalStock float64
-	for _, m := range movements {
-		totalStock += m.RemainingQuantity
-		log.Printf("Hareket ID: %d, Product ID: %d, Kalan miktar: %f, Ürün: %s",
-			m.ID, m.ProductID, m.RemainingQuantity, product.ProductName)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Birim dönüşümü yapılamadı"})
+		return
+	}
+	if sale.Unit == "" {
+		sale.Unit = product.Unit
 	}
+	sale.StockQuantity = sale.Quantity * factor
 
-	log.Printf("Toplam stok: %f, İstenen miktar: %f", totalStock, sale.Quantity)
+	// Stok satışın yapıldığı konumdan (verilmemişse varsayılan konumdan) düşülür
+	location, err := resolveLocation(tx, sale.LocationID)
+	if err != nil {
+		tx.Rollback()
+		respondLocationError(c, err)
+		return
+	}
+	sale.LocationID = &location.ID
 
-	if totalStock < sale.Quantity {
+	// Satış müşteri kaydına bağlanır; telefonla eşleşen müşteri yoksa oluşturulur
+	customer, err := resolveCustomer(tx, sale.CustomerID, sale.CustomerName, sale.CustomerPhone)
+	if err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Yetersiz stok"})
+		respondCustomerError(c, err)
 		return
 	}
+	assignCustomer(&sale, customer)
 
 	// Satışı kaydet
 	if err := tx.Create(&sale).Error; err != nil {
@@ -110,8 +180,7 @@ func (h *SaleHandler) CreateSale(c *gin.Context) {
 
 	// Satış ve ürün detaylarını yükle
 	var completeSale models.Sale
-	if err := tx.Debug().
-		Model(&models.Sale{}).
+	if err := tx.Model(&models.Sale{}).
 		Where("id = ?", sale.ID).
 		First(&completeSale).Error; err != nil {
 		tx.Rollback()
@@ -122,68 +191,41 @@ func (h *SaleHandler) CreateSale(c *gin.Context) {
 	// Product bilgilerini set et
 	completeSale.Product = product
 
-	// Debug için satış detaylarını logla
-	log.Printf("Ürün: %+v", product)
-	log.Printf("Satış ID: %d", completeSale.ID)
-	log.Printf("Ürün ID: %d", completeSale.ProductID)
-	log.Printf("Product: %+v", completeSale.Product)
-	log.Printf("Satış detayları: %+v", completeSale)
-
-	// FIFO mantığına göre stok düşümü
-	remaining := sale.Quantity
-	var stockUsages []models.StockUsage
-
-	for _, m := range movements {
-		if remaining <= 0 {
-			break
-		}
-
-		use := math.Min(remaining, m.RemainingQuantity)
-
-		// Stok kullanımını kaydet
-		usage := models.StockUsage{
-			SaleID:          sale.ID,
-			StockMovementID: m.ID,
-			UsedQuantity:    use,
-		}
-
-		if err := tx.Create(&usage).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok kullanımı kaydedilemedi"})
-			return
-		}
-
-		// Stok kullanımını tam olarak yükle
-		if err := tx.First(&usage, usage.ID).Error; err != nil {
+	// Rezervasyondan yapılan satışta ayrılan stok satışa açılır
+	if sale.ReservationID != nil {
+		if err := convertReservation(tx, &sale); err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok kullanımı detayları alınamadı"})
-			return
-		}
-
-		stockUsages = append(stockUsages, usage)
-
-		// Stok hareketini güncelle
-		if err := tx.Model(&models.StockMovement{}).
-			Where("id = ?", m.ID).
-			Update("remaining_quantity", gorm.Expr("remaining_quantity - ?", use)).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketi güncellenemedi"})
+			respondReservationError(c, err)
 			return
 		}
+	}
 
-		// Ürün stoğunu güncelle
-		if err := tx.Model(&models.Product{}).
-			Where("id = ?", m.ProductID).
-			Update("current_stock", gorm.Expr("current_stock - ?", use)).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün stoğu güncellenemedi"})
-			return
-		}
+	// Stoğu ürünün maliyet yöntemiyle partilerden düş (süresi geçmişler hariç)
+	demands := []inventory.Demand{{ProductID: product.ID, Quantity: sale.StockQuantity, LotID: sale.LotID, LocationID: location.ID}}
+	_, cost, err := inventory.Allocate(tx, inventory.SaleRef(sale.ID), demands, sale.SaleDate)
+	if err != nil {
+		tx.Rollback()
+		respondAllocationError(c, err)
+		return
+	}
 
-		remaining -= use
+	// Satılan malın maliyetini tüketilen partilerden kaydet; istemcinin gönderdiği
+	// birim maliyet yerine satış birimi başına gerçek maliyet yazılır
+	completeSale.CostOfGoods = cost
+	completeSale.UnitCost = cost / sale.Quantity
+	if err := tx.Model(&models.Sale{}).Where("id = ?", sale.ID).Updates(map[string]interface{}{
+		"cost_of_goods": completeSale.CostOfGoods,
+		"unit_cost":     completeSale.UnitCost,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Satış maliyeti kaydedilemedi"})
+		return
 	}
 
-	tx.Commit()
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
 
 	// Fiyatları hesapla
 	completeSale.CalculatePrices()
@@ -193,28 +235,103 @@ func (h *SaleHandler) CreateSale(c *gin.Context) {
 	c.Writer.WriteHeader(http.StatusCreated)
 }
 
+// presentSale reçete satışında ürün yerine reçete adını gösterir ve fiyatları hesaplar
+func presentSale(sale *models.Sale) {
+	if sale.RecipeID != nil && sale.Recipe != nil {
+		sale.Product = models.Product{
+			CategoryID:  sale.Recipe.CategoryID,
+			Category:    sale.Recipe.Category,
+			ProductName: "Reçete: " + sale.Recipe.Name,
+		}
+	}
+	sale.CalculatePrices()
+}
+
+// withSaleDetails satışların ürün ve reçetelerini kategorileriyle birlikte yükler
+func withSaleDetails(query *gorm.DB) *gorm.DB {
+	return query.Preload("Product", withArchived).
+		Preload("Product.Category").
+		Preload("Recipe").
+		Preload("Recipe.Category")
+}
+
+// groupSalesByOrder sipariş satırlarını ayırır ve bu satırların siparişlerini tüm
+// satırlarıyla yükler; siparişe ait olmayan satışlar ayrıca döner
+func groupSalesByOrder(db *gorm.DB, sales []models.Sale) ([]models.Sale, []models.SalesOrder, error) {
+	standalone := []models.Sale{}
+	var orderIDs []uint
+	seen := make(map[uint]bool)
+	for _, sale := range sales {
+		if sale.SalesOrderID == nil {
+			standalone = append(standalone, sale)
+			continue
+		}
+		if !seen[*sale.SalesOrderID] {
+			seen[*sale.SalesOrderID] = true
+			orderIDs = append(orderIDs, *sale.SalesOrderID)
+		}
+	}
+
+	orders := []models.SalesOrder{}
+	if len(orderIDs) > 0 {
+		if err := withOrderLines(db).Where("id IN ?", orderIDs).Order("order_date desc, id desc").Find(&orders).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	for i := range orders {
+		presentOrder(&orders[i])
+	}
+	return standalone, orders, nil
+}
+
+// GetSales - satışları listeler; categoryId ve locationId ile filtrelenebilir.
+// groupBy=order verilirse sipariş satırları "orders" altında siparişleriyle döner.
 func (h *SaleHandler) GetSales(c *gin.Context) {
 	var sales []models.Sale
 
+	query := withSaleDetails(h.db)
+
+	// Kategoriye göre filtrele (alt kategoriler dahil)
+	if categoryID := c.Query("categoryId"); categoryID != "" {
+		subtree, err := categorySubtree(h.db, categoryID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kategori bulunamadı"})
+			return
+		}
+		products := h.db.Unscoped().Model(&models.Product{}).Select("id").Where("category_id IN (?)", subtree)
+		recipes := h.db.Model(&models.Recipe{}).Select("id").Where("category_id IN (?)", subtree)
+		query = query.Where("(recipe_id IS NULL AND product_id IN (?)) OR recipe_id IN (?)", products, recipes)
+	}
+
+	// Konuma göre filtrele
+	if locationID := c.Query("locationId"); locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+
 	// Tüm satışları yükle
-	if err := h.db.Preload("Product").
-		Preload("Recipe").
-		Find(&sales).Error; err != nil {
+	if err := query.Find(&sales).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Satışlar listelenemedi"})
 		return
 	}
 
 	// Her satış için düzenleme yap
 	for i := range sales {
-		// Eğer bu bir reçete satışı ise
-		if sales[i].RecipeID != nil && sales[i].Recipe != nil {
-			// Ürün yerine reçete adını göster
-			sales[i].Product = models.Product{
-				Category:    "Reçete",
-				ProductName: "Reçete: " + sales[i].Recipe.Name,
-			}
+		presentSale(&sales[i])
+	}
+
+	// groupBy=order ile sipariş satırları siparişleriyle birlikte ayrıca döner
+	if c.Query("groupBy") == "order" {
+		standalone, orders, err := groupSalesByOrder(h.db, sales)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Siparişler listelenemedi"})
+			return
 		}
-		sales[i].CalculatePrices()
+
+		c.Set("response", gin.H{
+			"sales":  standalone,
+			"orders": orders,
+		})
+		return
 	}
 
 	// Response'u middleware'e bırak
@@ -244,13 +361,18 @@ func (h *SaleHandler) CreateRecipeSale(c *gin.Context) {
 
 	log.Printf("Reçete satışı verisi: %+v", recipeSale)
 
-	// Transaction başlat
+	// Transaction başlat; yazma kilidi alınamazsa istemci tekrar dener
 	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
 
 	// Reçeteyi getir
 	var recipe models.Recipe
 	if err := tx.Preload("RecipeItems").
 		Preload("RecipeItems.Product").
+		Preload("Category").
 		First(&recipe, recipeSale.RecipeID).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusNotFound, gin.H{"error": "Reçete bulunamadı"})
@@ -259,148 +381,83 @@ func (h *SaleHandler) CreateRecipeSale(c *gin.Context) {
 
 	log.Printf("Bulunan reçete: %+v", recipe)
 
-	// Stok kontrolü yap
-	for _, item := range recipe.RecipeItems {
-		itemQuantity := item.Quantity * recipeSale.Quantity
-
-		// FIFO için stok hareketlerini al
-		var movements []models.StockMovement
-		query := tx.Debug().
-			Raw(`
-				SELECT sm.* 
-				FROM stock_movements sm
-				JOIN products p1 ON sm.product_id = p1.id
-				JOIN products p2 ON p1.product_name = p2.product_name
-				WHERE p2.id = ?
-				AND sm.remaining_quantity > 0
-				ORDER BY sm.movement_date ASC
-			`, item.ProductID)
-
-		log.Printf("SQL Sorgusu: %v", query.Statement.SQL.String())
-		log.Printf("Parametreler: %v", query.Statement.Vars)
-
-		if err := query.Scan(&movements).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketleri alınamadı"})
-			return
-		}
-
-		// Debug için stok hareketlerini logla
-		log.Printf("Bulunan stok hareketleri: %+v", movements)
-		log.Printf("Toplam hareket sayısı: %d", len(movements))
-
-		// Toplam stok kontrolü
-		var totalStock float64
-		for _, m := range movements {
-			totalStock += m.RemainingQuantity
-			log.Printf("Hareket ID: %d, Kalan miktar: %f", m.ID, m.RemainingQuantity)
-		}
+	// Malzemeler satışın yapıldığı konumdan (verilmemişse varsayılan konumdan) düşülür
+	location, err := resolveLocation(tx, recipeSale.LocationID)
+	if err != nil {
+		tx.Rollback()
+		respondLocationError(c, err)
+		return
+	}
 
-		log.Printf("Toplam stok: %f, İstenen miktar: %f", totalStock, itemQuantity)
+	// Kalem miktarlarını ürün birimine çevir
+	demands, err := recipeDemands(tx, recipe, recipeSale.Quantity, location.ID)
+	if err != nil {
+		tx.Rollback()
+		respondRecipeError(c, err)
+		return
+	}
 
-		if totalStock < itemQuantity {
-			tx.Rollback()
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Yetersiz stok: " + item.Product.ProductName})
-			return
-		}
+	// Müşteri verilmişse satış müşteri kaydına bağlanır
+	customer, err := resolveCustomer(tx, recipeSale.CustomerID, recipeSale.CustomerName, recipeSale.CustomerPhone)
+	if err != nil {
+		tx.Rollback()
+		respondCustomerError(c, err)
+		return
 	}
 
 	// Tek bir satış kaydı oluştur
 	sale := models.Sale{
-		RecipeID:  &recipe.ID,
-		Quantity:  recipeSale.Quantity,
-		SaleDate:  recipeSale.SaleDate,
-		SalePrice: recipeSale.SalePrice,
-		UnitCost:  recipeSale.UnitCost,
-		Note:      recipeSale.Note,
-		Discount:  recipeSale.Discount,
-		VAT:       recipeSale.VAT,
+		RecipeID:   &recipe.ID,
+		LocationID: &location.ID,
+		Quantity:   recipeSale.Quantity,
+		SaleDate:   recipeSale.SaleDate,
+		SalePrice:  recipeSale.SalePrice,
+		Note:       recipeSale.Note,
+		Discount:   recipeSale.Discount,
+		VAT:        recipeSale.VAT,
 		Product: models.Product{ // Reçete bilgilerini Product'a ekle
-			Category:    "Reçete",
+			CategoryID:  recipe.CategoryID,
+			Category:    recipe.Category,
 			ProductName: "Reçete: " + recipe.Name,
 		},
 	}
 
-	// Satışı kaydet
-	if err := tx.Create(&sale).Error; err != nil {
+	assignCustomer(&sale, customer)
+
+	// Satışı kaydet (gösterim amaçlı Product katalog kaydı olarak oluşturulmaz)
+	if err := tx.Omit(clause.Associations).Create(&sale).Error; err != nil {
 		tx.Rollback()
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Satış kaydedilemedi"})
 		return
 	}
 
-	// Stok düşümlerini yap
-	var allStockUsages []models.StockUsage
-	for _, item := range recipe.RecipeItems {
-		itemQuantity := item.Quantity * recipeSale.Quantity
-
-		// FIFO için stok hareketlerini al
-		var movements []models.StockMovement
-		query := tx.Debug().
-			Raw(`
-				SELECT sm.* 
-				FROM stock_movements sm
-				JOIN products p1 ON sm.product_id = p1.id
-				JOIN products p2 ON p1.product_name = p2.product_name
-				WHERE p2.id = ?
-				AND sm.remaining_quantity > 0
-				ORDER BY sm.movement_date ASC
-			`, item.ProductID)
-
-		if err := query.Scan(&movements).Error; err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketleri alınamadı"})
-			return
-		}
-
-		// FIFO mantığına göre stok düşümü
-		remaining := itemQuantity
-		for _, m := range movements {
-			if remaining <= 0 {
-				break
-			}
-
-			use := math.Min(remaining, m.RemainingQuantity)
-
-			// Stok kullanımını kaydet
-			usage := models.StockUsage{
-				SaleID:          sale.ID,
-				StockMovementID: m.ID,
-				UsedQuantity:    use,
-			}
-
-			if err := tx.Create(&usage).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok kullanımı kaydedilemedi"})
-				return
-			}
-
-			allStockUsages = append(allStockUsages, usage)
-
-			// Stok hareketini güncelle
-			if err := tx.Model(&models.StockMovement{}).
-				Where("id = ?", m.ID).
-				Update("remaining_quantity", gorm.Expr("remaining_quantity - ?", use)).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketi güncellenemedi"})
-				return
-			}
-
-			// Ürün stoğunu güncelle
-			if err := tx.Model(&models.Product{}).
-				Where("id = ?", m.ProductID).
-				Update("current_stock", gorm.Expr("current_stock - ?", use)).Error; err != nil {
-				tx.Rollback()
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün stoğu güncellenemedi"})
-				return
-			}
+	// Aynı ürünü kullanan kalemler toplanarak partilerden düşülür
+	allStockUsages, cost, err := inventory.Allocate(tx, inventory.SaleRef(sale.ID), demands, recipeSale.SaleDate)
+	if err != nil {
+		tx.Rollback()
+		respondAllocationError(c, err)
+		return
+	}
 
-			remaining -= use
-		}
+	// Satılan malın maliyeti malzemelerin tüketilen partilerindeki maliyetlerinin toplamıdır
+	sale.CostOfGoods = cost
+	sale.UnitCost = cost / sale.Quantity
+	if err := tx.Model(&models.Sale{}).Where("id = ?", sale.ID).Updates(map[string]interface{}{
+		"cost_of_goods": sale.CostOfGoods,
+		"unit_cost":     sale.UnitCost,
+	}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Satış maliyeti kaydedilemedi"})
+		return
 	}
 
-	tx.Commit()
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
 
 	// Response'u hazırla
+	sale.CalculatePrices()
 	c.Set("response", gin.H{
 		"sale":        sale,
 		"recipe":      recipe,
@@ -414,6 +471,10 @@ func (h *SaleHandler) DeleteSale(c *gin.Context) {
 
 	// Transaction başlat
 	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
 
 	// Satışı bul
 	var sale models.Sale
@@ -423,66 +484,424 @@ func (h *SaleHandler) DeleteSale(c *gin.Context) {
 		return
 	}
 
-	// Stok kullanımlarını bul ve tarihe göre sırala
-	var usages []struct {
-		models.StockUsage
-		MovementDate time.Time
+	// Sipariş satırları sipariş toplamlarını bozmamak için siparişle birlikte silinir
+	if sale.SalesOrderID != nil {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Sipariş satırı tek başına silinemez; siparişi silin"})
+		return
+	}
+
+	// İade belgesi kesilmiş satış silinemez
+	returned, err := hasReturns(tx, sale.ID)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "İadeler kontrol edilemedi"})
+		return
+	}
+	if returned {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "İadesi olan satış silinemez"})
+		return
+	}
+
+	// Stok kullanımlarını partilere iade et
+	if err := inventory.Release(tx, inventory.SaleRef(sale.ID)); err != nil {
+		log.Printf("Stok iadesi hatası: %v", err)
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok iade edilemedi"})
+		return
+	}
+
+	// Satışı sil
+	if err := tx.Delete(&sale).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Satış silinemedi"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Satış başarıyla silindi"})
+}
+
+// saleRevisionChanges satışın düzeltme öncesi ve sonrası hallerini karşılaştırır ve
+// değişen alanları döner
+func saleRevisionChanges(before, after models.Sale) []models.SaleRevisionChange {
+	formatFloat := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	formatID := func(id *uint) string {
+		if id == nil {
+			return ""
+		}
+		return strconv.FormatUint(uint64(*id), 10)
+	}
+
+	fields := []struct {
+		name          string
+		before, after string
+	}{
+		{"quantity", formatFloat(before.Quantity), formatFloat(after.Quantity)},
+		{"stockQuantity", formatFloat(before.StockQuantity), formatFloat(after.StockQuantity)},
+		{"lotId", formatID(before.LotID), formatID(after.LotID)},
+		{"salePrice", formatFloat(before.SalePrice), formatFloat(after.SalePrice)},
+		{"discount", formatFloat(before.Discount), formatFloat(after.Discount)},
+		{"vat", formatFloat(before.VAT), formatFloat(after.VAT)},
+		{"customerId", formatID(before.CustomerID), formatID(after.CustomerID)},
+		{"customerName", before.CustomerName, after.CustomerName},
+		{"customerPhone", before.CustomerPhone, after.CustomerPhone},
+		{"saleDate", before.SaleDate.Format(time.RFC3339), after.SaleDate.Format(time.RFC3339)},
+		{"note", before.Note, after.Note},
+		{"unitCost", formatFloat(before.UnitCost), formatFloat(after.UnitCost)},
+		{"costOfGoods", formatFloat(before.CostOfGoods), formatFloat(after.CostOfGoods)},
+	}
+
+	var changes []models.SaleRevisionChange
+	for _, field := range fields {
+		if field.before != field.after {
+			changes = append(changes, models.SaleRevisionChange{
+				Field:    field.name,
+				OldValue: field.before,
+				NewValue: field.after,
+			})
+		}
+	}
+	return changes
+}
+
+// saleLot satışta seçilen alış partisini döner. Parti saklanmamış eski satışlarda,
+// ürün belirli parti yöntemindeyse satışın en son tükettiği parti kullanılır; diğer
+// yöntemlerde parti seçilmez.
+func saleLot(tx *gorm.DB, sale models.Sale) (*uint, error) {
+	if sale.LotID != nil {
+		return sale.LotID, nil
+	}
+
+	var product models.Product
+	if err := tx.Unscoped().First(&product, sale.ProductID).Error; err != nil {
+		return nil, err
 	}
-	if err := tx.Table("stock_usages").
-		Select("stock_usages.*, stock_movements.movement_date").
+	method, err := inventory.CostingMethod(tx, product)
+	if err != nil {
+		return nil, err
+	}
+	if method != models.CostingSpecific {
+		return nil, nil
+	}
+
+	var lotIDs []uint
+	if err := tx.Model(&models.StockUsage{}).
 		Joins("JOIN stock_movements ON stock_movements.id = stock_usages.stock_movement_id").
-		Where("stock_usages.sale_id = ?", id).
-		Order("stock_movements.movement_date DESC"). // En yeni tarihten başla
-		Find(&usages).Error; err != nil {
+		Where("stock_usages.sale_id = ? AND stock_usages.used_quantity > 0 AND stock_movements.purchase_lot_id IS NOT NULL", sale.ID).
+		Order("stock_usages.id desc").
+		Limit(1).
+		Pluck("stock_movements.purchase_lot_id", &lotIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(lotIDs) == 0 {
+		return nil, nil
+	}
+	return &lotIDs[0], nil
+}
+
+// UpdateSale - satışın miktarını, fiyatını, iskontosunu, KDV'sini, müşterisini, tarihini
+// ya da notunu düzeltir. Miktar artışı eksik kısmı aynı maliyet yöntemiyle partilerden
+// düşer, azalış fazlayı en son tüketilen partiden başlayarak geri verir; satılan malın
+// maliyeti buna göre güncellenir. Stok değişiklikleri satış tarihine değil düzeltme
+// anına işlenir; tarih düzeltmesi defter kayıtlarını taşımaz. Her düzeltme değişen
+// alanlarıyla revizyon olarak saklanır. Sipariş satırlarında KDV, müşteri ve tarih
+// siparişe ait olduğundan değiştirilemez; iadesi olan satışın miktarı değiştirilemez.
+func (h *SaleHandler) UpdateSale(c *gin.Context) {
+	var input models.SaleUpdateInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+	if input.CustomerName != nil && input.CustomerID == nil && input.CustomerPhone == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Müşteri değişikliği için müşteri ID'si ya da telefon gereklidir"})
+		return
+	}
+
+	// Transaction başlat; yazma kilidi alınamazsa istemci tekrar dener
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	var sale models.Sale
+	if err := tx.First(&sale, c.Param("id")).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok kullanımları alınamadı"})
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Satış bulunamadı"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Satış alınamadı"})
 		return
 	}
+	before := sale
 
-	// Her stok kullanımı için stokları geri al (yeni tarihten eskiye doğru)
-	for _, usage := range usages {
-		// Stok hareketini ve ürün ID'sini al
-		var stockMovement models.StockMovement
-		if err := tx.First(&stockMovement, usage.StockMovementID).Error; err != nil {
+	// Sipariş satırının KDV, müşteri ve tarihi siparişten gelir
+	if sale.SalesOrderID != nil &&
+		(input.VAT != nil || input.CustomerID != nil || input.CustomerName != nil || input.CustomerPhone != nil || input.SaleDate != nil) {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Sipariş satırının KDV, müşteri ve tarihi siparişten düzeltilir"})
+		return
+	}
+
+	// Reçete malzemeleri maliyet yöntemleriyle düşülür; parti seçilemez. Parti yalnızca
+	// miktar artışının düşüleceği parti olarak seçilir, mevcut kullanımlar taşınmaz.
+	if input.LotID != nil {
+		if sale.RecipeID != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reçete satışında parti seçilemez"})
+			return
+		}
+		if input.Quantity == nil || *input.Quantity <= sale.Quantity {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Parti yalnızca miktar artırılırken seçilebilir"})
+			return
+		}
+		var lots int64
+		if err := tx.Model(&models.PurchaseLot{}).Where("id = ? AND product_id = ?", *input.LotID, sale.ProductID).Count(&lots).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Parti kontrol edilemedi"})
+			return
+		}
+		if lots == 0 {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketi bulunamadı"})
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Seçilen parti ürüne ait değil"})
 			return
 		}
+		sale.LotID = input.LotID
+	}
 
-		// Stok hareketini güncelle
-		if err := tx.Model(&models.StockMovement{}).
-			Where("id = ?", usage.StockMovementID).
-			Update("remaining_quantity", gorm.Expr("remaining_quantity + ?", usage.UsedQuantity)).Error; err != nil {
+	if input.Quantity != nil && *input.Quantity != sale.Quantity {
+		// İade belgeleri satışın partilerdeki kullanımlarına bağlıdır
+		returned, err := hasReturns(tx, sale.ID)
+		if err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketi güncellenemedi"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "İadeler kontrol edilemedi"})
+			return
+		}
+		if returned {
+			tx.Rollback()
+			c.JSON(http.StatusConflict, gin.H{"error": "İadesi olan satışın miktarı değiştirilemez"})
 			return
 		}
 
-		// Ürün stoğunu güncelle
-		if err := tx.Model(&models.Product{}).
-			Where("id = ?", stockMovement.ProductID). // Stok hareketinin ait olduğu ürüne iade et
-			Update("current_stock", gorm.Expr("current_stock + ?", usage.UsedQuantity)).Error; err != nil {
+		// Düzeltme geçmiş dönemleri değiştirmemek için düzeltme anında deftere işlenir
+		correctedAt := time.Now()
+		quantity := *input.Quantity
+		if quantity > sale.Quantity {
+			// Eksik kısım satış oluşturulurken kullanılan düşüm kurallarıyla partilerden düşülür
+			location, err := resolveLocation(tx, sale.LocationID)
+			if err != nil {
+				tx.Rollback()
+				respondLocationError(c, err)
+				return
+			}
+			var demands []inventory.Demand
+			if sale.RecipeID != nil {
+				var recipe models.Recipe
+				if err := tx.Preload("RecipeItems").
+					Preload("RecipeItems.Product").
+					First(&recipe, *sale.RecipeID).Error; err != nil {
+					tx.Rollback()
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Reçete bulunamadı"})
+					return
+				}
+				demands, err = recipeDemands(tx, recipe, quantity-sale.Quantity, location.ID)
+				if err != nil {
+					tx.Rollback()
+					respondRecipeError(c, err)
+					return
+				}
+			} else {
+				// Satışta parti seçildiyse eksik kısım da aynı partiden düşülür
+				lotID, err := saleLot(tx, sale)
+				if err != nil {
+					tx.Rollback()
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Satışın partisi alınamadı"})
+					return
+				}
+				demands = []inventory.Demand{{
+					ProductID:  sale.ProductID,
+					Quantity:   sale.StockQuantity / sale.Quantity * (quantity - sale.Quantity),
+					LotID:      lotID,
+					LocationID: location.ID,
+				}}
+			}
+
+			_, cost, err := inventory.Allocate(tx, inventory.SaleRef(sale.ID), demands, correctedAt)
+			if err != nil {
+				tx.Rollback()
+				respondAllocationError(c, err)
+				return
+			}
+			sale.CostOfGoods += cost
+		} else {
+			cost, err := inventory.Shrink(tx, inventory.SaleRef(sale.ID), (sale.Quantity-quantity)/sale.Quantity, correctedAt)
+			if err != nil {
+				log.Printf("Stok iadesi hatası: %v", err)
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok iade edilemedi"})
+				return
+			}
+			sale.CostOfGoods -= cost
+		}
+
+		sale.StockQuantity = sale.StockQuantity / sale.Quantity * quantity
+		sale.Quantity = quantity
+		sale.UnitCost = sale.CostOfGoods / quantity
+	}
+
+	if input.SalePrice != nil {
+		sale.SalePrice = *input.SalePrice
+	}
+	if input.Discount != nil {
+		sale.Discount = *input.Discount
+	}
+	if input.VAT != nil {
+		sale.VAT = *input.VAT
+	}
+	if input.SaleDate != nil {
+		sale.SaleDate = *input.SaleDate
+	}
+	if input.Note != nil {
+		sale.Note = *input.Note
+	}
+
+	// Müşteri ID ile ya da telefonla değiştirilir; telefonla eşleşen müşteri yoksa
+	// ad verilmişse oluşturulur, verilmemişse satış müşteri kaydından ayrılır
+	if input.CustomerID != nil || input.CustomerPhone != nil {
+		var name, phone string
+		if input.CustomerName != nil {
+			name = *input.CustomerName
+		}
+		if input.CustomerPhone != nil {
+			phone = *input.CustomerPhone
+		}
+		customer, err := resolveCustomer(tx, input.CustomerID, name, phone)
+		if err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün stoğu güncellenemedi"})
+			respondCustomerError(c, err)
 			return
 		}
+		if customer == nil {
+			sale.CustomerID = nil
+			sale.CustomerName = name
+			sale.CustomerPhone = models.NormalizePhone(phone)
+		}
+		assignCustomer(&sale, customer)
 	}
 
-	// Stok kullanımlarını sil
-	if err := tx.Where("sale_id = ?", id).Delete(&models.StockUsage{}).Error; err != nil {
+	changes := saleRevisionChanges(before, sale)
+	if len(changes) == 0 {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok kullanımları silinemedi"})
+		presentSale(&sale)
+		c.JSON(http.StatusOK, gin.H{"data": gin.H{"sale": sale}})
 		return
 	}
 
-	// Satışı sil
-	if err := tx.Delete(&sale).Error; err != nil {
+	if err := tx.Model(&models.Sale{}).Where("id = ?", sale.ID).Updates(map[string]interface{}{
+		"quantity":       sale.Quantity,
+		"stock_quantity": sale.StockQuantity,
+		"lot_id":         sale.LotID,
+		"sale_price":     sale.SalePrice,
+		"discount":       sale.Discount,
+		"vat":            sale.VAT,
+		"customer_id":    sale.CustomerID,
+		"customer_name":  sale.CustomerName,
+		"customer_phone": sale.CustomerPhone,
+		"sale_date":      sale.SaleDate,
+		"note":           sale.Note,
+		"unit_cost":      sale.UnitCost,
+		"cost_of_goods":  sale.CostOfGoods,
+	}).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Satış silinemedi"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Satış güncellenemedi"})
 		return
 	}
 
-	tx.Commit()
+	// Sipariş satırının maliyeti değiştiyse siparişin maliyeti satırlardan yeniden
+	// hesaplanır; sipariş iskontosu düzeltilen satır toplamını aşamaz
+	if sale.SalesOrderID != nil {
+		var order models.SalesOrder
+		if err := tx.Preload("Lines").First(&order, *sale.SalesOrderID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Sipariş alınamadı"})
+			return
+		}
+		order.CalculatePrices()
+		if order.NetPrice < 0 {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Sipariş iskontosu satır toplamını aşamaz"})
+			return
+		}
+		if err := updateOrderCost(tx, order.ID); err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Sipariş maliyeti kaydedilemedi"})
+			return
+		}
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Satış başarıyla silindi"})
+	// Revizyon numarası satış içinde sıralıdır
+	var count int64
+	if err := tx.Model(&models.SaleRevision{}).Where("sale_id = ?", sale.ID).Count(&count).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Revizyon kaydedilemedi"})
+		return
+	}
+	revision := models.SaleRevision{
+		SaleID:  sale.ID,
+		Number:  int(count) + 1,
+		Note:    input.RevisionNote,
+		Changes: changes,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Revizyon kaydedilemedi"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	if err := withSaleDetails(h.db).First(&sale, sale.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Satış detayları alınamadı"})
+		return
+	}
+	presentSale(&sale)
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"sale":     sale,
+		"revision": revision,
+	}})
+}
+
+// GetSaleRevisions - satışın düzeltme geçmişini değişen alanlarıyla listeler
+func (h *SaleHandler) GetSaleRevisions(c *gin.Context) {
+	var sale models.Sale
+	if err := h.db.First(&sale, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Satış bulunamadı"})
+		return
+	}
+
+	var revisions []models.SaleRevision
+	if err := h.db.Preload("Changes").
+		Where("sale_id = ?", sale.ID).
+		Order("number asc").
+		Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Revizyonlar listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"sale":      sale,
+		"revisions": revisions,
+	}})
 }
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"stock-api/internal/inventory"
+	"stock-api/internal/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SaleReturnHandler struct {
+	db *gorm.DB
+}
+
+func NewSaleReturnHandler(db *gorm.DB) *SaleReturnHandler {
+	return &SaleReturnHandler{db: db}
+}
+
+// restockedQuantity bir stok kullanımının stoğa geri alınan iade miktarını veren SQL
+// ifadesidir; kullanılan miktardan düşülerek kullanımın net tüketimi bulunur
+const restockedQuantity = "(SELECT COALESCE(SUM(srl.quantity), 0) FROM sale_return_lines srl " +
+	"JOIN sale_returns sr ON sr.id = srl.sale_return_id " +
+	"WHERE srl.stock_usage_id = stock_usages.id AND sr.disposition = '" + models.ReturnRestock + "')"
+
+// hasReturns satışın iadesi olup olmadığını döner; iadesi olan satış silinemez ve
+// miktarı değiştirilemez
+func hasReturns(tx *gorm.DB, saleIDs ...uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.SaleReturn{}).Where("sale_id IN ?", saleIDs).Count(&count).Error
+	return count > 0, err
+}
+
+// writeOffReturn fire olarak ayrılan iade miktarını ürün için bir fire düzeltmesiyle
+// kaydeder. Mal satışta partilerden zaten düşüldüğünden stok değişmez; düzeltme fire
+// geçmişinde görünsün diye kalanı sıfır olan negatif bir hareketle kaydedilir.
+func writeOffReturn(tx *gorm.DB, sale models.Sale, saleReturn models.SaleReturn, productID uint, quantity, cost float64) (*models.StockAdjustment, error) {
+	var product models.Product
+	if err := tx.Unscoped().First(&product, productID).Error; err != nil {
+		return nil, err
+	}
+	location, err := resolveLocation(tx, sale.LocationID)
+	if err != nil {
+		return nil, err
+	}
+
+	adjustment := models.StockAdjustment{
+		ProductID:      product.ID,
+		Reason:         models.AdjustmentWaste,
+		Quantity:       -quantity,
+		Unit:           product.Unit,
+		StockQuantity:  -quantity,
+		UnitCost:       cost / quantity,
+		TotalCost:      -cost,
+		LocationID:     &location.ID,
+		Note:           fmt.Sprintf("Satış #%d iadesi (%s)", sale.ID, saleReturn.CreditNo),
+		AdjustmentDate: saleReturn.ReturnDate,
+	}
+	if err := tx.Omit("StockMovement", "Usages").Create(&adjustment).Error; err != nil {
+		return nil, err
+	}
+
+	movement := models.StockMovement{
+		ProductID:         product.ID,
+		LocationID:        location.ID,
+		Type:              models.MovementAdjustment,
+		StockAdjustmentID: &adjustment.ID,
+		InitialQuantity:   -quantity,
+		UnitCost:          cost / quantity,
+		MovementDate:      saleReturn.ReturnDate,
+	}
+	if err := tx.Create(&movement).Error; err != nil {
+		return nil, err
+	}
+
+	adjustment.StockMovementID = &movement.ID
+	if err := tx.Model(&adjustment).Update("stock_movement_id", movement.ID).Error; err != nil {
+		return nil, err
+	}
+	return &adjustment, nil
+}
+
+// CreateSaleReturn - satışın bir kısmını iade alır ve iade belgesi oluşturur. Miktar,
+// satışta tüketilen partilere en son tüketilenden başlayarak geri döner; disposition
+// "waste" ise mal stoğa dönmez, fire olarak yazılır. Toplam iade satılan miktarı aşamaz.
+func (h *SaleReturnHandler) CreateSaleReturn(c *gin.Context) {
+	var input models.SaleReturnInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+	if input.Disposition == "" {
+		input.Disposition = models.ReturnRestock
+	}
+
+	// Transaction başlat; yazma kilidi alınamazsa istemci tekrar dener
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	var sale models.Sale
+	if err := tx.First(&sale, c.Param("id")).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Satış bulunamadı"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Satış alınamadı"})
+		return
+	}
+
+	if sale.ReturnedQuantity+input.Quantity > sale.Quantity+1e-9 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("İade miktarı satılan miktarı aşamaz: satılan %.2f, iade edilen %.2f", sale.Quantity, sale.ReturnedQuantity),
+		})
+		return
+	}
+
+	returnDate := time.Now()
+	if input.ReturnDate != nil {
+		returnDate = *input.ReturnDate
+	}
+	if returnDate.Before(sale.SaleDate) {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "İade tarihi satış tarihinden önce olamaz"})
+		return
+	}
+
+	// İade tutarları satışın fiyat, iskonto ve KDV'sinin iade miktarına düşen kısmıdır.
+	// Sipariş satırında sipariş iskontosunun satıra düşen payı da iadeden düşülür.
+	fraction := input.Quantity / sale.Quantity
+	factor := 1.0
+	if sale.SalesOrderID != nil {
+		var order models.SalesOrder
+		if err := tx.Preload("Lines").First(&order, *sale.SalesOrderID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Sipariş alınamadı"})
+			return
+		}
+		order.CalculatePrices()
+		factor = order.DiscountFactor()
+	}
+	saleReturn := models.SaleReturn{
+		SaleID:      sale.ID,
+		CustomerID:  sale.CustomerID,
+		Quantity:    input.Quantity,
+		Unit:        sale.Unit,
+		Disposition: input.Disposition,
+		Reason:      input.Reason,
+		Note:        input.Note,
+		ReturnDate:  returnDate,
+		NetAmount:   sale.NetPrice * factor * fraction,
+		VatAmount:   sale.VatAmount * factor * fraction,
+		TotalAmount: sale.TotalPrice * factor * fraction,
+	}
+	if err := tx.Omit("Lines").Create(&saleReturn).Error; err != nil {
+		log.Printf("İade oluşturma hatası: %v", err)
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "İade kaydedilemedi"})
+		return
+	}
+	saleReturn.CreditNo = fmt.Sprintf("IADE-%06d", saleReturn.ID)
+
+	restock := input.Disposition == models.ReturnRestock
+	returned, err := inventory.Return(tx, sale.ID, fraction, restock, returnDate)
+	if err != nil {
+		log.Printf("Stok iadesi hatası: %v", err)
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok iade edilemedi"})
+		return
+	}
+
+	// Fire olarak ayrılan iade her ürün için tek fire kaydıyla yazılır
+	var productIDs []uint
+	quantities := make(map[uint]float64)
+	costs := make(map[uint]float64)
+	for _, r := range returned {
+		if _, ok := quantities[r.ProductID]; !ok {
+			productIDs = append(productIDs, r.ProductID)
+		}
+		quantities[r.ProductID] += r.Quantity
+		costs[r.ProductID] += r.Quantity * r.UnitCost
+		saleReturn.CostOfGoods += r.Quantity * r.UnitCost
+	}
+	adjustments := make(map[uint]*uint)
+	if !restock {
+		for _, productID := range productIDs {
+			adjustment, err := writeOffReturn(tx, sale, saleReturn, productID, quantities[productID], costs[productID])
+			if err != nil {
+				log.Printf("İade fire kaydı hatası: %v", err)
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Fire kaydı oluşturulamadı"})
+				return
+			}
+			adjustments[productID] = &adjustment.ID
+		}
+	}
+
+	for _, r := range returned {
+		saleReturn.Lines = append(saleReturn.Lines, models.SaleReturnLine{
+			SaleReturnID:      saleReturn.ID,
+			StockUsageID:      r.StockUsageID,
+			StockMovementID:   r.StockMovementID,
+			ProductID:         r.ProductID,
+			Quantity:          r.Quantity,
+			UnitCost:          r.UnitCost,
+			StockAdjustmentID: adjustments[r.ProductID],
+		})
+	}
+	if len(saleReturn.Lines) > 0 {
+		if err := tx.Create(&saleReturn.Lines).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "İade satırları kaydedilemedi"})
+			return
+		}
+	}
+
+	if err := tx.Model(&saleReturn).
+		Select("credit_no", "cost_of_goods").
+		Updates(&saleReturn).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "İade kaydedilemedi"})
+		return
+	}
+
+	// İade edilen malın maliyeti satışın kârından düşülür; fire olarak yazılan iadede
+	// maliyet fire kaydına geçer
+	if err := tx.Model(&models.Sale{}).
+		Where("id = ?", sale.ID).
+		Updates(map[string]interface{}{
+			"returned_quantity": gorm.Expr("returned_quantity + ?", input.Quantity),
+			"returned_cost":     gorm.Expr("returned_cost + ?", saleReturn.CostOfGoods),
+		}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Satış güncellenemedi"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": saleReturn})
+}
+
+// GetSaleReturns - satışın iadelerini parti satırlarıyla listeler
+func (h *SaleReturnHandler) GetSaleReturns(c *gin.Context) {
+	var sale models.Sale
+	if err := h.db.First(&sale, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Satış bulunamadı"})
+		return
+	}
+
+	var returns []models.SaleReturn
+	if err := h.db.Preload("Lines").
+		Where("sale_id = ?", sale.ID).
+		Order("return_date asc, id asc").
+		Find(&returns).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "İadeler listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"sale":             sale,
+		"returns":          returns,
+		"returnedQuantity": sale.ReturnedQuantity,
+		"returnable":       sale.Quantity - sale.ReturnedQuantity,
+	}})
+}
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"stock-api/internal/inventory"
+	"stock-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SalesOrderHandler struct {
+	db *gorm.DB
+}
+
+func NewSalesOrderHandler(db *gorm.DB) *SalesOrderHandler {
+	return &SalesOrderHandler{db: db}
+}
+
+// withOrderLines siparişin satırlarını ürün ve reçeteleriyle birlikte yükler
+func withOrderLines(query *gorm.DB) *gorm.DB {
+	return query.
+		Preload("Lines", func(db *gorm.DB) *gorm.DB { return db.Order("id asc") }).
+		Preload("Lines.Product", withArchived).
+		Preload("Lines.Product.Category").
+		Preload("Lines.Recipe").
+		Preload("Lines.Recipe.Category")
+}
+
+// presentOrder satırlarda reçete adlarını gösterir ve sipariş toplamlarını hesaplar
+func presentOrder(order *models.SalesOrder) {
+	for i := range order.Lines {
+		presentSale(&order.Lines[i])
+	}
+	order.CalculatePrices()
+}
+
+// updateOrderCost siparişin maliyetini satırlarının maliyetleri toplamı olarak kaydeder
+func updateOrderCost(tx *gorm.DB, orderID uint) error {
+	return tx.Model(&models.SalesOrder{}).Where("id = ?", orderID).
+		Update("cost_of_goods", tx.Model(&models.Sale{}).
+			Select("COALESCE(SUM(cost_of_goods), 0)").
+			Where("sales_order_id = ?", orderID)).Error
+}
+
+// CreateSalesOrder - ürün ve reçete satırlarından oluşan siparişi kaydeder. Tüm satırlar
+// aynı transaction'da ürünlerin maliyet yöntemiyle partilerden düşülür; bir satırın stoğu
+// yetmezse sipariş hiç kaydedilmez.
+func (h *SalesOrderHandler) CreateSalesOrder(c *gin.Context) {
+	var input models.SalesOrderInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+
+	if input.CustomerID == nil && (input.CustomerName == "" || input.CustomerPhone == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Müşteri ya da müşteri adı ve telefonu gereklidir"})
+		return
+	}
+
+	for _, line := range input.Lines {
+		isProduct := line.ProductID != nil || line.Barcode != ""
+		if isProduct == (line.RecipeID != nil) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Her satır bir ürün ya da bir reçete içermelidir"})
+			return
+		}
+		if line.RecipeID != nil && line.LotID != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Reçete satırında parti seçilemez"})
+			return
+		}
+	}
+
+	// Transaction başlat; yazma kilidi alınamazsa istemci tekrar dener
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	location, err := resolveLocation(tx, input.LocationID)
+	if err != nil {
+		tx.Rollback()
+		respondLocationError(c, err)
+		return
+	}
+
+	customer, err := resolveCustomer(tx, input.CustomerID, input.CustomerName, input.CustomerPhone)
+	if err != nil {
+		tx.Rollback()
+		respondCustomerError(c, err)
+		return
+	}
+	if customer == nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçerli bir müşteri telefonu gereklidir"})
+		return
+	}
+
+	order := models.SalesOrder{
+		LocationID:    &location.ID,
+		CustomerID:    &customer.ID,
+		CustomerName:  customer.Name,
+		CustomerPhone: customer.Phone,
+		OrderDate:     input.OrderDate,
+		Discount:      input.Discount,
+		VAT:           input.VAT,
+		Note:          input.Note,
+	}
+	if err := tx.Omit(clause.Associations).Create(&order).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Sipariş kaydedilemedi"})
+		return
+	}
+
+	for _, line := range input.Lines {
+		// Satırlar siparişin müşterisi, tarihi, konumu ve KDV oranıyla satış olarak kaydedilir
+		sale := models.Sale{
+			SalesOrderID: &order.ID,
+			LocationID:   &location.ID,
+			LotID:        line.LotID,
+			Quantity:     line.Quantity,
+			Unit:         line.Unit,
+			SaleDate:     order.OrderDate,
+			SalePrice:    line.SalePrice,
+			Discount:     line.Discount,
+			VAT:          order.VAT,
+			Note:         line.Note,
+		}
+		assignCustomer(&sale, customer)
+
+		var demands []inventory.Demand
+		if line.RecipeID != nil {
+			var recipe models.Recipe
+			if err := tx.Preload("RecipeItems").
+				Preload("RecipeItems.Product").
+				First(&recipe, *line.RecipeID).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Reçete bulunamadı"})
+				return
+			}
+
+			demands, err = recipeDemands(tx, recipe, line.Quantity, location.ID)
+			if err != nil {
+				tx.Rollback()
+				respondRecipeError(c, err)
+				return
+			}
+			sale.RecipeID = &recipe.ID
+		} else {
+			var product models.Product
+			if line.ProductID != nil {
+				err = tx.First(&product, *line.ProductID).Error
+			} else {
+				var barcode models.ProductBarcode
+				product, barcode, err = findProductByBarcode(tx, line.Barcode)
+				if err == nil && sale.Unit == "" {
+					sale.Unit = barcode.UnitCode
+				}
+			}
+			if err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Ürün bulunamadı"})
+				return
+			}
+
+			// Satır miktarını ürün birimine çevir
+			factor, err := unitFactor(tx, product, sale.Unit)
+			if err != nil {
+				tx.Rollback()
+				respondRecipeError(c, err)
+				return
+			}
+			if sale.Unit == "" {
+				sale.Unit = product.Unit
+			}
+			sale.ProductID = product.ID
+			sale.StockQuantity = sale.Quantity * factor
+			demands = []inventory.Demand{{ProductID: product.ID, Quantity: sale.StockQuantity, LotID: line.LotID, LocationID: location.ID}}
+		}
+
+		if err := tx.Omit(clause.Associations).Create(&sale).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Sipariş satırı kaydedilemedi"})
+			return
+		}
+
+		_, cost, err := inventory.Allocate(tx, inventory.SaleRef(sale.ID), demands, order.OrderDate)
+		if err != nil {
+			tx.Rollback()
+			respondAllocationError(c, err)
+			return
+		}
+
+		// Satırın maliyeti partilerden gelir
+		sale.CostOfGoods = cost
+		sale.UnitCost = cost / sale.Quantity
+		if err := tx.Model(&models.Sale{}).Where("id = ?", sale.ID).Updates(map[string]interface{}{
+			"cost_of_goods": sale.CostOfGoods,
+			"unit_cost":     sale.UnitCost,
+		}).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Satış maliyeti kaydedilemedi"})
+			return
+		}
+		order.Lines = append(order.Lines, sale)
+	}
+
+	// Sipariş iskontosu satır toplamını aşamaz; maliyet satırlardan toplanır
+	order.CalculatePrices()
+	if order.NetPrice < 0 {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sipariş iskontosu satır toplamını aşamaz"})
+		return
+	}
+
+	if err := tx.Model(&order).Update("cost_of_goods", order.CostOfGoods).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Sipariş maliyeti kaydedilemedi"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	var created models.SalesOrder
+	if err := withOrderLines(h.db).First(&created, order.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Sipariş detayları alınamadı"})
+		return
+	}
+	presentOrder(&created)
+
+	c.JSON(http.StatusCreated, gin.H{"data": created})
+}
+
+// GetSalesOrders - siparişleri satırlarıyla listeler; locationId, customerPhone ve tarih
+// aralığıyla filtrelenebilir
+func (h *SalesOrderHandler) GetSalesOrders(c *gin.Context) {
+	start, end, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	var orders []models.SalesOrder
+	query := withOrderLines(h.db).Order("order_date desc, id desc")
+
+	if locationID := c.Query("locationId"); locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+	if phone := c.Query("customerPhone"); phone != "" {
+		query = query.Where("customer_phone = ?", phone)
+	}
+	if start != nil {
+		query = query.Where("order_date >= ?", *start)
+	}
+	if end != nil {
+		query = query.Where("order_date < ?", *end)
+	}
+
+	if err := query.Find(&orders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Siparişler listelenemedi"})
+		return
+	}
+	for i := range orders {
+		presentOrder(&orders[i])
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": orders})
+}
+
+// GetSalesOrder - ID ile siparişi satırlarıyla getirir
+func (h *SalesOrderHandler) GetSalesOrder(c *gin.Context) {
+	var order models.SalesOrder
+	if err := withOrderLines(h.db).First(&order, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sipariş bulunamadı"})
+		return
+	}
+	presentOrder(&order)
+
+	c.JSON(http.StatusOK, gin.H{"data": order})
+}
+
+// DeleteSalesOrder - siparişin tüm satırlarının stoğunu partilere iade eder ve siparişi siler
+func (h *SalesOrderHandler) DeleteSalesOrder(c *gin.Context) {
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	var order models.SalesOrder
+	if err := tx.Preload("Lines").First(&order, c.Param("id")).Error; err != nil {
+		tx.Rollback()
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sipariş bulunamadı"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Sipariş alınamadı"})
+		return
+	}
+
+	// İade belgesi kesilmiş sipariş silinemez
+	lineIDs := []uint{0}
+	for _, line := range order.Lines {
+		lineIDs = append(lineIDs, line.ID)
+	}
+	returned, err := hasReturns(tx, lineIDs...)
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "İadeler kontrol edilemedi"})
+		return
+	}
+	if returned {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "İadesi olan sipariş silinemez"})
+		return
+	}
+
+	for _, line := range order.Lines {
+		if err := inventory.Release(tx, inventory.SaleRef(line.ID)); err != nil {
+			log.Printf("Stok iadesi hatası: %v", err)
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok iade edilemedi"})
+			return
+		}
+	}
+
+	if err := tx.Where("sales_order_id = ?", order.ID).Delete(&models.Sale{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Sipariş satırları silinemedi"})
+		return
+	}
+	if err := tx.Delete(&models.SalesOrder{}, order.ID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Sipariş silinemedi"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Sipariş başarıyla silindi"})
+}
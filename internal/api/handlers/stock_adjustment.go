@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"stock-api/internal/inventory"
+	"stock-api/internal/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type StockAdjustmentHandler struct {
+	db *gorm.DB
+}
+
+func NewStockAdjustmentHandler(db *gorm.DB) *StockAdjustmentHandler {
+	return &StockAdjustmentHandler{db: db}
+}
+
+// CreateAdjustment - fire, kırılma, kayıp, numune ve sayım düzeltmelerini kaydeder.
+// Çıkışlar partilerden ürünün maliyet yöntemiyle düşülür; girişler seçilen maliyetle yeni parti açar.
+func (h *StockAdjustmentHandler) CreateAdjustment(c *gin.Context) {
+	var adjustment models.StockAdjustment
+	if err := c.ShouldBindJSON(&adjustment); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+	adjustment.ID = 0
+	adjustment.Product = nil
+	adjustment.StockMovement = nil
+	adjustment.StockMovementID = nil
+	adjustment.Usages = nil
+	adjustment.SourceMovementID = nil
+	adjustment.Note = strings.TrimSpace(adjustment.Note)
+
+	if adjustment.Quantity == 0 || adjustment.Note == "" || adjustment.AdjustmentDate.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Miktar sıfır olamaz; not ve tarih gereklidir"})
+		return
+	}
+
+	// Fire, kırılma, kayıp ve numune yalnızca stok düşürür
+	increase := adjustment.Quantity > 0
+	if increase && adjustment.Reason != models.AdjustmentCountCorrection {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Stok yalnızca sayım düzeltmesiyle artırılabilir"})
+		return
+	}
+	if increase && adjustment.LotID != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Parti seçimi yalnızca stok çıkışında kullanılır"})
+		return
+	}
+
+	// Transaction başlat
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	var product models.Product
+	if err := tx.First(&product, adjustment.ProductID).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ürün bulunamadı"})
+		return
+	}
+
+	if err := applyAdjustment(tx, &adjustment, product); err != nil {
+		tx.Rollback()
+		if errors.Is(err, errUnitConversion) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if errors.Is(err, errLocationNotFound) {
+			respondLocationError(c, err)
+			return
+		}
+		respondAllocationError(c, err)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": adjustment})
+}
+
+// applyAdjustment düzeltmeyi kaydeder ve stoğa işler: çıkışları partilerden düşer,
+// girişler için yeni parti açar; her iki durumda da hareket geçmişine bir kayıt ekler.
+// Düzeltme miktarı ve birim maliyeti düzeltmenin biriminde verilir; konum verilmemişse
+// varsayılan konum kullanılır.
+func applyAdjustment(tx *gorm.DB, adjustment *models.StockAdjustment, product models.Product) error {
+	increase := adjustment.Quantity > 0
+
+	location, err := resolveLocation(tx, adjustment.LocationID)
+	if err != nil {
+		return err
+	}
+	adjustment.LocationID = &location.ID
+
+	// Düzeltme miktarını ürün birimine çevir
+	factor, err := unitFactor(tx, product, adjustment.Unit)
+	if err != nil {
+		return err
+	}
+	if adjustment.Unit == "" {
+		adjustment.Unit = product.Unit
+	}
+	adjustment.StockQuantity = adjustment.Quantity * factor
+	quantity := adjustment.StockQuantity
+	if !increase {
+		quantity = -quantity
+	}
+
+	// Giriş maliyeti düzeltme biriminde verilir, partide ürün birimine çevrilir
+	unitCost := adjustment.UnitCost / factor
+	if increase {
+		adjustment.TotalCost = adjustment.UnitCost * adjustment.Quantity
+	}
+
+	if err := tx.Omit("StockMovement", "Usages").Create(adjustment).Error; err != nil {
+		log.Printf("Stok düzeltmesi oluşturma hatası: %v", err)
+		return err
+	}
+
+	movement := models.StockMovement{
+		ProductID:         product.ID,
+		LocationID:        location.ID,
+		Type:              models.MovementAdjustment,
+		StockAdjustmentID: &adjustment.ID,
+		MovementDate:      adjustment.AdjustmentDate,
+	}
+
+	if increase {
+		// Giriş, sonraki tüketimlerde kullanılacak yeni bir partidir
+		movement.InitialQuantity = quantity
+		movement.RemainingQuantity = quantity
+		movement.UnitCost = unitCost
+		if adjustment.ExpiryDate != nil {
+			expiry := inventory.ExpiryDay(*adjustment.ExpiryDate)
+			movement.ExpiryDate = &expiry
+		}
+
+		if err := tx.Model(&models.Product{}).
+			Where("id = ?", product.ID).
+			Update("current_stock", gorm.Expr("current_stock + ?", quantity)).Error; err != nil {
+			return err
+		}
+	} else {
+		demands := []inventory.Demand{{
+			ProductID:  product.ID,
+			Quantity:   quantity,
+			LotID:      adjustment.LotID,
+			MovementID: adjustment.SourceMovementID,
+			LocationID: location.ID,
+		}}
+		usages, cost, err := inventory.Allocate(tx, inventory.AdjustmentRef(adjustment.ID), demands, adjustment.AdjustmentDate)
+		if err != nil {
+			return err
+		}
+		adjustment.Usages = usages
+		adjustment.TotalCost = -cost
+		adjustment.UnitCost = cost / quantity * factor
+
+		// Çıkış geçmişte görünsün diye kalanı sıfır olan negatif bir hareket olarak kaydedilir
+		movement.InitialQuantity = -quantity
+		movement.UnitCost = cost / quantity
+	}
+
+	if err := tx.Create(&movement).Error; err != nil {
+		return err
+	}
+
+	if increase {
+		if err := inventory.Record(tx, models.EntryAdjustment, inventory.AdjustmentRef(adjustment.ID), movement, quantity, adjustment.AdjustmentDate); err != nil {
+			return err
+		}
+	}
+
+	adjustment.StockMovementID = &movement.ID
+	if err := tx.Model(adjustment).
+		Select("stock_movement_id", "unit_cost", "total_cost").
+		Updates(adjustment).Error; err != nil {
+		return err
+	}
+
+	adjustment.StockMovement = &movement
+	return nil
+}
+
+// GetAdjustments - stok düzeltmelerini ürün, neden ve tarih aralığına göre listeler
+func (h *StockAdjustmentHandler) GetAdjustments(c *gin.Context) {
+	start, end, ok := parseDateRange(c)
+	if !ok {
+		return
+	}
+
+	var adjustments []models.StockAdjustment
+	query := h.db.Preload("Product", withArchived).Order("adjustment_date desc")
+
+	if productID := c.Query("productId"); productID != "" {
+		query = query.Where("product_id = ?", productID)
+	}
+	if reason := c.Query("reason"); reason != "" {
+		query = query.Where("reason = ?", reason)
+	}
+	if locationID := c.Query("locationId"); locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+	if start != nil {
+		query = query.Where("adjustment_date >= ?", *start)
+	}
+	if end != nil {
+		query = query.Where("adjustment_date < ?", *end)
+	}
+
+	if err := query.Find(&adjustments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok düzeltmeleri listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": adjustments})
+}
+
+// GetAdjustment - ID ile stok düzeltmesini hareket ve parti kullanımlarıyla getirir
+func (h *StockAdjustmentHandler) GetAdjustment(c *gin.Context) {
+	var adjustment models.StockAdjustment
+	if err := h.db.Preload("Product", withArchived).
+		Preload("StockMovement", withArchived).
+		Preload("Usages").
+		First(&adjustment, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stok düzeltmesi bulunamadı"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": adjustment})
+}
@@ -1,8 +1,12 @@
 package handlers
 
 import (
+	"math"
 	"net/http"
+	"stock-api/internal/inventory"
 	"stock-api/internal/models"
+	"strconv"
+	"time"
 
 	"log"
 
@@ -28,8 +32,29 @@ func (h *StockMovementHandler) GetStockMovements(c *gin.Context) {
 		query = query.Where("product_id = ?", productID)
 	}
 
-	// Ürün bilgilerini de getir
-	query = query.Preload("Product")
+	// Konuma göre filtrele
+	if locationID := c.Query("locationId"); locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+
+	// Hareket türüne göre filtrele (purchase, adjustment)
+	if movementType := c.Query("type"); movementType != "" {
+		query = query.Where("type = ?", movementType)
+	}
+
+	// Kategoriye göre filtrele (alt kategoriler dahil)
+	if categoryID := c.Query("categoryId"); categoryID != "" {
+		subtree, err := categorySubtree(h.db, categoryID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kategori bulunamadı"})
+			return
+		}
+		products := h.db.Unscoped().Model(&models.Product{}).Select("id").Where("category_id IN (?)", subtree)
+		query = query.Where("product_id IN (?)", products)
+	}
+
+	// Ürün ve konum bilgilerini de getir
+	query = query.Preload("Product", withArchived).Preload("Location", withArchived)
 
 	if err := query.Find(&stockMovements).Error; err != nil {
 		log.Printf("Stok hareketleri listeleme hatası: %v", err)
@@ -40,3 +65,73 @@ func (h *StockMovementHandler) GetStockMovements(c *gin.Context) {
 	log.Printf("Bulunan stok hareketi sayısı: %d", len(stockMovements))
 	c.JSON(http.StatusOK, gin.H{"data": stockMovements})
 }
+
+// GetExpiringStock - son kullanma tarihi önümüzdeki days gün içinde dolan (veya dolmuş) kalan stoğu listeler
+func (h *StockMovementHandler) GetExpiringStock(c *gin.Context) {
+	days := 7
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz gün sayısı"})
+			return
+		}
+		days = parsed
+	}
+
+	today := inventory.ExpiryDay(time.Now())
+	limit := today.AddDate(0, 0, days)
+
+	query := h.db.Preload("Product").
+		Preload("Product.Category").
+		Where("remaining_quantity > 0 AND expiry_date IS NOT NULL AND expiry_date <= ?", limit).
+		Order("expiry_date asc, movement_date asc")
+
+	// Konuma göre filtrele
+	if locationID := c.Query("locationId"); locationID != "" {
+		query = query.Where("location_id = ?", locationID)
+	}
+
+	// Kategoriye göre filtrele (alt kategoriler dahil)
+	if categoryID := c.Query("categoryId"); categoryID != "" {
+		subtree, err := categorySubtree(h.db, categoryID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Kategori bulunamadı"})
+			return
+		}
+		products := h.db.Model(&models.Product{}).Select("id").Where("category_id IN (?)", subtree)
+		query = query.Where("product_id IN (?)", products)
+	}
+
+	var movements []models.StockMovement
+	if err := query.Find(&movements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketleri listelenemedi"})
+		return
+	}
+
+	type expiringLot struct {
+		models.StockMovement
+		DaysLeft   int     `json:"daysLeft"`
+		Expired    bool    `json:"expired"`
+		StockValue float64 `json:"stockValue"`
+	}
+
+	var totalValue float64
+	lots := make([]expiringLot, 0, len(movements))
+	for _, m := range movements {
+		daysLeft := int(math.Round(inventory.ExpiryDay(*m.ExpiryDate).Sub(today).Hours() / 24))
+		value := m.RemainingQuantity * m.UnitCost
+		lots = append(lots, expiringLot{
+			StockMovement: m,
+			DaysLeft:      daysLeft,
+			Expired:       daysLeft < 0,
+			StockValue:    value,
+		})
+		totalValue += value
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"days":       days,
+		"lots":       lots,
+		"stockValue": totalValue,
+	}})
+}
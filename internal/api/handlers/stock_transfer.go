@@ -0,0 +1,494 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"stock-api/internal/inventory"
+	"stock-api/internal/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type StockTransferHandler struct {
+	db *gorm.DB
+}
+
+func NewStockTransferHandler(db *gorm.DB) *StockTransferHandler {
+	return &StockTransferHandler{db: db}
+}
+
+// receiptEntry teslimde bir satırın teslim alınan miktarıdır; miktar satırın birimindedir
+type receiptEntry struct {
+	LineID           uint     `json:"lineId" binding:"required"`
+	ReceivedQuantity *float64 `json:"receivedQuantity" binding:"required,gte=0"`
+	Note             string   `json:"note"`
+}
+
+// inTransitBalance bir ürünün hedef konuma yolda olan miktarı ve değeridir
+type inTransitBalance struct {
+	ProductID    uint    `json:"productId"`
+	ProductName  string  `json:"productName"`
+	Unit         string  `json:"unit"`
+	ToLocationID uint    `json:"toLocationId"`
+	Quantity     float64 `json:"quantity"`
+	Cost         float64 `json:"cost"`
+}
+
+// loadTransfer transferi satırları, kaynak partileri ve farklarıyla birlikte yükler
+func loadTransfer(db *gorm.DB, id interface{}) (models.StockTransfer, error) {
+	var transfer models.StockTransfer
+	err := db.Preload("FromLocation").
+		Preload("ToLocation").
+		Preload("Lines", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id asc")
+		}).
+		Preload("Lines.Product", withArchived).
+		Preload("Lines.Items", func(db *gorm.DB) *gorm.DB {
+			return db.Order("id asc")
+		}).
+		Preload("Discrepancies").
+		First(&transfer, id).Error
+	return transfer, err
+}
+
+// CreateTransfer - iki konum arasında taslak transfer açar; stok sevkte düşülür.
+// ship=true ise transfer oluşturulur oluşturulmaz sevk edilir.
+func (h *StockTransferHandler) CreateTransfer(c *gin.Context) {
+	var input struct {
+		models.StockTransfer
+		Ship bool `json:"ship"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+	transfer := input.StockTransfer
+	transfer.ID = 0
+	transfer.Status = models.TransferDraft
+	transfer.ShippedAt = nil
+	transfer.ReceivedAt = nil
+	transfer.Discrepancies = nil
+
+	if transfer.FromLocationID == transfer.ToLocationID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Kaynak ve hedef konum aynı olamaz"})
+		return
+	}
+	for _, id := range []uint{transfer.FromLocationID, transfer.ToLocationID} {
+		id := id
+		if _, err := resolveLocation(h.db, &id); err != nil {
+			respondLocationError(c, err)
+			return
+		}
+	}
+
+	// Transaction başlat
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	for i := range transfer.Lines {
+		line := &transfer.Lines[i]
+		line.ID = 0
+		line.ShippedQuantity = 0
+		line.ShippedCost = 0
+		line.ReceivedQuantity = 0
+		line.Items = nil
+
+		var product models.Product
+		if err := tx.First(&product, line.ProductID).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Ürün bulunamadı: %d", line.ProductID)})
+			return
+		}
+
+		// Transfer miktarını ürün birimine çevir
+		factor, err := unitFactor(tx, product, line.Unit)
+		if err != nil {
+			tx.Rollback()
+			if errors.Is(err, errUnitConversion) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Birim dönüştürülemedi"})
+			return
+		}
+		if line.Unit == "" {
+			line.Unit = product.Unit
+		}
+		line.StockQuantity = line.Quantity * factor
+	}
+
+	if err := tx.Omit("FromLocation", "ToLocation", "Lines.Product").Create(&transfer).Error; err != nil {
+		tx.Rollback()
+		log.Printf("Transfer oluşturma hatası: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transfer kaydedilemedi"})
+		return
+	}
+
+	if input.Ship {
+		if err := shipTransfer(tx, &transfer, time.Now()); err != nil {
+			tx.Rollback()
+			respondAllocationError(c, err)
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": transfer})
+}
+
+// shipTransfer her satırı kaynak konumun partilerinden FIFO sırasıyla düşer ve düşülen
+// partileri satıra kaydeder. Düşülen stok ürün kartından da çıkar; teslim alınana
+// kadar yalnızca yoldaki bakiyede görünür.
+func shipTransfer(tx *gorm.DB, transfer *models.StockTransfer, at time.Time) error {
+	for i := range transfer.Lines {
+		line := &transfer.Lines[i]
+		demands := []inventory.Demand{{
+			ProductID:  line.ProductID,
+			Quantity:   line.StockQuantity,
+			LocationID: transfer.FromLocationID,
+			Method:     models.CostingFIFO,
+		}}
+		usages, cost, err := inventory.Allocate(tx, inventory.TransferRef(transfer.ID), demands, at)
+		if err != nil {
+			return err
+		}
+
+		line.Items = nil
+		for _, usage := range usages {
+			var source models.StockMovement
+			if err := tx.Unscoped().First(&source, usage.StockMovementID).Error; err != nil {
+				return err
+			}
+			item := models.StockTransferItem{
+				StockTransferLineID: line.ID,
+				SourceMovementID:    source.ID,
+				PurchaseLotID:       source.PurchaseLotID,
+				ExpiryDate:          source.ExpiryDate,
+				Quantity:            usage.UsedQuantity,
+				UnitCost:            usage.UnitCost,
+			}
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+			line.Items = append(line.Items, item)
+		}
+
+		line.ShippedQuantity = line.StockQuantity
+		line.ShippedCost = cost
+		if err := tx.Model(line).
+			Select("shipped_quantity", "shipped_cost").
+			Updates(line).Error; err != nil {
+			return err
+		}
+	}
+
+	transfer.Status = models.TransferShipped
+	transfer.ShippedAt = &at
+	return tx.Model(transfer).
+		Select("status", "shipped_at").
+		Updates(transfer).Error
+}
+
+// GetTransfers - transferleri satırları olmadan listeler; locationId kaynak ya da hedefi eşler
+func (h *StockTransferHandler) GetTransfers(c *gin.Context) {
+	locationID, ok := queryLocationID(c)
+	if !ok {
+		return
+	}
+
+	var transfers []models.StockTransfer
+	query := h.db.Preload("FromLocation").Preload("ToLocation").Order("created_at desc")
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if locationID != 0 {
+		query = query.Where("from_location_id = ? OR to_location_id = ?", locationID, locationID)
+	}
+
+	if err := query.Find(&transfers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transferler listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": transfers})
+}
+
+// GetTransfer - transferi satırları, sevk edilen partileri ve teslim farklarıyla getirir
+func (h *StockTransferHandler) GetTransfer(c *gin.Context) {
+	transfer, err := loadTransfer(h.db, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer bulunamadı"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": transfer})
+}
+
+// ShipTransfer - taslak transferi sevk eder
+func (h *StockTransferHandler) ShipTransfer(c *gin.Context) {
+	// Transaction başlat
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	transfer, err := loadTransfer(tx, c.Param("id"))
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer bulunamadı"})
+		return
+	}
+	if transfer.Status != models.TransferDraft {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Yalnızca taslak transfer sevk edilebilir"})
+		return
+	}
+
+	if err := shipTransfer(tx, &transfer, time.Now()); err != nil {
+		tx.Rollback()
+		respondAllocationError(c, err)
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": transfer})
+}
+
+// ReceiveTransfer - sevk edilen transferi hedef konumda teslim alır. Her kaynak parti
+// hedefte aynı parti, birim maliyet ve son kullanma tarihiyle yeni bir stok hareketi
+// olarak açılır. Satır için miktar verilmezse sevk edilen miktarın tamamı teslim alınmış
+// sayılır; teslim alınan miktar farklıysa fark kaydı oluşturulur. Eksik teslimde son
+// partilerden eksiltilir, fazla teslim son partiye eklenir.
+func (h *StockTransferHandler) ReceiveTransfer(c *gin.Context) {
+	var input struct {
+		Lines []receiptEntry `json:"lines" binding:"omitempty,dive"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+			return
+		}
+	}
+
+	// Transaction başlat
+	tx := h.db.Begin()
+	if tx.Error != nil {
+		respondAllocationError(c, tx.Error)
+		return
+	}
+
+	transfer, err := loadTransfer(tx, c.Param("id"))
+	if err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer bulunamadı"})
+		return
+	}
+	if transfer.Status != models.TransferShipped {
+		tx.Rollback()
+		c.JSON(http.StatusConflict, gin.H{"error": "Yalnızca sevk edilmiş transfer teslim alınabilir"})
+		return
+	}
+
+	lineIDs := make(map[uint]bool, len(transfer.Lines))
+	for _, line := range transfer.Lines {
+		lineIDs[line.ID] = true
+	}
+	receipts := make(map[uint]receiptEntry, len(input.Lines))
+	for _, entry := range input.Lines {
+		if !lineIDs[entry.LineID] {
+			tx.Rollback()
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Transfer satırı bulunamadı: %d", entry.LineID)})
+			return
+		}
+		receipts[entry.LineID] = entry
+	}
+
+	now := time.Now()
+	for i := range transfer.Lines {
+		line := &transfer.Lines[i]
+		received := line.ShippedQuantity
+		entry, ok := receipts[line.ID]
+		if ok {
+			// Teslim miktarı satırın biriminde verilir
+			received = *entry.ReceivedQuantity * line.StockQuantity / line.Quantity
+		}
+
+		// Teslim alınan miktar sevk sırasıyla partilere dağıtılır
+		remaining := received
+		var discrepancyCost float64
+		for j := range line.Items {
+			item := &line.Items[j]
+			quantity := math.Min(remaining, item.Quantity)
+			if j == len(line.Items)-1 {
+				quantity = remaining
+			}
+			remaining -= quantity
+			discrepancyCost += (quantity - item.Quantity) * item.UnitCost
+			item.ReceivedQuantity = quantity
+			if quantity <= 0 {
+				continue
+			}
+
+			movement := models.StockMovement{
+				ProductID:         line.ProductID,
+				LocationID:        transfer.ToLocationID,
+				Type:              models.MovementTransfer,
+				PurchaseLotID:     item.PurchaseLotID,
+				StockTransferID:   &transfer.ID,
+				InitialQuantity:   quantity,
+				RemainingQuantity: quantity,
+				UnitCost:          item.UnitCost,
+				MovementDate:      now,
+				ExpiryDate:        item.ExpiryDate,
+			}
+			if err := tx.Create(&movement).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Stok hareketi kaydedilemedi"})
+				return
+			}
+			if err := inventory.Record(tx, models.EntryTransfer, inventory.TransferRef(transfer.ID), movement, quantity, now); err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Envanter kaydı oluşturulamadı"})
+				return
+			}
+
+			item.DestinationMovementID = &movement.ID
+			if err := tx.Model(item).
+				Select("received_quantity", "destination_movement_id").
+				Updates(item).Error; err != nil {
+				tx.Rollback()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Transfer satırı güncellenemedi"})
+				return
+			}
+		}
+
+		if err := tx.Model(&models.Product{}).
+			Where("id = ?", line.ProductID).
+			Update("current_stock", gorm.Expr("current_stock + ?", received)).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün stoğu güncellenemedi"})
+			return
+		}
+
+		line.ReceivedQuantity = received
+		if err := tx.Model(line).Update("received_quantity", received).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Transfer satırı güncellenemedi"})
+			return
+		}
+
+		if math.Abs(received-line.ShippedQuantity) < 1e-9 {
+			continue
+		}
+		discrepancy := models.StockTransferDiscrepancy{
+			StockTransferID:     transfer.ID,
+			StockTransferLineID: line.ID,
+			ProductID:           line.ProductID,
+			ShippedQuantity:     line.ShippedQuantity,
+			ReceivedQuantity:    received,
+			Quantity:            received - line.ShippedQuantity,
+			Cost:                discrepancyCost,
+			Note:                entry.Note,
+		}
+		if err := tx.Create(&discrepancy).Error; err != nil {
+			tx.Rollback()
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Teslim farkı kaydedilemedi"})
+			return
+		}
+		transfer.Discrepancies = append(transfer.Discrepancies, discrepancy)
+	}
+
+	transfer.Status = models.TransferReceived
+	transfer.ReceivedAt = &now
+	if err := tx.Model(&transfer).
+		Select("status", "received_at").
+		Updates(&transfer).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transfer teslim alınamadı"})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondAllocationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": transfer})
+}
+
+// CancelTransfer - taslak transferi stoğa dokunmadan iptal eder
+func (h *StockTransferHandler) CancelTransfer(c *gin.Context) {
+	var transfer models.StockTransfer
+	if err := h.db.First(&transfer, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer bulunamadı"})
+		return
+	}
+	if transfer.Status != models.TransferDraft {
+		c.JSON(http.StatusConflict, gin.H{"error": "Yalnızca taslak transfer iptal edilebilir"})
+		return
+	}
+
+	if err := h.db.Model(&transfer).Update("status", models.TransferCancelled).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Transfer iptal edilemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": transfer})
+}
+
+// GetInTransit - sevk edilip henüz teslim alınmamış stoğu ürün ve hedef konum bazında
+// miktar ve parti maliyetiyle listeler; locationId hedef konumu filtreler
+func (h *StockTransferHandler) GetInTransit(c *gin.Context) {
+	locationID, ok := queryLocationID(c)
+	if !ok {
+		return
+	}
+
+	balances := make([]inTransitBalance, 0)
+	query := h.db.Table("stock_transfer_items").
+		Select("stock_transfer_lines.product_id, products.product_name, products.unit, "+
+			"stock_transfers.to_location_id, "+
+			"SUM(stock_transfer_items.quantity) AS quantity, "+
+			"SUM(stock_transfer_items.quantity * stock_transfer_items.unit_cost) AS cost").
+		Joins("JOIN stock_transfer_lines ON stock_transfer_lines.id = stock_transfer_items.stock_transfer_line_id").
+		Joins("JOIN stock_transfers ON stock_transfers.id = stock_transfer_lines.stock_transfer_id").
+		Joins("JOIN products ON products.id = stock_transfer_lines.product_id").
+		Where("stock_transfers.status = ?", models.TransferShipped)
+	if locationID != 0 {
+		query = query.Where("stock_transfers.to_location_id = ?", locationID)
+	}
+	if err := query.
+		Group("stock_transfer_lines.product_id, products.product_name, products.unit, stock_transfers.to_location_id").
+		Order("products.product_name asc").
+		Scan(&balances).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Yoldaki stok alınamadı"})
+		return
+	}
+
+	var cost float64
+	for _, balance := range balances {
+		cost += balance.Cost
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"items": balances,
+		"cost":  cost,
+	}})
+}
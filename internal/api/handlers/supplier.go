@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"stock-api/internal/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type SupplierHandler struct {
+	db *gorm.DB
+}
+
+func NewSupplierHandler(db *gorm.DB) *SupplierHandler {
+	return &SupplierHandler{db: db}
+}
+
+// validateSupplier zorunlu alanları ve vergi numarası tekilliğini kontrol eder
+func (h *SupplierHandler) validateSupplier(c *gin.Context, supplier *models.Supplier) bool {
+	supplier.Name = strings.TrimSpace(supplier.Name)
+	supplier.TaxNumber = strings.TrimSpace(supplier.TaxNumber)
+	if supplier.Name == "" || supplier.PaymentTermDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Tedarikçi adı gereklidir ve vade günü negatif olamaz"})
+		return false
+	}
+
+	if supplier.TaxNumber != "" {
+		var count int64
+		if err := h.db.Model(&models.Supplier{}).
+			Where("tax_number = ? AND id != ?", supplier.TaxNumber, supplier.ID).
+			Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Tedarikçi kontrol edilemedi"})
+			return false
+		}
+		if count > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Bu vergi numarasıyla kayıtlı bir tedarikçi zaten var"})
+			return false
+		}
+	}
+
+	return true
+}
+
+func (h *SupplierHandler) CreateSupplier(c *gin.Context) {
+	var supplier models.Supplier
+	if err := c.ShouldBindJSON(&supplier); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+	supplier.ID = 0
+
+	if !h.validateSupplier(c, &supplier) {
+		return
+	}
+
+	if err := h.db.Create(&supplier).Error; err != nil {
+		log.Printf("Tedarikçi oluşturma hatası: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tedarikçi kaydedilemedi"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": supplier})
+}
+
+func (h *SupplierHandler) GetSuppliers(c *gin.Context) {
+	var suppliers []models.Supplier
+	if err := h.db.Order("name asc").Find(&suppliers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tedarikçiler listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": suppliers})
+}
+
+// GetSupplier - ID ile tedarikçi getirme
+func (h *SupplierHandler) GetSupplier(c *gin.Context) {
+	var supplier models.Supplier
+	if err := h.db.First(&supplier, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tedarikçi bulunamadı"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"data": supplier})
+}
+
+func (h *SupplierHandler) UpdateSupplier(c *gin.Context) {
+	var supplier models.Supplier
+	if err := h.db.First(&supplier, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tedarikçi bulunamadı"})
+		return
+	}
+
+	var input models.Supplier
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+	input.ID = supplier.ID
+	input.CreatedAt = supplier.CreatedAt
+
+	if !h.validateSupplier(c, &input) {
+		return
+	}
+
+	if err := h.db.Save(&input).Error; err != nil {
+		log.Printf("Tedarikçi güncelleme hatası: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tedarikçi güncellenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": input})
+}
+
+func (h *SupplierHandler) DeleteSupplier(c *gin.Context) {
+	id := c.Param("id")
+
+	// Alış partisi olan tedarikçi silinemez
+	var count int64
+	if err := h.db.Unscoped().Model(&models.PurchaseLot{}).Where("supplier_id = ?", id).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tedarikçi kontrol edilemedi"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu tedarikçiden yapılmış alışlar var, silinemez"})
+		return
+	}
+
+	tx := h.db.Begin()
+
+	// Ürünlerdeki varsayılan tedarikçi bağlantısını kaldır
+	if err := tx.Model(&models.Product{}).
+		Where("supplier_id = ?", id).
+		Update("supplier_id", nil).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün bağlantıları kaldırılamadı"})
+		return
+	}
+
+	result := tx.Delete(&models.Supplier{}, id)
+	if result.Error != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Tedarikçi silinemedi"})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tedarikçi bulunamadı"})
+		return
+	}
+
+	tx.Commit()
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tedarikçi başarıyla silindi"})
+}
+
+// GetSupplierPurchases - tedarikçiden alınan tüm partileri KDV ve toplamlarıyla listeler
+func (h *SupplierHandler) GetSupplierPurchases(c *gin.Context) {
+	var supplier models.Supplier
+	if err := h.db.First(&supplier, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tedarikçi bulunamadı"})
+		return
+	}
+
+	// Arşivlenmiş partiler de alış geçmişinin parçasıdır
+	var lots []models.PurchaseLot
+	if err := h.db.Unscoped().
+		Preload("Product", withArchived).
+		Where("supplier_id = ?", supplier.ID).
+		Order("invoice_date asc").
+		Find(&lots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Alışlar listelenemedi"})
+		return
+	}
+
+	type purchaseLine struct {
+		models.PurchaseLot
+		NetAmount   float64 `json:"netAmount"`
+		VatAmount   float64 `json:"vatAmount"`
+		TotalAmount float64 `json:"totalAmount"`
+	}
+
+	var totalQuantity, totalNet, totalVat float64
+	purchases := make([]purchaseLine, 0, len(lots))
+	for _, lot := range lots {
+		// Net tutar = Birim fiyat × Miktar, KDV tutarı = Net tutar × (KDV oranı / 100)
+		net := lot.UnitPrice * lot.InitialStock
+		vat := net * (lot.VAT / 100.0)
+
+		purchases = append(purchases, purchaseLine{
+			PurchaseLot: lot,
+			NetAmount:   net,
+			VatAmount:   vat,
+			TotalAmount: net + vat,
+		})
+
+		totalQuantity += lot.InitialStock
+		totalNet += net
+		totalVat += vat
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": gin.H{
+		"supplier":  supplier,
+		"purchases": purchases,
+		"totals": gin.H{
+			"lotCount":    len(lots),
+			"quantity":    totalQuantity,
+			"netAmount":   totalNet,
+			"vatAmount":   totalVat,
+			"totalAmount": totalNet + totalVat,
+		},
+	}})
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"stock-api/internal/models"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// errUnitConversion birimin ürün birimine dönüştürülemediğini belirtir (400 döner)
+var errUnitConversion = errors.New("birim dönüştürülemez")
+
+type UnitHandler struct {
+	db *gorm.DB
+}
+
+func NewUnitHandler(db *gorm.DB) *UnitHandler {
+	return &UnitHandler{db: db}
+}
+
+// findUnit birim kaydını büyük/küçük harf duyarsız koda göre bulur
+func findUnit(db *gorm.DB, code string) (models.Unit, error) {
+	var unit models.Unit
+	err := db.Where("LOWER(code) = LOWER(?)", strings.TrimSpace(code)).First(&unit).Error
+	return unit, err
+}
+
+// unitFactor verilen birimdeki 1 miktarın ürünün kendi birimindeki karşılığını döner.
+// Önce ürüne özel ambalaj dönüşümüne, sonra aynı boyuttaki kayıtlı birimlere bakılır.
+func unitFactor(db *gorm.DB, product models.Product, unitCode string) (float64, error) {
+	unitCode = strings.TrimSpace(unitCode)
+	if unitCode == "" || strings.EqualFold(unitCode, product.Unit) {
+		return 1, nil
+	}
+
+	var pack models.ProductUnit
+	err := db.Where("product_id = ? AND LOWER(unit_code) = LOWER(?)", product.ID, unitCode).First(&pack).Error
+	if err == nil {
+		return pack.Factor, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+
+	from, err := findUnit(db, unitCode)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, fmt.Errorf("%w: %s birimi tanımlı değil", errUnitConversion, unitCode)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	to, err := findUnit(db, product.Unit)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, fmt.Errorf("%w: %s ürününün birimi (%s) tanımlı değil", errUnitConversion, product.ProductName, product.Unit)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if from.Dimension != to.Dimension {
+		return 0, fmt.Errorf("%w: %s birimi %s birimine çevrilemez", errUnitConversion, from.Code, to.Code)
+	}
+
+	return from.Factor / to.Factor, nil
+}
+
+func (h *UnitHandler) GetUnits(c *gin.Context) {
+	var units []models.Unit
+	query := h.db.Order("dimension asc, factor asc")
+
+	// Boyuta göre filtrele
+	if dimension := c.Query("dimension"); dimension != "" {
+		query = query.Where("dimension = ?", dimension)
+	}
+
+	if err := query.Find(&units).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Birimler listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": units})
+}
+
+func (h *UnitHandler) CreateUnit(c *gin.Context) {
+	var unit models.Unit
+	if err := c.ShouldBindJSON(&unit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+	unit.ID = 0
+	unit.Code = strings.ToLower(strings.TrimSpace(unit.Code))
+
+	if _, err := findUnit(h.db, unit.Code); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu kodla bir birim zaten var"})
+		return
+	}
+
+	if err := h.db.Create(&unit).Error; err != nil {
+		log.Printf("Birim oluşturma hatası: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Birim kaydedilemedi"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": unit})
+}
+
+func (h *UnitHandler) DeleteUnit(c *gin.Context) {
+	var unit models.Unit
+	if err := h.db.First(&unit, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Birim bulunamadı"})
+		return
+	}
+
+	// Ürünlerin kullandığı birim silinemez
+	var count int64
+	if err := h.db.Unscoped().Model(&models.Product{}).
+		Where("LOWER(unit) = LOWER(?)", unit.Code).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Birim kontrol edilemedi"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Birim %d üründe kullanılıyor, silinemez", count)})
+		return
+	}
+
+	if err := h.db.Delete(&unit).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Birim silinemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Birim başarıyla silindi"})
+}
+
+// GetProductUnits - ürüne özel ambalaj dönüşümlerini listeler
+func (h *UnitHandler) GetProductUnits(c *gin.Context) {
+	var productUnits []models.ProductUnit
+	if err := h.db.Where("product_id = ?", c.Param("id")).
+		Order("factor asc").
+		Find(&productUnits).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün birimleri listelenemedi"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": productUnits})
+}
+
+// CreateProductUnit - ürüne ambalaj dönüşümü ekler (1 koli = 24 adet)
+func (h *UnitHandler) CreateProductUnit(c *gin.Context) {
+	var product models.Product
+	if err := h.db.First(&product, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ürün bulunamadı"})
+		return
+	}
+
+	var productUnit models.ProductUnit
+	if err := c.ShouldBindJSON(&productUnit); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Geçersiz veri formatı: " + err.Error()})
+		return
+	}
+	productUnit.ID = 0
+	productUnit.ProductID = product.ID
+	productUnit.UnitCode = strings.ToLower(strings.TrimSpace(productUnit.UnitCode))
+
+	if strings.EqualFold(productUnit.UnitCode, product.Unit) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Ürünün kendi birimi için dönüşüm tanımlanamaz"})
+		return
+	}
+
+	var count int64
+	if err := h.db.Model(&models.ProductUnit{}).
+		Where("product_id = ? AND unit_code = ?", product.ID, productUnit.UnitCode).
+		Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün birimi kontrol edilemedi"})
+		return
+	}
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Bu ürün için bu birim zaten tanımlı"})
+		return
+	}
+
+	if err := h.db.Create(&productUnit).Error; err != nil {
+		log.Printf("Ürün birimi oluşturma hatası: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün birimi kaydedilemedi"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": productUnit})
+}
+
+func (h *UnitHandler) DeleteProductUnit(c *gin.Context) {
+	result := h.db.Where("product_id = ?", c.Param("id")).
+		Delete(&models.ProductUnit{}, c.Param("unitId"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Ürün birimi silinemedi"})
+		return
+	}
+
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Ürün birimi bulunamadı"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ürün birimi başarıyla silindi"})
+}
@@ -11,20 +11,146 @@ func SetupRouter(v1 *gin.RouterGroup, db *gorm.DB) {
 	// Product handler
 	productHandler := handlers.NewProductHandler(db)
 	v1.GET("/products", productHandler.GetProducts)
+	v1.GET("/products/average-price", productHandler.GetAveragePrice)
 	v1.GET("/products/:id", productHandler.GetProduct)
 	v1.POST("/products", productHandler.CreateProduct)
+	v1.PUT("/products/:id", productHandler.UpdateProduct)
 	v1.DELETE("/products/:id", productHandler.DeleteProduct)
+	v1.POST("/products/:id/restore", productHandler.RestoreProduct)
+
+	// Barkod handler
+	barcodeHandler := handlers.NewBarcodeHandler(db)
+	v1.GET("/products/lookup", barcodeHandler.LookupProduct)
+	v1.POST("/products/:id/barcodes", barcodeHandler.AddBarcode)
+	v1.DELETE("/products/:id/barcodes/:barcodeId", barcodeHandler.DeleteBarcode)
+
+	// Alış partisi handler
+	purchaseLotHandler := handlers.NewPurchaseLotHandler(db)
+	v1.POST("/products/:id/lots", purchaseLotHandler.CreateLot)
+	v1.GET("/products/:id/lots", purchaseLotHandler.GetLots)
+	v1.GET("/lots/:id", purchaseLotHandler.GetLot)
+	v1.PATCH("/lots/:id", purchaseLotHandler.UpdateLot)
+	v1.DELETE("/lots/:id", purchaseLotHandler.DeleteLot)
+	v1.POST("/lots/:id/restore", purchaseLotHandler.RestoreLot)
+
+	// Tedarikçi handler
+	supplierHandler := handlers.NewSupplierHandler(db)
+	v1.POST("/suppliers", supplierHandler.CreateSupplier)
+	v1.GET("/suppliers", supplierHandler.GetSuppliers)
+	v1.GET("/suppliers/:id", supplierHandler.GetSupplier)
+	v1.PUT("/suppliers/:id", supplierHandler.UpdateSupplier)
+	v1.DELETE("/suppliers/:id", supplierHandler.DeleteSupplier)
+	v1.GET("/suppliers/:id/purchases", supplierHandler.GetSupplierPurchases)
+
+	// Müşteri handler
+	customerHandler := handlers.NewCustomerHandler(db)
+	v1.POST("/customers", customerHandler.CreateCustomer)
+	v1.GET("/customers", customerHandler.GetCustomers)
+	v1.GET("/customers/:id", customerHandler.GetCustomer)
+	v1.PUT("/customers/:id", customerHandler.UpdateCustomer)
+	v1.DELETE("/customers/:id", customerHandler.DeleteCustomer)
+	v1.GET("/customers/:id/sales", customerHandler.GetCustomerSales)
+
+	// Alış faturası handler
+	purchaseInvoiceHandler := handlers.NewPurchaseInvoiceHandler(db)
+	v1.POST("/purchase-invoices", purchaseInvoiceHandler.CreatePurchaseInvoice)
+	v1.GET("/purchase-invoices", purchaseInvoiceHandler.GetPurchaseInvoices)
+	v1.GET("/purchase-invoices/:id", purchaseInvoiceHandler.GetPurchaseInvoice)
+
+	// Birim handler
+	unitHandler := handlers.NewUnitHandler(db)
+	v1.GET("/units", unitHandler.GetUnits)
+	v1.POST("/units", unitHandler.CreateUnit)
+	v1.DELETE("/units/:id", unitHandler.DeleteUnit)
+	v1.GET("/products/:id/units", unitHandler.GetProductUnits)
+	v1.POST("/products/:id/units", unitHandler.CreateProductUnit)
+	v1.DELETE("/products/:id/units/:unitId", unitHandler.DeleteProductUnit)
+
+	// Kategori handler
+	categoryHandler := handlers.NewCategoryHandler(db)
+	v1.POST("/categories", categoryHandler.CreateCategory)
+	v1.GET("/categories", categoryHandler.GetCategories)
+	v1.GET("/categories/:id", categoryHandler.GetCategory)
+	v1.PUT("/categories/:id", categoryHandler.UpdateCategory)
+	v1.POST("/categories/:id/move", categoryHandler.MoveCategory)
+	v1.DELETE("/categories/:id", categoryHandler.DeleteCategory)
+
+	// Rapor handler
+	reportHandler := handlers.NewReportHandler(db)
+	v1.GET("/reports/categories", reportHandler.GetCategoryReport)
+	v1.GET("/inventory/as-of", reportHandler.GetInventoryAsOf)
+	v1.GET("/inventory/journal", reportHandler.GetInventoryJournal)
 
 	// Sale handler
 	saleHandler := handlers.NewSaleHandler(db)
 	v1.GET("/sales", saleHandler.GetSales)
 	v1.POST("/sales", saleHandler.CreateSale)
+	v1.PATCH("/sales/:id", saleHandler.UpdateSale)
 	v1.DELETE("/sales/:id", saleHandler.DeleteSale)
+	v1.GET("/sales/:id/revisions", saleHandler.GetSaleRevisions)
 	v1.POST("/recipe-sales", saleHandler.CreateRecipeSale)
 
+	// Satış iadesi handler
+	saleReturnHandler := handlers.NewSaleReturnHandler(db)
+	v1.POST("/sales/:id/returns", saleReturnHandler.CreateSaleReturn)
+	v1.GET("/sales/:id/returns", saleReturnHandler.GetSaleReturns)
+
 	// Stock movement handler
 	stockMovementHandler := handlers.NewStockMovementHandler(db)
 	v1.GET("/stock-movements", stockMovementHandler.GetStockMovements)
+	v1.GET("/stock/expiring", stockMovementHandler.GetExpiringStock)
+
+	// Stok düzeltme handler
+	stockAdjustmentHandler := handlers.NewStockAdjustmentHandler(db)
+	v1.POST("/stock-adjustments", stockAdjustmentHandler.CreateAdjustment)
+	v1.GET("/stock-adjustments", stockAdjustmentHandler.GetAdjustments)
+	v1.GET("/stock-adjustments/:id", stockAdjustmentHandler.GetAdjustment)
+
+	// Konum handler
+	locationHandler := handlers.NewLocationHandler(db)
+	v1.GET("/locations", locationHandler.GetLocations)
+	v1.POST("/locations", locationHandler.CreateLocation)
+	v1.PUT("/locations/:id", locationHandler.UpdateLocation)
+	v1.DELETE("/locations/:id", locationHandler.DeleteLocation)
+	v1.GET("/products/:id/location-levels", locationHandler.GetProductLevels)
+	v1.PUT("/products/:id/location-levels", locationHandler.SetProductLevel)
+
+	// Stok transferi handler
+	stockTransferHandler := handlers.NewStockTransferHandler(db)
+	v1.POST("/stock-transfers", stockTransferHandler.CreateTransfer)
+	v1.GET("/stock-transfers", stockTransferHandler.GetTransfers)
+	v1.GET("/stock-transfers/in-transit", stockTransferHandler.GetInTransit)
+	v1.GET("/stock-transfers/:id", stockTransferHandler.GetTransfer)
+	v1.POST("/stock-transfers/:id/ship", stockTransferHandler.ShipTransfer)
+	v1.POST("/stock-transfers/:id/receive", stockTransferHandler.ReceiveTransfer)
+	v1.POST("/stock-transfers/:id/cancel", stockTransferHandler.CancelTransfer)
+
+	// Sayım handler
+	countSessionHandler := handlers.NewCountSessionHandler(db)
+	v1.POST("/count-sessions", countSessionHandler.OpenSession)
+	v1.GET("/count-sessions", countSessionHandler.GetSessions)
+	v1.GET("/count-sessions/:id", countSessionHandler.GetSession)
+	v1.POST("/count-sessions/:id/counts", countSessionHandler.SubmitCounts)
+	v1.POST("/count-sessions/:id/approve", countSessionHandler.ApproveSession)
+	v1.POST("/count-sessions/:id/cancel", countSessionHandler.CancelSession)
+
+	// Rezervasyon handler
+	reservationHandler := handlers.NewReservationHandler(db)
+	v1.POST("/reservations", reservationHandler.CreateReservation)
+	v1.GET("/reservations", reservationHandler.GetReservations)
+	v1.GET("/reservations/:id", reservationHandler.GetReservation)
+	v1.POST("/reservations/:id/release", reservationHandler.ReleaseReservation)
+
+	// Satış siparişi handler
+	salesOrderHandler := handlers.NewSalesOrderHandler(db)
+	v1.POST("/sales-orders", salesOrderHandler.CreateSalesOrder)
+	v1.GET("/sales-orders", salesOrderHandler.GetSalesOrders)
+	v1.GET("/sales-orders/:id", salesOrderHandler.GetSalesOrder)
+	v1.DELETE("/sales-orders/:id", salesOrderHandler.DeleteSalesOrder)
+
+	// Uyarı handler
+	alertHandler := handlers.NewAlertHandler(db)
+	v1.GET("/alerts/low-stock", alertHandler.GetLowStock)
 
 	// Reçete endpoint'leri
 	recipeHandler := handlers.NewRecipeHandler(db)
@@ -1,19 +1,109 @@
 package database
 
 import (
+	"errors"
+	"stock-api/internal/inventory"
 	"stock-api/internal/models"
+	"strings"
 
 	"log"
 
+	"github.com/mattn/go-sqlite3"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
+// dsn veritabanı dosyası ve bağlantı ayarlarıdır. Kilitli veritabanında sorgular hata
+// vermeden önce 5 saniyeye kadar bekler; transaction'lar yazma kilidini baştan alır
+// (BEGIN IMMEDIATE), böylece aynı anda stok düşen iki istek sırayla çalışır ve okuma
+// kilidini yazmaya yükseltirken birbirini kilitlemez.
+const dsn = "stock.db?_busy_timeout=5000&_txlock=immediate"
+
+// journalTriggers envanter defterindeki kayıtların veritabanı düzeyinde de
+// değiştirilmesini ve silinmesini engeller
+var journalTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS inventory_entries_no_update BEFORE UPDATE ON inventory_entries
+	BEGIN SELECT RAISE(ABORT, 'envanter kayıtları değiştirilemez'); END`,
+	`CREATE TRIGGER IF NOT EXISTS inventory_entries_no_delete BEFORE DELETE ON inventory_entries
+	BEGIN SELECT RAISE(ABORT, 'envanter kayıtları değiştirilemez'); END`,
+}
+
+// productNameKeyIndex aynı isimde iki aktif ürün açılmasını veritabanı düzeyinde engeller;
+// arşivlenmiş ürünler ve anahtarı atanmamış eski kayıtlar indekse girmez
+const productNameKeyIndex = `CREATE UNIQUE INDEX IF NOT EXISTS idx_products_name_key
+	ON products(name_key) WHERE deleted_at IS NULL AND name_key != ''`
+
+// customerIndexes aynı telefon ya da vergi numarasıyla iki müşteri kaydını veritabanı
+// düzeyinde engeller; boş değerler indekse girmez
+var customerIndexes = []string{
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_customers_phone_unique
+	ON customers(phone) WHERE phone != ''`,
+	`CREATE UNIQUE INDEX IF NOT EXISTS idx_customers_tax_number_unique
+	ON customers(tax_number) WHERE tax_number != ''`,
+}
+
+// backfillProductNameKeys anahtarı olmayan ürünlere ad anahtarı atar. Eski kayıtlarda aynı
+// anahtarı paylaşan aktif ürünlerden yalnızca ilkine anahtar verilir; diğerleri
+// birleştirilene ya da güncellenene kadar boş kalır
+func backfillProductNameKeys(db *gorm.DB) error {
+	var products []models.Product
+	if err := db.Unscoped().Select("id", "product_name", "deleted_at").
+		Where("name_key IS NULL OR name_key = ''").
+		Order("id").
+		Find(&products).Error; err != nil {
+		return err
+	}
+
+	for _, product := range products {
+		key := models.ProductNameKey(product.ProductName)
+		if key == "" {
+			continue
+		}
+		if !product.DeletedAt.Valid {
+			var count int64
+			if err := db.Model(&models.Product{}).Where("name_key = ?", key).Count(&count).Error; err != nil {
+				return err
+			}
+			if count > 0 {
+				log.Printf("Ürün %d (%s) aynı isimli aktif bir ürünle çakışıyor, ad anahtarı atanmadı", product.ID, product.ProductName)
+				continue
+			}
+		}
+		if err := db.Unscoped().Model(&models.Product{}).
+			Where("id = ?", product.ID).
+			UpdateColumn("name_key", key).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsBusy hatanın veritabanının başka bir bağlantı tarafından kilitli olmasından
+// kaynaklandığını belirtir; bu durumda işlem tekrar denenebilir
+func IsBusy(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+// IsUniqueViolation hatanın verilen sütundaki (ör. "products.name_key") tekillik
+// kısıtının ihlalinden kaynaklandığını belirtir
+func IsUniqueViolation(err error, column string) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+			strings.Contains(sqliteErr.Error(), column)
+	}
+	return false
+}
+
 func InitDB() (*gorm.DB, error) {
 	log.Println("Veritabanı başlatılıyor...")
 
 	// SQLite bağlantısı
-	db, err := gorm.Open(sqlite.Open("stock.db"), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
 	if err != nil {
 		log.Printf("Veritabanı bağlantı hatası: %v", err)
 		return nil, err
@@ -21,17 +111,109 @@ func InitDB() (*gorm.DB, error) {
 
 	// Auto Migration
 	err = db.AutoMigrate(
+		&models.Category{},
+		&models.Supplier{},
 		&models.Product{},
+		&models.PurchaseInvoice{},
+		&models.PurchaseLot{},
 		&models.Sale{},
 		&models.StockMovement{},
 		&models.StockUsage{},
 		&models.Recipe{},
 		&models.RecipeItem{},
+		&models.Unit{},
+		&models.ProductUnit{},
+		&models.ProductBarcode{},
+		&models.StockAdjustment{},
+		&models.CountSession{},
+		&models.CountLine{},
+		&models.Location{},
+		&models.ProductLocationLevel{},
+		&models.StockTransfer{},
+		&models.StockTransferLine{},
+		&models.StockTransferItem{},
+		&models.StockTransferDiscrepancy{},
+		&models.Reservation{},
+		&models.InventoryEntry{},
+		&models.SalesOrder{},
+		&models.Customer{},
+		&models.CustomerAddress{},
+		&models.SaleReturn{},
+		&models.SaleReturnLine{},
+		&models.SaleRevision{},
+		&models.SaleRevisionChange{},
+		&models.Production{},
 	)
 	if err != nil {
 		log.Printf("Migration hatası: %v", err)
 		return nil, err
 	}
 
+	// Varsayılan birimleri ekle
+	var unitCount int64
+	if err := db.Model(&models.Unit{}).Count(&unitCount).Error; err != nil {
+		log.Printf("Birim kontrol hatası: %v", err)
+		return nil, err
+	}
+	if unitCount == 0 {
+		units := models.DefaultUnits()
+		if err := db.Create(&units).Error; err != nil {
+			log.Printf("Varsayılan birimler eklenemedi: %v", err)
+			return nil, err
+		}
+	}
+
+	// Varsayılan konumu ekle; konumsuz eski stok hareketleri ve partiler bu konuma aittir
+	var location models.Location
+	if err := db.Where("is_default = ?", true).First(&location).Error; err != nil {
+		location = models.DefaultLocation()
+		if err := db.Create(&location).Error; err != nil {
+			log.Printf("Varsayılan konum eklenemedi: %v", err)
+			return nil, err
+		}
+	}
+	if err := db.Model(&models.StockMovement{}).Unscoped().
+		Where("location_id IS NULL OR location_id = 0").
+		Update("location_id", location.ID).Error; err != nil {
+		log.Printf("Stok hareketlerine konum atanamadı: %v", err)
+		return nil, err
+	}
+	if err := db.Model(&models.PurchaseLot{}).Unscoped().
+		Where("location_id IS NULL").
+		Update("location_id", location.ID).Error; err != nil {
+		log.Printf("Alış partilerine konum atanamadı: %v", err)
+		return nil, err
+	}
+
+	// Ad anahtarları doldurulduktan sonra aktif ürünlerde tekil tutulur
+	if err := backfillProductNameKeys(db); err != nil {
+		log.Printf("Ürün ad anahtarları oluşturulamadı: %v", err)
+		return nil, err
+	}
+	if err := db.Exec(productNameKeyIndex).Error; err != nil {
+		log.Printf("Ürün ad anahtarı indeksi oluşturulamadı: %v", err)
+		return nil, err
+	}
+
+	for _, index := range customerIndexes {
+		if err := db.Exec(index).Error; err != nil {
+			log.Printf("Müşteri indeksi oluşturulamadı: %v", err)
+			return nil, err
+		}
+	}
+
+	for _, trigger := range journalTriggers {
+		if err := db.Exec(trigger).Error; err != nil {
+			log.Printf("Envanter defteri tetikleyicisi oluşturulamadı: %v", err)
+			return nil, err
+		}
+	}
+
+	// Defter boşsa mevcut stok açılış kayıtlarıyla deftere aktarılır
+	if err := db.Transaction(inventory.BackfillJournal); err != nil {
+		log.Printf("Envanter defteri oluşturulamadı: %v", err)
+		return nil, err
+	}
+
 	return db, nil
 }
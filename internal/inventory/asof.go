@@ -0,0 +1,93 @@
+package inventory
+
+import (
+	"stock-api/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// LotBalance bir stok hareketinin (partinin bir konumdaki parçasının) geçmiş bir andaki
+// miktarı ve defter kayıtlarındaki maliyetle değeridir
+type LotBalance struct {
+	StockMovementID uint       `json:"stockMovementId"`
+	ProductID       uint       `json:"productId"`
+	LocationID      uint       `json:"locationId"`
+	PurchaseLotID   *uint      `json:"purchaseLotId,omitempty"`
+	Type            string     `json:"type"`
+	MovementDate    time.Time  `json:"movementDate"`
+	ExpiryDate      *time.Time `json:"expiryDate,omitempty"`
+	UnitCost        float64    `json:"unitCost"`
+	Quantity        float64    `json:"quantity"`
+	Value           float64    `json:"value"`
+}
+
+// TransitBalance geçmiş bir anda sevk edilmiş ama henüz teslim alınmamış transfer partisidir
+type TransitBalance struct {
+	StockTransferID uint    `json:"stockTransferId"`
+	ProductID       uint    `json:"productId"`
+	FromLocationID  uint    `json:"fromLocationId"`
+	ToLocationID    uint    `json:"toLocationId"`
+	PurchaseLotID   *uint   `json:"purchaseLotId,omitempty"`
+	UnitCost        float64 `json:"unitCost"`
+	Quantity        float64 `json:"quantity"`
+	Value           float64 `json:"value"`
+}
+
+// usageDate stok kullanımının gerçekleştiği tarihtir: satış tarihi, düzeltme tarihi,
+// transferin sevk tarihi ya da üretim tarihi
+const usageDate = "COALESCE(sa.adjustment_date, st.shipped_at, p.production_date, s.sale_date)"
+
+// BalancesAt partilerin verilen andaki miktarlarını, o andan önce gerçekleşen defter
+// kayıtlarının toplamıyla hesaplar. Defter yalnızca eklenerek büyüdüğünden parti ya da
+// satış düzeltmeleri geçmiş bakiyeleri değiştirmez; düzeltme yapıldığı tarihte işlenir.
+// Partiler arşivlendikleri ana kadar bakiyeye dahildir; stok düzeltmesi çıkışlarının
+// kayıt amaçlı negatif hareketlerinin defter kaydı yoktur. Süresi geçmiş partiler değere
+// dahildir. Değer de kayıtların değerlerinin toplamıdır; partinin sonradan düzeltilen
+// maliyeti geçmiş değerleri değiştirmez. locationID 0 tüm konumlar demektir.
+func BalancesAt(tx *gorm.DB, at time.Time, locationID uint) ([]LotBalance, error) {
+	entries := tx.Model(&models.InventoryEntry{}).
+		Select("stock_movement_id, SUM(quantity) AS quantity, SUM(value) AS value").
+		Where("occurred_at < ?", at).
+		Group("stock_movement_id")
+
+	var balances []LotBalance
+	err := AtLocation(tx.Unscoped().Model(&models.StockMovement{}), locationID).
+		Select("stock_movements.id AS stock_movement_id, product_id, location_id, purchase_lot_id, type, "+
+			"movement_date, expiry_date, e.quantity AS quantity, e.value AS value").
+		Joins("JOIN (?) e ON e.stock_movement_id = stock_movements.id", entries).
+		Where("stock_movements.deleted_at IS NULL OR stock_movements.deleted_at > ?", at).
+		Order("product_id asc, movement_date asc, stock_movements.id asc").
+		Scan(&balances).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := balances[:0]
+	for _, balance := range balances {
+		if balance.Quantity <= quantityEpsilon {
+			continue
+		}
+		balance.UnitCost = balance.Value / balance.Quantity
+		result = append(result, balance)
+	}
+	return result, nil
+}
+
+// InTransitAt verilen anda yolda olan (sevk edilmiş, teslim alınmamış) transfer partilerini döner
+func InTransitAt(tx *gorm.DB, at time.Time) ([]TransitBalance, error) {
+	var balances []TransitBalance
+	err := tx.Table("stock_transfer_items").
+		Select("stock_transfers.id AS stock_transfer_id, stock_transfer_lines.product_id, "+
+			"stock_transfers.from_location_id, stock_transfers.to_location_id, "+
+			"stock_transfer_items.purchase_lot_id, stock_transfer_items.unit_cost, "+
+			"stock_transfer_items.quantity, stock_transfer_items.quantity * stock_transfer_items.unit_cost AS value").
+		Joins("JOIN stock_transfer_lines ON stock_transfer_lines.id = stock_transfer_items.stock_transfer_line_id").
+		Joins("JOIN stock_transfers ON stock_transfers.id = stock_transfer_lines.stock_transfer_id").
+		Where("stock_transfers.status IN ?", []string{models.TransferShipped, models.TransferReceived}).
+		Where("stock_transfers.shipped_at < ?", at).
+		Where("stock_transfers.received_at IS NULL OR stock_transfers.received_at >= ?", at).
+		Order("stock_transfers.id asc, stock_transfer_items.id asc").
+		Scan(&balances).Error
+	return balances, err
+}
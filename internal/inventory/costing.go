@@ -0,0 +1,254 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"stock-api/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrLotRequired belirli parti yöntemindeki üründe parti seçilmediğini belirtir
+	ErrLotRequired = errors.New("parti seçimi gerekli")
+	// ErrLotUnavailable seçilen partinin ürüne ait olmadığını, arşivlendiğini veya süresinin geçtiğini belirtir
+	ErrLotUnavailable = errors.New("seçilen parti kullanılamaz")
+)
+
+// Quote bir miktarın hangi partilerden hangi maliyetle karşılanacağıdır
+type Quote struct {
+	Method   string      `json:"method"`
+	Quantity float64     `json:"quantity"`
+	Cost     float64     `json:"cost"`
+	Lines    []QuoteLine `json:"lines"`
+}
+
+// QuoteLine tek bir partiden karşılanan miktardır
+type QuoteLine struct {
+	StockMovementID uint    `json:"stockMovementId"`
+	LocationID      uint    `json:"locationId"`
+	PurchaseLotID   *uint   `json:"purchaseLotId,omitempty"`
+	Quantity        float64 `json:"quantity"`
+	UnitCost        float64 `json:"unitCost"`
+}
+
+// UnitCost teklifin birim maliyetidir
+func (q Quote) UnitCost() float64 {
+	if q.Quantity == 0 {
+		return 0
+	}
+	return q.Cost / q.Quantity
+}
+
+// CostingMethod ürünün maliyet yöntemini döner: ürünün kendi yöntemi, yoksa en yakın
+// üst kategorinin yöntemi, hiçbiri yoksa FIFO
+func CostingMethod(tx *gorm.DB, product models.Product) (string, error) {
+	if product.CostingMethod != "" {
+		return product.CostingMethod, nil
+	}
+	if product.CategoryID == nil {
+		return models.CostingFIFO, nil
+	}
+
+	var category models.Category
+	if err := tx.First(&category, *product.CategoryID).Error; err != nil {
+		return "", err
+	}
+
+	var ancestors []models.Category
+	if err := tx.Where("costing_method != '' AND ? LIKE path || '%'", category.Path).
+		Order("LENGTH(path) desc").
+		Limit(1).
+		Find(&ancestors).Error; err != nil {
+		return "", err
+	}
+	if len(ancestors) == 0 {
+		return models.CostingFIFO, nil
+	}
+	return ancestors[0].CostingMethod, nil
+}
+
+// Movements ürünün verilen konumda (0 tüm konumlar) ve tarihte tüketilebilir stok hareketlerini tüketim sırasıyla döner.
+// Son kullanma tarihi geçmiş partiler hariç tutulur. Bozulabilir ürünlerde önce son kullanma
+// tarihi en yakın parti gelir (FEFO); alış tarihine göre sıra LIFO'da yeniden eskiye,
+// diğer yöntemlerde eskiden yeniyedir.
+func Movements(tx *gorm.DB, product models.Product, locationID uint, method string, at time.Time) ([]models.StockMovement, error) {
+	fefo, err := IsPerishable(tx, product)
+	if err != nil {
+		return nil, err
+	}
+
+	order := "sm.movement_date ASC"
+	if method == models.CostingLIFO {
+		order = "sm.movement_date DESC"
+	}
+	if fefo {
+		order = "sm.expiry_date IS NULL, sm.expiry_date ASC, " + order
+	}
+
+	var movements []models.StockMovement
+	err = tx.Raw(`
+		SELECT sm.*
+		FROM stock_movements sm
+		WHERE sm.product_id = ?
+		AND (? = 0 OR sm.location_id = ?)
+		AND sm.remaining_quantity > 0
+		AND sm.deleted_at IS NULL
+		AND (sm.expiry_date IS NULL OR sm.expiry_date >= ?)
+		ORDER BY `+order, product.ID, locationID, locationID, ExpiryDay(at)).
+		Scan(&movements).Error
+	return movements, err
+}
+
+// lotMovements seçilen alış partisinin verilen konumdaki tüketilebilir stok hareketini döner
+func lotMovements(tx *gorm.DB, product models.Product, lotID, locationID uint, at time.Time) ([]models.StockMovement, error) {
+	var movements []models.StockMovement
+	err := AtLocation(tx, locationID).Where("product_id = ? AND purchase_lot_id = ?", product.ID, lotID).
+		Where("expiry_date IS NULL OR expiry_date >= ?", ExpiryDay(at)).
+		Find(&movements).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(movements) == 0 {
+		return nil, fmt.Errorf("%w: %s için %d numaralı parti", ErrLotUnavailable, product.ProductName, lotID)
+	}
+	return movements, nil
+}
+
+// estimateMovement miktarın yalnızca verilen stok hareketinden, hareketin maliyetiyle
+// karşılanmasını hesaplar. Son kullanma tarihine bakılmaz; arşivlenmiş hareket kullanılamaz.
+func estimateMovement(tx *gorm.DB, product models.Product, quantity float64, movementID uint) (Quote, error) {
+	quote := Quote{Method: models.CostingSpecific, Quantity: quantity}
+
+	var movement models.StockMovement
+	err := tx.Where("product_id = ?", product.ID).First(&movement, movementID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return quote, fmt.Errorf("%w: %s için %d numaralı stok hareketi", ErrLotUnavailable, product.ProductName, movementID)
+	}
+	if err != nil {
+		return quote, err
+	}
+	if movement.RemainingQuantity < quantity {
+		return quote, &ShortageError{
+			ProductID:   product.ID,
+			ProductName: product.ProductName,
+			Requested:   quantity,
+			Available:   movement.RemainingQuantity,
+		}
+	}
+
+	quote.Lines = []QuoteLine{{
+		StockMovementID: movement.ID,
+		LocationID:      movement.LocationID,
+		PurchaseLotID:   movement.PurchaseLotID,
+		Quantity:        quantity,
+		UnitCost:        movement.UnitCost,
+	}}
+	quote.Cost = quantity * movement.UnitCost
+	return quote, nil
+}
+
+// Estimate talebin ürünün maliyet yöntemiyle hangi partilerden ve hangi maliyetle
+// karşılanacağını hesaplar; hiçbir kayıt değiştirmez. Talepte parti veya stok hareketi
+// verilirse yöntem ne olursa olsun yalnızca o kullanılır.
+//
+// Ağırlıklı ortalamada miktar tüketilebilir partilere kalanlarıyla orantılı dağıtılır;
+// böylece her satış o anki ortalama maliyetten yapılır ve kalan partilerin maliyet
+// toplamı her yöntemde stok değerine eşit kalır.
+func Estimate(tx *gorm.DB, product models.Product, demand Demand, at time.Time) (Quote, error) {
+	quantity := demand.Quantity
+	if demand.MovementID != nil {
+		return estimateMovement(tx, product, quantity, *demand.MovementID)
+	}
+
+	method := demand.Method
+	if method == "" {
+		var err error
+		if method, err = CostingMethod(tx, product); err != nil {
+			return Quote{}, err
+		}
+	}
+	if demand.LotID != nil {
+		method = models.CostingSpecific
+	}
+	quote := Quote{Method: method, Quantity: quantity}
+
+	var movements []models.StockMovement
+	var err error
+	switch {
+	case demand.LotID != nil:
+		movements, err = lotMovements(tx, product, *demand.LotID, demand.LocationID, at)
+	case method == models.CostingSpecific:
+		return quote, fmt.Errorf("%w: %s", ErrLotRequired, product.ProductName)
+	default:
+		movements, err = Movements(tx, product, demand.LocationID, method, at)
+	}
+	if err != nil {
+		return quote, err
+	}
+
+	var available float64
+	for _, m := range movements {
+		available += m.RemainingQuantity
+	}
+	if available < quantity {
+		return quote, &ShortageError{
+			ProductID:   product.ID,
+			ProductName: product.ProductName,
+			Requested:   quantity,
+			Available:   available,
+		}
+	}
+
+	remaining := quantity
+	for i, m := range movements {
+		if remaining <= 0 {
+			break
+		}
+
+		use := math.Min(remaining, m.RemainingQuantity)
+		if method == models.CostingAverage && i < len(movements)-1 {
+			use = math.Min(remaining, quantity*m.RemainingQuantity/available)
+		}
+		if use <= 0 {
+			continue
+		}
+
+		quote.Lines = append(quote.Lines, QuoteLine{
+			StockMovementID: m.ID,
+			LocationID:      m.LocationID,
+			PurchaseLotID:   m.PurchaseLotID,
+			Quantity:        use,
+			UnitCost:        m.UnitCost,
+		})
+		quote.Cost += use * m.UnitCost
+		remaining -= use
+	}
+
+	return quote, nil
+}
+
+// Valuation arşivlenmemiş partilerdeki stoğun ürün bazında maliyet değerini döner.
+// Tüm maliyet yöntemleri partilerin kalan miktarı üzerinden tüketim yaptığından stok
+// değeri kalan miktar × parti maliyetidir; süresi geçmiş partiler de değere dahildir.
+func Valuation(tx *gorm.DB) (map[uint]float64, error) {
+	var rows []struct {
+		ProductID  uint
+		StockValue float64
+	}
+	if err := tx.Model(&models.StockMovement{}).
+		Select("product_id, SUM(remaining_quantity * unit_cost) AS stock_value").
+		Where("remaining_quantity > 0").
+		Group("product_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	values := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		values[row.ProductID] = row.StockValue
+	}
+	return values, nil
+}
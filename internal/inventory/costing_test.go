@@ -0,0 +1,131 @@
+package inventory
+
+import (
+	"errors"
+	"stock-api/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCostingMethodInheritance(t *testing.T) {
+	db := newTestDB(t)
+
+	bulk := models.Category{Name: "Dökme", Path: "/1/", CostingMethod: models.CostingAverage}
+	assert.NoError(t, db.Create(&bulk).Error)
+	grain := models.Category{Name: "Tahıl", ParentID: &bulk.ID, Path: "/1/2/"}
+	assert.NoError(t, db.Create(&grain).Error)
+
+	product := createProduct(t, db, &grain.ID)
+	method, err := CostingMethod(db, product)
+	assert.NoError(t, err)
+	assert.Equal(t, models.CostingAverage, method)
+
+	// Ürünün kendi yöntemi kategoriden önce gelir
+	product.CostingMethod = models.CostingLIFO
+	method, err = CostingMethod(db, product)
+	assert.NoError(t, err)
+	assert.Equal(t, models.CostingLIFO, method)
+
+	method, err = CostingMethod(db, createProduct(t, db, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, models.CostingFIFO, method)
+}
+
+func TestAllocateLIFO(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	product := createProduct(t, db, nil,
+		models.StockMovement{RemainingQuantity: 5, UnitCost: 1, MovementDate: now.AddDate(0, 0, -2)},
+		models.StockMovement{RemainingQuantity: 5, UnitCost: 2, MovementDate: now.AddDate(0, 0, -1)},
+	)
+	db.Model(&product).Update("costing_method", models.CostingLIFO)
+
+	tx := db.Begin()
+	_, cost, err := Allocate(tx, SaleRef(1), []Demand{{ProductID: product.ID, Quantity: 6}}, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	assert.Equal(t, 11.0, cost)
+	assert.Equal(t, []float64{4, 0}, remaining(t, db, product.ID))
+}
+
+// Transferler ürünün maliyet yönteminden bağımsız olarak FIFO sırasıyla düşer
+func TestAllocateMethodOverride(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	product := createProduct(t, db, nil,
+		models.StockMovement{RemainingQuantity: 5, UnitCost: 1, MovementDate: now.AddDate(0, 0, -2)},
+		models.StockMovement{RemainingQuantity: 5, UnitCost: 2, MovementDate: now.AddDate(0, 0, -1)},
+	)
+	db.Model(&product).Update("costing_method", models.CostingLIFO)
+
+	tx := db.Begin()
+	usages, cost, err := Allocate(tx, TransferRef(1), []Demand{{ProductID: product.ID, Quantity: 6, Method: models.CostingFIFO}}, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	assert.Equal(t, 7.0, cost)
+	assert.Equal(t, []float64{0, 4}, remaining(t, db, product.ID))
+	if assert.Len(t, usages, 2) {
+		assert.Equal(t, uint(1), *usages[0].StockTransferID)
+	}
+}
+
+func TestAllocateWeightedAverage(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	product := createProduct(t, db, nil,
+		models.StockMovement{RemainingQuantity: 10, UnitCost: 1, MovementDate: now.AddDate(0, 0, -2)},
+		models.StockMovement{RemainingQuantity: 30, UnitCost: 3, MovementDate: now.AddDate(0, 0, -1)},
+	)
+	db.Model(&product).Update("costing_method", models.CostingAverage)
+
+	average, err := AverageCost(db, product.ID, 0, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.5, average)
+
+	tx := db.Begin()
+	_, cost, err := Allocate(tx, SaleRef(1), []Demand{{ProductID: product.ID, Quantity: 8}}, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	// Partilerden kalanlarıyla orantılı düşülür; ortalama maliyet değişmez
+	assert.InDelta(t, 20.0, cost, 1e-9)
+	assert.InDeltaSlice(t, []float64{8, 24}, remaining(t, db, product.ID), 1e-9)
+
+	average, err = AverageCost(db, product.ID, 0, now)
+	assert.NoError(t, err)
+	assert.InDelta(t, 2.5, average, 1e-9)
+}
+
+func TestAllocateSpecificLot(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	lotA, lotB := uint(11), uint(12)
+	product := createProduct(t, db, nil,
+		models.StockMovement{PurchaseLotID: &lotA, RemainingQuantity: 5, UnitCost: 1, MovementDate: now.AddDate(0, 0, -2)},
+		models.StockMovement{PurchaseLotID: &lotB, RemainingQuantity: 5, UnitCost: 4, MovementDate: now.AddDate(0, 0, -1)},
+	)
+	db.Model(&product).Update("costing_method", models.CostingSpecific)
+
+	tx := db.Begin()
+	_, _, err := Allocate(tx, SaleRef(1), []Demand{{ProductID: product.ID, Quantity: 2}}, now)
+	tx.Rollback()
+	assert.True(t, errors.Is(err, ErrLotRequired))
+
+	tx = db.Begin()
+	_, cost, err := Allocate(tx, SaleRef(1), []Demand{{ProductID: product.ID, Quantity: 2, LotID: &lotB}}, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	assert.Equal(t, 8.0, cost)
+	assert.Equal(t, []float64{5, 3}, remaining(t, db, product.ID))
+
+	// Seçilen partide yeterli miktar yoksa diğer partiye geçilmez
+	tx = db.Begin()
+	_, _, err = Allocate(tx, SaleRef(2), []Demand{{ProductID: product.ID, Quantity: 4, LotID: &lotB}}, now)
+	tx.Rollback()
+	assert.True(t, errors.Is(err, ErrInsufficientStock))
+}
@@ -0,0 +1,489 @@
+// Package inventory satış ve reçete tüketimlerinin stok partilerinden (StockMovement)
+// düşülmesini ve iadesini yönetir. Tüm işlemler çağıranın transaction'ı içinde çalışır;
+// commit ve rollback çağırana aittir. Miktarlar her zaman ürünün kendi birimindedir.
+package inventory
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"stock-api/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrInsufficientStock talep edilen miktarın kullanılabilir stoğu aştığını belirtir
+var ErrInsufficientStock = errors.New("yetersiz stok")
+
+// ErrConcurrentUpdate partilerin eşzamanlı işlemlerce tekrar tekrar tüketildiğini ve
+// düşümün tamamlanamadığını belirtir; işlem baştan denenebilir
+var ErrConcurrentUpdate = errors.New("stok eşzamanlı olarak güncellendi, işlemi tekrar deneyin")
+
+// quantityEpsilon kayan nokta miktar karşılaştırmalarındaki yuvarlama payıdır
+const quantityEpsilon = 1e-9
+
+// maxAllocationAttempts bir talebin partileri yeniden seçilerek kaç kez deneneceğidir
+const maxAllocationAttempts = 3
+
+// ShortageError hangi ürünün ne kadar eksik kaldığını taşır; errors.Is ile
+// ErrInsufficientStock olarak yakalanır
+type ShortageError struct {
+	ProductID   uint
+	ProductName string
+	Requested   float64
+	Available   float64
+}
+
+func (e *ShortageError) Error() string {
+	return "Yetersiz stok: " + e.ProductName
+}
+
+func (e *ShortageError) Is(target error) bool {
+	return target == ErrInsufficientStock
+}
+
+// Demand bir üründen düşülecek miktardır (ürün biriminde); LotID verilirse
+// miktar yalnızca o alış partisinden, MovementID verilirse yalnızca o stok
+// hareketinden düşülür. Hareket bazlı talepler sayım düzeltmeleri içindir ve
+// son kullanma tarihi geçmiş partilerden de düşebilir. LocationID verilirse yalnızca
+// o konumdaki partiler kullanılır; 0 tüm konumlar demektir. Method verilirse ürünün
+// maliyet yöntemi yerine o yöntemin tüketim sırası kullanılır.
+type Demand struct {
+	ProductID  uint
+	Quantity   float64
+	LotID      *uint
+	MovementID *uint
+	LocationID uint
+	Method     string
+}
+
+// Ref stok kullanımlarının bağlandığı kaydı belirtir; alanlardan yalnızca biri doludur
+type Ref struct {
+	SaleID            uint
+	StockAdjustmentID uint
+	StockTransferID   uint
+	ProductionID      uint
+}
+
+// SaleRef satışa bağlı kullanımları seçer
+func SaleRef(saleID uint) Ref {
+	return Ref{SaleID: saleID}
+}
+
+// AdjustmentRef stok düzeltmesine bağlı kullanımları seçer
+func AdjustmentRef(adjustmentID uint) Ref {
+	return Ref{StockAdjustmentID: adjustmentID}
+}
+
+// TransferRef stok transferine bağlı kullanımları seçer
+func TransferRef(transferID uint) Ref {
+	return Ref{StockTransferID: transferID}
+}
+
+// ProductionRef üretime bağlı kullanımları seçer
+func ProductionRef(productionID uint) Ref {
+	return Ref{ProductionID: productionID}
+}
+
+// usage ref'e bağlı boş bir stok kullanımı döner
+func (r Ref) usage() models.StockUsage {
+	usage := models.StockUsage{SaleID: r.SaleID}
+	if r.StockAdjustmentID != 0 {
+		id := r.StockAdjustmentID
+		usage.StockAdjustmentID = &id
+	}
+	if r.StockTransferID != 0 {
+		id := r.StockTransferID
+		usage.StockTransferID = &id
+	}
+	if r.ProductionID != 0 {
+		id := r.ProductionID
+		usage.ProductionID = &id
+	}
+	return usage
+}
+
+// scope ref'e bağlı stok kullanımlarını seçen koşulu ekler
+func (r Ref) scope(tx *gorm.DB) *gorm.DB {
+	switch {
+	case r.StockAdjustmentID != 0:
+		return tx.Where("stock_adjustment_id = ?", r.StockAdjustmentID)
+	case r.StockTransferID != 0:
+		return tx.Where("stock_transfer_id = ?", r.StockTransferID)
+	case r.ProductionID != 0:
+		return tx.Where("production_id = ?", r.ProductionID)
+	}
+	return tx.Where("sale_id = ?", r.SaleID)
+}
+
+// respectsReservations ref'in rezervasyonlarla ayrılmış stoğa dokunmaması gerektiğini belirtir.
+// Stok düzeltmeleri fiziksel kaybı kaydettiğinden rezervasyonlarla engellenmez.
+func (r Ref) respectsReservations() bool {
+	return r.StockAdjustmentID == 0
+}
+
+// ExpiryDay tarihi gün başına (UTC) indirir; son kullanma günü boyunca parti kullanılabilir
+func ExpiryDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// IsPerishable ürünün kategorisi veya üst kategorilerinden biri bozulabilir işaretliyse true döner
+func IsPerishable(tx *gorm.DB, product models.Product) (bool, error) {
+	if product.CategoryID == nil {
+		return false, nil
+	}
+
+	var category models.Category
+	if err := tx.First(&category, *product.CategoryID).Error; err != nil {
+		return false, err
+	}
+
+	var count int64
+	err := tx.Model(&models.Category{}).
+		Where("perishable = ? AND ? LIKE path || '%'", true, category.Path).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// AtLocation sorguyu verilen konumdaki stok hareketleriyle sınırlar; 0 tüm konumlar demektir
+func AtLocation(query *gorm.DB, locationID uint) *gorm.DB {
+	if locationID == 0 {
+		return query
+	}
+	return query.Where("location_id = ?", locationID)
+}
+
+// Availability ürünün verilen konumda ve tarihte tüketilebilir toplam stoğunu döner
+func Availability(tx *gorm.DB, productID, locationID uint, at time.Time) (float64, error) {
+	var available float64
+	err := AtLocation(tx.Model(&models.StockMovement{}), locationID).
+		Select("COALESCE(SUM(remaining_quantity), 0)").
+		Where("product_id = ? AND remaining_quantity > 0", productID).
+		Where("expiry_date IS NULL OR expiry_date >= ?", ExpiryDay(at)).
+		Scan(&available).Error
+	return available, err
+}
+
+// AverageCost ürünün verilen konumda ve tarihte tüketilebilir stoğunun ağırlıklı ortalama birim maliyetini döner
+func AverageCost(tx *gorm.DB, productID, locationID uint, at time.Time) (float64, error) {
+	var totals struct {
+		Quantity float64
+		Value    float64
+	}
+	err := AtLocation(tx.Model(&models.StockMovement{}), locationID).
+		Select("COALESCE(SUM(remaining_quantity), 0) AS quantity, COALESCE(SUM(remaining_quantity * unit_cost), 0) AS value").
+		Where("product_id = ? AND remaining_quantity > 0", productID).
+		Where("expiry_date IS NULL OR expiry_date >= ?", ExpiryDay(at)).
+		Scan(&totals).Error
+	if err != nil || totals.Quantity == 0 {
+		return 0, err
+	}
+	return totals.Value / totals.Quantity, nil
+}
+
+// Allocate talepleri ref'e (satış veya stok düzeltmesi) bağlı stok kullanımları olarak partilerden düşer ve
+// kullanımların toplam maliyetini (satılan malın maliyeti) döner. Partiler ve birim
+// maliyetler ürünün maliyet yöntemine göre Estimate ile belirlenir. Aynı ürün, konum ve
+// partiye ait talepler önce toplanır ve hiçbir düşüm yapılmadan tüm ürünlerin
+// stoğu konum bazında kontrol edilir; eksik olan ilk ürün için *ShortageError döner.
+// Stok düzeltmeleri dışındaki ref'ler için etkin rezervasyonlar kullanılabilir stoktan düşülür.
+func Allocate(tx *gorm.DB, ref Ref, demands []Demand, at time.Time) ([]models.StockUsage, float64, error) {
+	type demandKey struct {
+		productID  uint
+		locationID uint
+		lotID      uint
+		movementID uint
+		method     string
+	}
+	type stockKey struct {
+		productID  uint
+		locationID uint
+	}
+
+	var keys []demandKey
+	totals := make(map[demandKey]float64)
+	stockTotals := make(map[stockKey]float64)
+	var stockKeys []stockKey
+	for _, demand := range demands {
+		if demand.Quantity <= 0 {
+			continue
+		}
+		key := demandKey{productID: demand.ProductID, locationID: demand.LocationID, method: demand.Method}
+		if demand.LotID != nil {
+			key.lotID = *demand.LotID
+		}
+		if demand.MovementID != nil {
+			key.movementID = *demand.MovementID
+		}
+		if _, ok := totals[key]; !ok {
+			keys = append(keys, key)
+		}
+		totals[key] += demand.Quantity
+
+		// Hareket bazlı talepler süresi geçmiş partileri de kapsadığından kendi hareketlerinde kontrol edilir
+		if key.movementID == 0 {
+			stock := stockKey{productID: demand.ProductID, locationID: demand.LocationID}
+			if _, ok := stockTotals[stock]; !ok {
+				stockKeys = append(stockKeys, stock)
+			}
+			stockTotals[stock] += demand.Quantity
+		}
+	}
+
+	products := make(map[uint]models.Product)
+	for _, key := range keys {
+		if _, ok := products[key.productID]; ok {
+			continue
+		}
+
+		var product models.Product
+		if err := tx.First(&product, key.productID).Error; err != nil {
+			return nil, 0, err
+		}
+		products[key.productID] = product
+	}
+
+	for _, stock := range stockKeys {
+		availability := Availability
+		if ref.respectsReservations() {
+			availability = Unreserved
+		}
+		available, err := availability(tx, stock.productID, stock.locationID, at)
+		if err != nil {
+			return nil, 0, err
+		}
+		if available < stockTotals[stock] {
+			return nil, 0, &ShortageError{
+				ProductID:   stock.productID,
+				ProductName: products[stock.productID].ProductName,
+				Requested:   stockTotals[stock],
+				Available:   available,
+			}
+		}
+	}
+
+	var usages []models.StockUsage
+	var cost float64
+	for _, key := range keys {
+		demand := Demand{ProductID: key.productID, Quantity: totals[key], LocationID: key.locationID, Method: key.method}
+		if key.lotID != 0 {
+			id := key.lotID
+			demand.LotID = &id
+		}
+		if key.movementID != 0 {
+			id := key.movementID
+			demand.MovementID = &id
+		}
+
+		// Partiler yalnızca kalan miktar yetiyorsa düşülür. Tahminden sonra başka bir işlem
+		// partiyi tüketmişse düşülemeyen miktar için partiler yeniden seçilir.
+		for attempt := 0; demand.Quantity > quantityEpsilon; attempt++ {
+			if attempt == maxAllocationAttempts {
+				return nil, 0, ErrConcurrentUpdate
+			}
+
+			quote, err := Estimate(tx, products[key.productID], demand, at)
+			if err != nil {
+				return nil, 0, err
+			}
+
+			for _, line := range quote.Lines {
+				taken, err := decrement(tx, line.StockMovementID, line.Quantity)
+				if err != nil {
+					return nil, 0, err
+				}
+				if !taken {
+					break
+				}
+
+				usage := ref.usage()
+				usage.StockMovementID = line.StockMovementID
+				usage.UsedQuantity = line.Quantity
+				usage.UnitCost = line.UnitCost
+				if err := tx.Create(&usage).Error; err != nil {
+					return nil, 0, err
+				}
+				movement := models.StockMovement{
+					ID:            line.StockMovementID,
+					ProductID:     key.productID,
+					LocationID:    line.LocationID,
+					PurchaseLotID: line.PurchaseLotID,
+					UnitCost:      line.UnitCost,
+				}
+				if err := Record(tx, ref.entryType(), ref, movement, -line.Quantity, at); err != nil {
+					return nil, 0, err
+				}
+				usages = append(usages, usage)
+				cost += line.Quantity * line.UnitCost
+				demand.Quantity -= line.Quantity
+			}
+		}
+
+		// Katalog ürününün toplam stoğunu güncelle
+		if err := tx.Model(&models.Product{}).
+			Where("id = ?", key.productID).
+			Update("current_stock", gorm.Expr("current_stock - ?", totals[key])).Error; err != nil {
+			return nil, 0, err
+		}
+	}
+
+	return usages, cost, nil
+}
+
+// decrement partinin kalan miktarını, yalnızca miktar hâlâ yetiyorsa düşer ve düşümün
+// yapılıp yapılmadığını döner
+func decrement(tx *gorm.DB, movementID uint, quantity float64) (bool, error) {
+	result := tx.Model(&models.StockMovement{}).
+		Where("id = ? AND remaining_quantity >= ?", movementID, quantity-quantityEpsilon).
+		Update("remaining_quantity", gorm.Expr("remaining_quantity - ?", quantity))
+	return result.RowsAffected == 1, result.Error
+}
+
+// usageBalance ref'in bir partiden net tükettiği miktardır; miktar düzeltmelerinde
+// eklenen ters kullanımlar tüketimden düşülmüş olur
+type usageBalance struct {
+	StockMovementID uint
+	UsedQuantity    float64
+	UnitCost        float64
+	LastUsageID     uint
+}
+
+// balances ref'in partilerden net tükettiği miktarları döner; tamamen geri verilmiş
+// partiler dahil edilmez
+func balances(tx *gorm.DB, ref Ref) ([]usageBalance, error) {
+	var result []usageBalance
+	err := ref.scope(tx.Model(&models.StockUsage{})).
+		Select("stock_movement_id, SUM(used_quantity) AS used_quantity, "+
+			"SUM(used_quantity * unit_cost) / SUM(used_quantity) AS unit_cost, MAX(id) AS last_usage_id").
+		Group("stock_movement_id").
+		Having("SUM(used_quantity) > ?", quantityEpsilon).
+		Scan(&result).Error
+	return result, err
+}
+
+// Release ref'e bağlı stok kullanımlarını partilere iade eder ve kullanımları siler.
+// Arşivlenmiş partiye iade yapılır ama bu miktar ürünün satılabilir stoğuna eklenmez.
+// Her iade deftere işlenir; satış iadeleri iade, diğerleri kendi türüyle kaydedilir.
+func Release(tx *gorm.DB, ref Ref) error {
+	entryType := ref.entryType()
+	if entryType == models.EntrySale {
+		entryType = models.EntryReturn
+	}
+	now := time.Now()
+
+	usages, err := balances(tx, ref)
+	if err != nil {
+		return err
+	}
+
+	for _, usage := range usages {
+		var movement models.StockMovement
+		if err := tx.Unscoped().First(&movement, usage.StockMovementID).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Unscoped().Model(&models.StockMovement{}).
+			Where("id = ?", movement.ID).
+			Update("remaining_quantity", gorm.Expr("remaining_quantity + ?", usage.UsedQuantity)).Error; err != nil {
+			return err
+		}
+
+		if err := recordReturn(tx, entryType, ref, movement, usage.UsedQuantity, usage.UnitCost, now); err != nil {
+			return err
+		}
+
+		if movement.DeletedAt.Valid {
+			continue
+		}
+
+		if err := tx.Unscoped().Model(&models.Product{}).
+			Where("id = ?", movement.ProductID).
+			Update("current_stock", gorm.Expr("current_stock + ?", usage.UsedQuantity)).Error; err != nil {
+			return err
+		}
+	}
+
+	return ref.scope(tx).Delete(&models.StockUsage{}).Error
+}
+
+// Shrink ref'e bağlı kullanımların fraction oranını, en son tüketilen partiden
+// başlayarak partilere iade eder. Mevcut kullanımlar değişmez; iade edilen miktar
+// aynı partiye ters (eksi) kullanım olarak eklenir ve deftere iade tarihiyle işlenir.
+// Satış miktarı düzeltilirken kullanılır; iade edilen malın maliyetini döner.
+func Shrink(tx *gorm.DB, ref Ref, fraction float64, at time.Time) (float64, error) {
+	entryType := ref.entryType()
+	if entryType == models.EntrySale {
+		entryType = models.EntryReturn
+	}
+
+	usages, err := balances(tx, ref)
+	if err != nil {
+		return 0, err
+	}
+
+	movements := make(map[uint]models.StockMovement)
+	need := make(map[uint]float64)
+	for _, usage := range usages {
+		var movement models.StockMovement
+		if err := tx.Unscoped().First(&movement, usage.StockMovementID).Error; err != nil {
+			return 0, err
+		}
+		movements[usage.StockMovementID] = movement
+		need[movement.ProductID] += usage.UsedQuantity * fraction
+	}
+	// Oranın kayan nokta hatası partilerde küsurat bırakmasın diye talep yuvarlanır
+	for productID, quantity := range need {
+		need[productID] = math.Round(quantity/quantityEpsilon) * quantityEpsilon
+	}
+	sort.SliceStable(usages, func(i, j int) bool {
+		a, b := movements[usages[i].StockMovementID], movements[usages[j].StockMovementID]
+		if !a.MovementDate.Equal(b.MovementDate) {
+			return a.MovementDate.After(b.MovementDate)
+		}
+		return usages[i].LastUsageID > usages[j].LastUsageID
+	})
+
+	var cost float64
+	for _, usage := range usages {
+		movement := movements[usage.StockMovementID]
+		quantity := math.Min(usage.UsedQuantity, need[movement.ProductID])
+		if quantity <= quantityEpsilon {
+			continue
+		}
+		need[movement.ProductID] -= quantity
+		if usage.UsedQuantity-quantity <= quantityEpsilon*math.Max(1, usage.UsedQuantity) {
+			quantity = usage.UsedQuantity
+		}
+
+		reversal := ref.usage()
+		reversal.StockMovementID = movement.ID
+		reversal.UsedQuantity = -quantity
+		reversal.UnitCost = usage.UnitCost
+		if err := tx.Create(&reversal).Error; err != nil {
+			return 0, err
+		}
+
+		if err := tx.Unscoped().Model(&models.StockMovement{}).
+			Where("id = ?", movement.ID).
+			Update("remaining_quantity", gorm.Expr("remaining_quantity + ?", quantity)).Error; err != nil {
+			return 0, err
+		}
+
+		if err := recordReturn(tx, entryType, ref, movement, quantity, usage.UnitCost, at); err != nil {
+			return 0, err
+		}
+		cost += quantity * usage.UnitCost
+
+		if movement.DeletedAt.Valid {
+			continue
+		}
+		if err := tx.Unscoped().Model(&models.Product{}).
+			Where("id = ?", movement.ProductID).
+			Update("current_stock", gorm.Expr("current_stock + ?", quantity)).Error; err != nil {
+			return 0, err
+		}
+	}
+	return cost, nil
+}
@@ -0,0 +1,330 @@
+package inventory
+
+import (
+	"errors"
+	"stock-api/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	assert.NoError(t, err)
+
+	// Bellek içi veritabanı bağlantıya özeldir; tek bağlantı tüm sorguların aynı veritabanını görmesini sağlar
+	sqlDB, err := db.DB()
+	assert.NoError(t, err)
+	sqlDB.SetMaxOpenConns(1)
+
+	assert.NoError(t, db.AutoMigrate(
+		&models.Location{},
+		&models.Category{},
+		&models.Product{},
+		&models.StockMovement{},
+		&models.StockUsage{},
+		&models.Reservation{},
+		&models.InventoryEntry{},
+		&models.SaleReturn{},
+		&models.SaleReturnLine{},
+	))
+	return db
+}
+
+func createProduct(t *testing.T, db *gorm.DB, categoryID *uint, lots ...models.StockMovement) models.Product {
+	product := models.Product{ProductName: "Süt", Unit: "l", CategoryID: categoryID}
+	assert.NoError(t, db.Create(&product).Error)
+
+	for _, lot := range lots {
+		lot.ProductID = product.ID
+		lot.InitialQuantity = lot.RemainingQuantity
+		assert.NoError(t, db.Create(&lot).Error)
+		product.CurrentStock += lot.RemainingQuantity
+	}
+	assert.NoError(t, db.Model(&product).Update("current_stock", product.CurrentStock).Error)
+	return product
+}
+
+func remaining(t *testing.T, db *gorm.DB, productID uint) []float64 {
+	var movements []models.StockMovement
+	assert.NoError(t, db.Where("product_id = ?", productID).Order("id asc").Find(&movements).Error)
+
+	quantities := make([]float64, len(movements))
+	for i, m := range movements {
+		quantities[i] = m.RemainingQuantity
+	}
+	return quantities
+}
+
+func TestAllocateFIFO(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	product := createProduct(t, db, nil,
+		models.StockMovement{RemainingQuantity: 5, MovementDate: now.AddDate(0, 0, -1)},
+		models.StockMovement{RemainingQuantity: 5, MovementDate: now.AddDate(0, 0, -2)},
+	)
+
+	tx := db.Begin()
+	usages, _, err := Allocate(tx, SaleRef(1), []Demand{{ProductID: product.ID, Quantity: 7}}, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	// Önce eski tarihli ikinci parti tüketilir
+	assert.Len(t, usages, 2)
+	assert.Equal(t, []float64{3, 0}, remaining(t, db, product.ID))
+
+	var updated models.Product
+	db.First(&updated, product.ID)
+	assert.Equal(t, 3.0, updated.CurrentStock)
+}
+
+func TestAllocateAggregatesDemandPerProduct(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	product := createProduct(t, db, nil, models.StockMovement{RemainingQuantity: 5, MovementDate: now})
+
+	// Kalemler tek tek stoğa sığar ama toplamları sığmaz
+	tx := db.Begin()
+	_, _, err := Allocate(tx, SaleRef(1), []Demand{
+		{ProductID: product.ID, Quantity: 3},
+		{ProductID: product.ID, Quantity: 3},
+	}, now)
+	tx.Rollback()
+
+	assert.True(t, errors.Is(err, ErrInsufficientStock))
+	var shortage *ShortageError
+	if assert.True(t, errors.As(err, &shortage)) {
+		assert.Equal(t, 6.0, shortage.Requested)
+		assert.Equal(t, 5.0, shortage.Available)
+	}
+	assert.Equal(t, []float64{5}, remaining(t, db, product.ID))
+}
+
+func TestAllocateFEFOSkipsExpired(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+
+	category := models.Category{Name: "Süt Ürünleri", Path: "/1/", Perishable: true}
+	assert.NoError(t, db.Create(&category).Error)
+	child := models.Category{Name: "Süt", ParentID: &category.ID, Path: "/1/2/"}
+	assert.NoError(t, db.Create(&child).Error)
+
+	expired := now.AddDate(0, 0, -1)
+	soon := now.AddDate(0, 0, 2)
+	later := now.AddDate(0, 0, 10)
+	product := createProduct(t, db, &child.ID,
+		models.StockMovement{RemainingQuantity: 5, MovementDate: now.AddDate(0, 0, -9), ExpiryDate: &expired},
+		models.StockMovement{RemainingQuantity: 5, MovementDate: now.AddDate(0, 0, -5), ExpiryDate: &later},
+		models.StockMovement{RemainingQuantity: 5, MovementDate: now.AddDate(0, 0, -1), ExpiryDate: &soon},
+	)
+
+	available, err := Availability(db, product.ID, 0, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 10.0, available)
+
+	tx := db.Begin()
+	_, _, err = Allocate(tx, SaleRef(1), []Demand{{ProductID: product.ID, Quantity: 6}}, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	// Süresi geçmiş parti atlanır, son kullanma tarihi en yakın parti önce biter
+	assert.Equal(t, []float64{5, 4, 0}, remaining(t, db, product.ID))
+}
+
+func TestRelease(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	product := createProduct(t, db, nil,
+		models.StockMovement{RemainingQuantity: 2, MovementDate: now.AddDate(0, 0, -2)},
+		models.StockMovement{RemainingQuantity: 5, MovementDate: now.AddDate(0, 0, -1)},
+	)
+
+	tx := db.Begin()
+	_, _, err := Allocate(tx, SaleRef(7), []Demand{{ProductID: product.ID, Quantity: 4}}, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	// Arşivlenen partiye iade edilen miktar satılabilir stoğa eklenmez
+	var archived models.StockMovement
+	db.Where("product_id = ?", product.ID).Order("id asc").First(&archived)
+	db.Delete(&archived)
+
+	tx = db.Begin()
+	assert.NoError(t, Release(tx, SaleRef(7)))
+	assert.NoError(t, tx.Commit().Error)
+
+	var count int64
+	db.Model(&models.StockUsage{}).Where("sale_id = ?", 7).Count(&count)
+	assert.Zero(t, count)
+	assert.Equal(t, []float64{5}, remaining(t, db, product.ID))
+
+	var updated models.Product
+	db.First(&updated, product.ID)
+	assert.Equal(t, 5.0, updated.CurrentStock)
+}
+
+func TestAllocateMovementIncludesExpired(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	expired := now.AddDate(0, 0, -3)
+	product := createProduct(t, db, nil,
+		models.StockMovement{RemainingQuantity: 4, UnitCost: 2, MovementDate: now.AddDate(0, 0, -9), ExpiryDate: &expired},
+		models.StockMovement{RemainingQuantity: 5, UnitCost: 3, MovementDate: now.AddDate(0, 0, -1)},
+	)
+
+	var movements []models.StockMovement
+	db.Where("product_id = ?", product.ID).Order("id asc").Find(&movements)
+
+	// Sayımda bulunmayan süresi geçmiş parti doğrudan hareketinden düşülebilir
+	tx := db.Begin()
+	_, cost, err := Allocate(tx, AdjustmentRef(1), []Demand{{ProductID: product.ID, Quantity: 4, MovementID: &movements[0].ID}}, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	assert.Equal(t, 8.0, cost)
+	assert.Equal(t, []float64{0, 5}, remaining(t, db, product.ID))
+
+	tx = db.Begin()
+	_, _, err = Allocate(tx, AdjustmentRef(2), []Demand{{ProductID: product.ID, Quantity: 6, MovementID: &movements[1].ID}}, now)
+	tx.Rollback()
+	assert.True(t, errors.Is(err, ErrInsufficientStock))
+}
+
+func TestAllocateAtLocation(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	product := createProduct(t, db, nil,
+		models.StockMovement{LocationID: 1, RemainingQuantity: 5, MovementDate: now.AddDate(0, 0, -2)},
+		models.StockMovement{LocationID: 2, RemainingQuantity: 3, MovementDate: now.AddDate(0, 0, -1)},
+	)
+
+	// Toplam stok yetse de konumdaki stok yetmezse satış yapılamaz
+	tx := db.Begin()
+	_, _, err := Allocate(tx, SaleRef(1), []Demand{{ProductID: product.ID, Quantity: 4, LocationID: 2}}, now)
+	tx.Rollback()
+	assert.True(t, errors.Is(err, ErrInsufficientStock))
+
+	tx = db.Begin()
+	_, _, err = Allocate(tx, SaleRef(1), []Demand{{ProductID: product.ID, Quantity: 2, LocationID: 2}}, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	// Eski tarihli parti başka konumda olduğundan dokunulmaz
+	assert.Equal(t, []float64{5, 1}, remaining(t, db, product.ID))
+
+	available, err := Availability(db, product.ID, 0, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 6.0, available)
+}
+
+func TestAllocateRespectsReservations(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	product := createProduct(t, db, nil,
+		models.StockMovement{LocationID: 1, RemainingQuantity: 5, MovementDate: now.AddDate(0, 0, -1)},
+	)
+	location := uint(1)
+	assert.NoError(t, db.Create(&models.Reservation{ProductID: product.ID, LocationID: &location, StockQuantity: 3, Status: models.ReservationActive, ExpiresAt: now.Add(time.Hour)}).Error)
+	assert.NoError(t, db.Create(&models.Reservation{ProductID: product.ID, LocationID: &location, StockQuantity: 2, Status: models.ReservationActive, ExpiresAt: now.Add(-time.Hour)}).Error)
+
+	// Süresi dolmuş rezervasyon stok tutmaz
+	free, err := Unreserved(db, product.ID, 1, now)
+	assert.NoError(t, err)
+	assert.Equal(t, 2.0, free)
+
+	tx := db.Begin()
+	_, _, err = Allocate(tx, SaleRef(1), []Demand{{ProductID: product.ID, Quantity: 3, LocationID: 1}}, now)
+	tx.Rollback()
+	var shortage *ShortageError
+	if assert.True(t, errors.As(err, &shortage)) {
+		assert.Equal(t, 2.0, shortage.Available)
+	}
+
+	// Stok düzeltmesi fiziksel kaybı kaydeder, rezervasyonla engellenmez
+	tx = db.Begin()
+	_, _, err = Allocate(tx, AdjustmentRef(1), []Demand{{ProductID: product.ID, Quantity: 4, LocationID: 1}}, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+	assert.Equal(t, []float64{1}, remaining(t, db, product.ID))
+
+	expired, err := ExpireReservations(db, now)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), expired)
+}
+
+func TestDecrementGuardsRemaining(t *testing.T) {
+	db := newTestDB(t)
+	product := createProduct(t, db, nil, models.StockMovement{RemainingQuantity: 5, MovementDate: time.Now()})
+
+	var movement models.StockMovement
+	assert.NoError(t, db.Where("product_id = ?", product.ID).First(&movement).Error)
+
+	// Kalan miktarı aşan düşüm yapılmaz; parti hiçbir zaman eksiye düşmez
+	taken, err := decrement(db, movement.ID, 6)
+	assert.NoError(t, err)
+	assert.False(t, taken)
+
+	taken, err = decrement(db, movement.ID, 5)
+	assert.NoError(t, err)
+	assert.True(t, taken)
+	assert.Equal(t, []float64{0}, remaining(t, db, product.ID))
+}
+
+func TestShrinkMostRecentFirst(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	milk := createProduct(t, db, nil,
+		models.StockMovement{RemainingQuantity: 5, UnitCost: 2, MovementDate: now.AddDate(0, 0, -2)},
+		models.StockMovement{RemainingQuantity: 5, UnitCost: 4, MovementDate: now.AddDate(0, 0, -1)},
+	)
+
+	tx := db.Begin()
+	_, _, err := Allocate(tx, SaleRef(1), []Demand{{ProductID: milk.ID, Quantity: 8}}, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	// Satışın yarısı geri verilir: önce yeni partideki 3, sonra eski partiden 1
+	tx = db.Begin()
+	cost, err := Shrink(tx, SaleRef(1), 0.5, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	assert.Equal(t, 3*4.0+1*2.0, cost)
+	assert.Equal(t, []float64{1, 5}, remaining(t, db, milk.ID))
+
+	// Mevcut kullanımlar değişmez; geri verilen miktarlar ters kullanım olarak eklenir
+	var usages []models.StockUsage
+	assert.NoError(t, db.Where("sale_id = ?", 1).Order("id asc").Find(&usages).Error)
+	var quantities []float64
+	for _, usage := range usages {
+		quantities = append(quantities, usage.UsedQuantity)
+	}
+	assert.Equal(t, []float64{5, 3, -3, -1}, quantities)
+
+	var stock float64
+	assert.NoError(t, db.Model(&models.Product{}).Where("id = ?", milk.ID).Select("current_stock").Scan(&stock).Error)
+	assert.Equal(t, 6.0, stock)
+
+	// İkinci düzeltme yalnızca net kullanımdan düşer
+	tx = db.Begin()
+	cost, err = Shrink(tx, SaleRef(1), 0.5, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+	assert.Equal(t, 2*2.0, cost)
+	assert.Equal(t, []float64{3, 5}, remaining(t, db, milk.ID))
+
+	// Satış silinince net kullanım iade edilir; satışın defter kayıtları sıfırlanır
+	tx = db.Begin()
+	assert.NoError(t, Release(tx, SaleRef(1)))
+	assert.NoError(t, tx.Commit().Error)
+	assert.Equal(t, []float64{5, 5}, remaining(t, db, milk.ID))
+	var journal float64
+	assert.NoError(t, db.Model(&models.InventoryEntry{}).
+		Where("inventory_entries.sale_id = ?", 1).
+		Select("COALESCE(SUM(inventory_entries.quantity), 0)").Scan(&journal).Error)
+	assert.InDelta(t, 0, journal, 1e-9)
+}
@@ -0,0 +1,218 @@
+package inventory
+
+import (
+	"math"
+	"stock-api/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RebuildResult defterden yeniden hesaplanırken düzeltilen önbellek satırlarının sayısıdır
+type RebuildResult struct {
+	Movements int64 `json:"movements"`
+	Products  int64 `json:"products"`
+}
+
+// entryType ref'e bağlı tüketimlerin defterdeki türünü döner
+func (r Ref) entryType() string {
+	switch {
+	case r.StockAdjustmentID != 0:
+		return models.EntryAdjustment
+	case r.StockTransferID != 0:
+		return models.EntryTransfer
+	case r.ProductionID != 0:
+		return models.EntryProduction
+	}
+	return models.EntrySale
+}
+
+// Record partinin miktarını quantity kadar (işaretli) değiştiren bir defter kaydı ekler.
+// Kayıt ref'e ve partinin alış partisine bağlanır; değer partinin maliyetiyle hesaplanır.
+// Önbellekteki miktarları güncellemek çağıranın sorumluluğundadır.
+func Record(tx *gorm.DB, entryType string, ref Ref, movement models.StockMovement, quantity float64, at time.Time) error {
+	entry := models.InventoryEntry{
+		Type:            entryType,
+		ProductID:       movement.ProductID,
+		LocationID:      movement.LocationID,
+		StockMovementID: movement.ID,
+		PurchaseLotID:   movement.PurchaseLotID,
+		Quantity:        quantity,
+		UnitCost:        movement.UnitCost,
+		Value:           quantity * movement.UnitCost,
+		OccurredAt:      at,
+	}
+	if ref.SaleID != 0 {
+		id := ref.SaleID
+		entry.SaleID = &id
+	}
+	if ref.StockAdjustmentID != 0 {
+		id := ref.StockAdjustmentID
+		entry.StockAdjustmentID = &id
+	}
+	if ref.StockTransferID != 0 {
+		id := ref.StockTransferID
+		entry.StockTransferID = &id
+	}
+	if ref.ProductionID != 0 {
+		id := ref.ProductionID
+		entry.ProductionID = &id
+	}
+	return tx.Create(&entry).Error
+}
+
+// recordReturn partiye geri dönen miktarı tüketildiği maliyetle deftere işler. Partinin
+// maliyeti o zamandan beri düzeltildiyse geri dönen miktar partinin güncel maliyetine
+// yeniden değerlenir; böylece partinin defter değeri kalan × parti maliyeti olarak kalır.
+func recordReturn(tx *gorm.DB, entryType string, ref Ref, movement models.StockMovement, quantity, unitCost float64, at time.Time) error {
+	returned := movement
+	returned.UnitCost = unitCost
+	if err := Record(tx, entryType, ref, returned, quantity, at); err != nil {
+		return err
+	}
+	if math.Abs(unitCost-movement.UnitCost) <= quantityEpsilon {
+		return nil
+	}
+	return Reprice(tx, movement, unitCost, quantity, at)
+}
+
+// Consumed partiden giriş dışında bir defter kaydıyla (satış, düzeltme, transfer ya
+// da iade) stok çıkıp çıkmadığını döner
+func Consumed(tx *gorm.DB, movementID uint) (bool, error) {
+	var count int64
+	err := tx.Model(&models.InventoryEntry{}).
+		Where("stock_movement_id = ? AND type != ?", movementID, models.EntryReceipt).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Reprice partinin maliyet düzeltmesini deftere işler: kalan quantity eski maliyetle
+// çıkarılıp movement'ın yeni maliyetiyle yeniden girilir. Tüketilmiş miktarlar eski
+// maliyetlerinde kalır; geçmiş değerler değişmez.
+func Reprice(tx *gorm.DB, movement models.StockMovement, oldCost, quantity float64, at time.Time) error {
+	old := movement
+	old.UnitCost = oldCost
+	if err := Record(tx, models.EntryReceipt, Ref{}, old, -quantity, at); err != nil {
+		return err
+	}
+	return Record(tx, models.EntryReceipt, Ref{}, movement, quantity, at)
+}
+
+// Redate kullanılmamış partinin giriş tarihini düzeltir: mevcut giriş kayıtları kendi
+// tarihlerinde ters kayıtla kapatılır ve quantity movement'ın tarihinde ve maliyetiyle
+// yeniden girilir. Böylece geçmiş bakiyeler partiyi yeni fatura tarihinden itibaren gösterir.
+func Redate(tx *gorm.DB, movement models.StockMovement, quantity float64) error {
+	var entries []models.InventoryEntry
+	if err := tx.Where("stock_movement_id = ?", movement.ID).Find(&entries).Error; err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		reversed := movement
+		reversed.UnitCost = entry.UnitCost
+		if err := Record(tx, entry.Type, Ref{}, reversed, -entry.Quantity, entry.OccurredAt); err != nil {
+			return err
+		}
+	}
+	return Record(tx, models.EntryReceipt, Ref{}, movement, quantity, movement.MovementDate)
+}
+
+// BackfillJournal defter tutulmaya başlanmadan önceki stok için açılış kayıtlarını
+// oluşturur: her partinin girişi, her stok kullanımı ve (varsa) parti kalanı ile bu
+// kayıtlar arasındaki fark. Böylece defterden hesaplanan miktarlar mevcut kalanlarla
+// aynı olur. Defterde kayıt varsa hiçbir şey yapmaz.
+func BackfillJournal(tx *gorm.DB) error {
+	var count int64
+	if err := tx.Model(&models.InventoryEntry{}).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	// Girişler: alış, düzeltme ve transfer partileri giriş tarihinde
+	if err := tx.Exec(`
+		INSERT INTO inventory_entries (type, product_id, location_id, stock_movement_id, purchase_lot_id,
+			stock_adjustment_id, stock_transfer_id, quantity, unit_cost, value, occurred_at, created_at)
+		SELECT CASE type WHEN ? THEN ? WHEN ? THEN ? ELSE ? END,
+			product_id, location_id, id, purchase_lot_id, stock_adjustment_id, stock_transfer_id,
+			initial_quantity, unit_cost, initial_quantity * unit_cost, movement_date, CURRENT_TIMESTAMP
+		FROM stock_movements
+		WHERE initial_quantity > 0`,
+		models.MovementAdjustment, models.EntryAdjustment,
+		models.MovementTransfer, models.EntryTransfer,
+		models.EntryReceipt,
+	).Error; err != nil {
+		return err
+	}
+
+	// Çıkışlar: satış, düzeltme, sevk ve üretim kullanımları kendi tarihlerinde; satış
+	// miktarı düzeltmelerindeki ters kullanımlar iade olarak
+	if err := tx.Exec(`
+		INSERT INTO inventory_entries (type, product_id, location_id, stock_movement_id, purchase_lot_id,
+			sale_id, stock_adjustment_id, stock_transfer_id, production_id, quantity, unit_cost, value, occurred_at, created_at)
+		SELECT CASE WHEN su.stock_adjustment_id IS NOT NULL THEN ?
+				WHEN su.stock_transfer_id IS NOT NULL THEN ?
+				WHEN su.production_id IS NOT NULL THEN ?
+				WHEN su.used_quantity < 0 THEN ? ELSE ? END,
+			sm.product_id, sm.location_id, sm.id, sm.purchase_lot_id,
+			NULLIF(su.sale_id, 0), su.stock_adjustment_id, su.stock_transfer_id, su.production_id,
+			-su.used_quantity, su.unit_cost, -su.used_quantity * su.unit_cost,
+			COALESCE(`+usageDate+`, su.created_at), CURRENT_TIMESTAMP
+		FROM stock_usages su
+		JOIN stock_movements sm ON sm.id = su.stock_movement_id
+		LEFT JOIN sales s ON s.id = su.sale_id
+		LEFT JOIN stock_adjustments sa ON sa.id = su.stock_adjustment_id
+		LEFT JOIN stock_transfers st ON st.id = su.stock_transfer_id
+		LEFT JOIN productions p ON p.id = su.production_id`,
+		models.EntryAdjustment, models.EntryTransfer, models.EntryProduction, models.EntryReturn, models.EntrySale,
+	).Error; err != nil {
+		return err
+	}
+
+	// Kayıtlarla açıklanamayan kalan farkı açılış düzeltmesi olarak işlenir
+	return tx.Exec(`
+		INSERT INTO inventory_entries (type, product_id, location_id, stock_movement_id, purchase_lot_id,
+			quantity, unit_cost, value, occurred_at, created_at)
+		SELECT ?, sm.product_id, sm.location_id, sm.id, sm.purchase_lot_id,
+			sm.remaining_quantity - e.quantity, sm.unit_cost, (sm.remaining_quantity - e.quantity) * sm.unit_cost,
+			CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+		FROM stock_movements sm
+		JOIN (SELECT stock_movements.id, COALESCE(SUM(inventory_entries.quantity), 0) AS quantity
+			FROM stock_movements
+			LEFT JOIN inventory_entries ON inventory_entries.stock_movement_id = stock_movements.id
+			GROUP BY stock_movements.id) e ON e.id = sm.id
+		WHERE ABS(sm.remaining_quantity - e.quantity) > ?`,
+		models.EntryAdjustment, quantityEpsilon,
+	).Error
+}
+
+// Rebuild tüm önbellekleri defterden yeniden hesaplar: her partinin kalanı kayıtlarının
+// toplamı, her ürünün stoğu arşivlenmemiş partilerinin kalanlarının toplamı olur.
+// Defterle uyuşmayan ve düzeltilen satırların sayısını döner.
+func Rebuild(tx *gorm.DB) (RebuildResult, error) {
+	var result RebuildResult
+
+	movements := tx.Exec(`
+		UPDATE stock_movements
+		SET remaining_quantity = (SELECT COALESCE(SUM(quantity), 0) FROM inventory_entries
+			WHERE inventory_entries.stock_movement_id = stock_movements.id)
+		WHERE ABS(remaining_quantity - (SELECT COALESCE(SUM(quantity), 0) FROM inventory_entries
+			WHERE inventory_entries.stock_movement_id = stock_movements.id)) > ?`, quantityEpsilon)
+	if movements.Error != nil {
+		return result, movements.Error
+	}
+	result.Movements = movements.RowsAffected
+
+	products := tx.Exec(`
+		UPDATE products
+		SET current_stock = (SELECT COALESCE(SUM(remaining_quantity), 0) FROM stock_movements
+			WHERE stock_movements.product_id = products.id AND stock_movements.deleted_at IS NULL)
+		WHERE ABS(current_stock - (SELECT COALESCE(SUM(remaining_quantity), 0) FROM stock_movements
+			WHERE stock_movements.product_id = products.id AND stock_movements.deleted_at IS NULL)) > ?`, quantityEpsilon)
+	if products.Error != nil {
+		return result, products.Error
+	}
+	result.Products = products.RowsAffected
+
+	return result, nil
+}
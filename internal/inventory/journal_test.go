@@ -0,0 +1,87 @@
+package inventory
+
+import (
+	"stock-api/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+func journalQuantity(t *testing.T, db *gorm.DB, movementID uint) float64 {
+	var quantity float64
+	assert.NoError(t, db.Model(&models.InventoryEntry{}).
+		Where("stock_movement_id = ?", movementID).
+		Select("COALESCE(SUM(quantity), 0)").
+		Scan(&quantity).Error)
+	return quantity
+}
+
+func TestJournalRebuild(t *testing.T) {
+	db := newTestDB(t)
+	assert.NoError(t, db.AutoMigrate(&models.Sale{}, &models.StockAdjustment{}, &models.StockTransfer{}, &models.Production{}))
+	now := time.Now()
+	product := createProduct(t, db, nil,
+		models.StockMovement{RemainingQuantity: 5, UnitCost: 2, MovementDate: now.AddDate(0, 0, -2)},
+		models.StockMovement{RemainingQuantity: 5, UnitCost: 3, MovementDate: now.AddDate(0, 0, -1)},
+	)
+
+	// Mevcut partiler açılış girişleriyle deftere aktarılır
+	assert.NoError(t, db.Transaction(BackfillJournal))
+	var movements []models.StockMovement
+	assert.NoError(t, db.Where("product_id = ?", product.ID).Order("id asc").Find(&movements).Error)
+	assert.Equal(t, 5.0, journalQuantity(t, db, movements[0].ID))
+
+	tx := db.Begin()
+	_, _, err := Allocate(tx, SaleRef(1), []Demand{{ProductID: product.ID, Quantity: 7}}, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	var sales []models.InventoryEntry
+	assert.NoError(t, db.Where("type = ?", models.EntrySale).Order("id asc").Find(&sales).Error)
+	assert.Len(t, sales, 2)
+	assert.Equal(t, -5.0, sales[0].Quantity)
+	assert.Equal(t, -10.0, sales[0].Value)
+	assert.Equal(t, uint(1), *sales[0].SaleID)
+
+	// Bozulan önbellekler defterden yeniden hesaplanır
+	assert.NoError(t, db.Model(&models.StockMovement{}).Where("id = ?", movements[1].ID).Update("remaining_quantity", 9).Error)
+	assert.NoError(t, db.Model(&models.Product{}).Where("id = ?", product.ID).Update("current_stock", 42).Error)
+
+	result, err := Rebuild(db)
+	assert.NoError(t, err)
+	assert.Equal(t, RebuildResult{Movements: 1, Products: 1}, result)
+	assert.Equal(t, []float64{0, 3}, remaining(t, db, product.ID))
+	var stock float64
+	assert.NoError(t, db.Model(&models.Product{}).Where("id = ?", product.ID).Select("current_stock").Scan(&stock).Error)
+	assert.Equal(t, 3.0, stock)
+
+	// Tutarlı önbellekler değişmez
+	result, err = Rebuild(db)
+	assert.NoError(t, err)
+	assert.Equal(t, RebuildResult{}, result)
+
+	// İade deftere işlenir ve kalanlarla uyumludur
+	tx = db.Begin()
+	assert.NoError(t, Release(tx, SaleRef(1)))
+	assert.NoError(t, tx.Commit().Error)
+	assert.Equal(t, []float64{5, 5}, remaining(t, db, product.ID))
+	assert.Equal(t, 5.0, journalQuantity(t, db, movements[1].ID))
+
+	var returns int64
+	assert.NoError(t, db.Model(&models.InventoryEntry{}).Where("type = ?", models.EntryReturn).Count(&returns).Error)
+	assert.Equal(t, int64(2), returns)
+}
+
+func TestJournalImmutable(t *testing.T) {
+	db := newTestDB(t)
+	product := createProduct(t, db, nil, models.StockMovement{RemainingQuantity: 5})
+	assert.NoError(t, Record(db, models.EntryReceipt, Ref{}, models.StockMovement{ID: 1, ProductID: product.ID}, 5, time.Now()))
+
+	var entry models.InventoryEntry
+	assert.NoError(t, db.First(&entry).Error)
+	assert.ErrorIs(t, db.Model(&entry).Update("quantity", 1).Error, models.ErrJournalImmutable)
+	assert.ErrorIs(t, db.Delete(&entry).Error, models.ErrJournalImmutable)
+	assert.Equal(t, 5.0, journalQuantity(t, db, 1))
+}
@@ -0,0 +1,68 @@
+package inventory
+
+import (
+	"stock-api/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// activeReservations verilen anda stoğu tutan rezervasyonları seçer; süresi dolmuş
+// ama henüz işaretlenmemiş rezervasyonlar hesaba katılmaz
+func activeReservations(tx *gorm.DB, locationID uint, at time.Time) *gorm.DB {
+	return AtLocation(tx.Model(&models.Reservation{}), locationID).
+		Where("status = ? AND expires_at > ?", models.ReservationActive, at)
+}
+
+// Reserved ürünün verilen konumda ve anda rezervasyonlarla ayrılmış miktarını döner
+func Reserved(tx *gorm.DB, productID, locationID uint, at time.Time) (float64, error) {
+	var reserved float64
+	err := activeReservations(tx, locationID, at).
+		Select("COALESCE(SUM(stock_quantity), 0)").
+		Where("product_id = ?", productID).
+		Scan(&reserved).Error
+	return reserved, err
+}
+
+// ReservedByProduct verilen konumdaki ayrılmış miktarları ürün bazında döner
+func ReservedByProduct(tx *gorm.DB, locationID uint, at time.Time) (map[uint]float64, error) {
+	var rows []struct {
+		ProductID uint
+		Reserved  float64
+	}
+	if err := activeReservations(tx, locationID, at).
+		Select("product_id, SUM(stock_quantity) AS reserved").
+		Group("product_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	reserved := make(map[uint]float64, len(rows))
+	for _, row := range rows {
+		reserved[row.ProductID] = row.Reserved
+	}
+	return reserved, nil
+}
+
+// Unreserved ürünün verilen konumda ve tarihte tüketilebilir stoğundan rezervasyonlar
+// düşüldükten sonra kalan, satılabilir miktarı döner
+func Unreserved(tx *gorm.DB, productID, locationID uint, at time.Time) (float64, error) {
+	available, err := Availability(tx, productID, locationID, at)
+	if err != nil {
+		return 0, err
+	}
+	reserved, err := Reserved(tx, productID, locationID, at)
+	if err != nil {
+		return 0, err
+	}
+	return available - reserved, nil
+}
+
+// ExpireReservations süresi dolmuş etkin rezervasyonları serbest bırakılmış olarak
+// işaretler ve işaretlenen rezervasyon sayısını döner
+func ExpireReservations(tx *gorm.DB, at time.Time) (int64, error) {
+	result := tx.Model(&models.Reservation{}).
+		Where("status = ? AND expires_at <= ?", models.ReservationActive, at).
+		Updates(map[string]interface{}{"status": models.ReservationExpired, "released_at": at})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,132 @@
+package inventory
+
+import (
+	"math"
+	"stock-api/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Returned iadenin satışta tüketilen bir partiye düşen kısmıdır
+type Returned struct {
+	StockUsageID    uint
+	StockMovementID uint
+	ProductID       uint
+	Quantity        float64
+	UnitCost        float64
+}
+
+// returnableUsage satış kullanımının henüz iade edilmemiş kısmıdır
+type returnableUsage struct {
+	ID              uint
+	StockMovementID uint
+	ProductID       uint
+	UsedQuantity    float64
+	UnitCost        float64
+	Returned        float64
+	Archived        bool
+}
+
+// Return satışın fraction oranındaki kısmını, satışta tüketilen partilere en son
+// tüketilenden başlayarak geri dağıtır. Reçete satışlarında her malzemenin aynı oranı
+// iade edilir; önceki iadelerle kalan miktar tamamen iade ediliyorsa kullanımların
+// kalanı olduğu gibi döner. restock ise miktarlar partilere ve ürün stoğuna eklenir
+// ve deftere iade olarak işlenir; değilse stok değişmez (mal fire olarak yazılır).
+// Arşivlenmiş partiye iade ürünün satılabilir stoğuna eklenmez.
+func Return(tx *gorm.DB, saleID uint, fraction float64, restock bool, at time.Time) ([]Returned, error) {
+	returned := tx.Model(&models.SaleReturnLine{}).
+		Select("COALESCE(SUM(quantity), 0)").
+		Where("sale_return_lines.stock_usage_id = stock_usages.id")
+
+	var usages []returnableUsage
+	if err := tx.Model(&models.StockUsage{}).
+		Select("stock_usages.id, stock_usages.stock_movement_id, stock_movements.product_id, "+
+			"stock_usages.used_quantity, stock_usages.unit_cost, (?) AS returned, "+
+			"stock_movements.deleted_at IS NOT NULL AS archived", returned).
+		Joins("JOIN stock_movements ON stock_movements.id = stock_usages.stock_movement_id").
+		Where("stock_usages.sale_id = ?", saleID).
+		Order("stock_movements.movement_date desc, stock_usages.id desc").
+		Scan(&usages).Error; err != nil {
+		return nil, err
+	}
+
+	// Miktar düzeltmelerinde eklenen ters kullanımlar aynı partinin kullanımlarından
+	// en son olandan başlayarak düşülür
+	reversed := make(map[uint]float64)
+	for _, usage := range usages {
+		if usage.UsedQuantity < 0 {
+			reversed[usage.StockMovementID] -= usage.UsedQuantity
+		}
+	}
+	net := usages[:0]
+	for _, usage := range usages {
+		if usage.UsedQuantity < 0 {
+			continue
+		}
+		offset := math.Min(usage.UsedQuantity, reversed[usage.StockMovementID])
+		reversed[usage.StockMovementID] -= offset
+		usage.UsedQuantity -= offset
+		if usage.UsedQuantity > quantityEpsilon {
+			net = append(net, usage)
+		}
+	}
+	usages = net
+
+	// Her ürün için iade edilecek miktar, kullanılan miktarın oranıdır
+	used := make(map[uint]float64)
+	outstanding := make(map[uint]float64)
+	for _, usage := range usages {
+		used[usage.ProductID] += usage.UsedQuantity
+		outstanding[usage.ProductID] += usage.UsedQuantity - usage.Returned
+	}
+	need := make(map[uint]float64)
+	for productID, total := range used {
+		need[productID] = math.Min(total*fraction, outstanding[productID])
+		if outstanding[productID]-need[productID] <= quantityEpsilon*math.Max(1, total) {
+			need[productID] = outstanding[productID]
+		}
+	}
+
+	var result []Returned
+	for _, usage := range usages {
+		quantity := math.Min(usage.UsedQuantity-usage.Returned, need[usage.ProductID])
+		if quantity <= quantityEpsilon {
+			continue
+		}
+		need[usage.ProductID] -= quantity
+
+		if restock {
+			if err := tx.Unscoped().Model(&models.StockMovement{}).
+				Where("id = ?", usage.StockMovementID).
+				Update("remaining_quantity", gorm.Expr("remaining_quantity + ?", quantity)).Error; err != nil {
+				return nil, err
+			}
+
+			movement := models.StockMovement{ID: usage.StockMovementID}
+			if err := tx.Unscoped().First(&movement).Error; err != nil {
+				return nil, err
+			}
+			if err := recordReturn(tx, models.EntryReturn, SaleRef(saleID), movement, quantity, usage.UnitCost, at); err != nil {
+				return nil, err
+			}
+
+			if !usage.Archived {
+				if err := tx.Model(&models.Product{}).
+					Where("id = ?", usage.ProductID).
+					Update("current_stock", gorm.Expr("current_stock + ?", quantity)).Error; err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		result = append(result, Returned{
+			StockUsageID:    usage.ID,
+			StockMovementID: usage.StockMovementID,
+			ProductID:       usage.ProductID,
+			Quantity:        quantity,
+			UnitCost:        usage.UnitCost,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,66 @@
+package inventory
+
+import (
+	"stock-api/internal/models"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReturnMostRecentFirst(t *testing.T) {
+	db := newTestDB(t)
+	now := time.Now()
+	milk := createProduct(t, db, nil,
+		models.StockMovement{RemainingQuantity: 5, UnitCost: 2, MovementDate: now.AddDate(0, 0, -2)},
+		models.StockMovement{RemainingQuantity: 5, UnitCost: 4, MovementDate: now.AddDate(0, 0, -1)},
+	)
+	sugar := createProduct(t, db, nil, models.StockMovement{RemainingQuantity: 10, UnitCost: 1, MovementDate: now})
+
+	// Reçete satışı: 8 süt ve 4 şeker
+	tx := db.Begin()
+	_, _, err := Allocate(tx, SaleRef(1), []Demand{{ProductID: milk.ID, Quantity: 8}, {ProductID: sugar.ID, Quantity: 4}}, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	// Satışın dörtte biri iade edilir: 2 süt en son tüketilen partiye, 1 şeker
+	tx = db.Begin()
+	returned, err := Return(tx, 1, 0.25, true, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	assert.Len(t, returned, 2)
+	quantities := make(map[uint]float64)
+	for _, r := range returned {
+		quantities[r.ProductID] += r.Quantity
+	}
+	assert.Equal(t, 2.0, quantities[milk.ID])
+	assert.Equal(t, 1.0, quantities[sugar.ID])
+	assert.Equal(t, []float64{0, 4}, remaining(t, db, milk.ID))
+	assert.Equal(t, []float64{7}, remaining(t, db, sugar.ID))
+
+	var stock float64
+	assert.NoError(t, db.Model(&models.Product{}).Where("id = ?", milk.ID).Select("current_stock").Scan(&stock).Error)
+	assert.Equal(t, 4.0, stock)
+
+	// Fire olarak ayrılan iade stoğu değiştirmez
+	for _, r := range returned {
+		assert.NoError(t, db.Create(&models.SaleReturnLine{StockUsageID: r.StockUsageID, StockMovementID: r.StockMovementID, ProductID: r.ProductID, Quantity: r.Quantity}).Error)
+	}
+	tx = db.Begin()
+	returned, err = Return(tx, 1, 0.25, false, now)
+	assert.NoError(t, err)
+	assert.NoError(t, tx.Commit().Error)
+
+	// Kalan 1 süt yeni partiden, 1 süt eski partiden gelir
+	var milkLines []Returned
+	for _, r := range returned {
+		if r.ProductID == milk.ID {
+			milkLines = append(milkLines, r)
+		}
+	}
+	assert.Len(t, milkLines, 2)
+	assert.Equal(t, 4.0, milkLines[0].UnitCost)
+	assert.Equal(t, 2.0, milkLines[1].UnitCost)
+	assert.Equal(t, []float64{0, 4}, remaining(t, db, milk.ID))
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+)
+
+// ProductBarcode ürüne bağlı EAN/UPC barkodudur. UnitCode verilmişse barkod
+// o ambalajı temsil eder (koli barkodu okutulunca bir koli satılır).
+type ProductBarcode struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ProductID uint      `gorm:"index" json:"productId"`
+	Barcode   string    `gorm:"uniqueIndex" json:"barcode" binding:"required"`
+	Type      string    `json:"type"`
+	UnitCode  string    `json:"unitCode"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// Category ürün kategorisi ağacının bir düğümüdür. Path kökten düğüme kadar
+// ID'leri taşır ("/1/4/9/"); alt ağaç sorguları bu önekle yapılır.
+// Perishable işaretli kategorinin alt ağacındaki ürünler FEFO ile tüketilir;
+// boş CostingMethod üst kategoriden devralınır.
+type Category struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Name          string     `json:"name" binding:"required"`
+	ParentID      *uint      `json:"parentId"`
+	Parent        *Category  `gorm:"foreignKey:ParentID" json:"-"`
+	Path          string     `gorm:"index" json:"path"`
+	Perishable    bool       `json:"perishable"`
+	CostingMethod string     `json:"costingMethod" binding:"omitempty,oneof=fifo lifo average specific"`
+	FullName      string     `gorm:"-" json:"fullName,omitempty"`
+	Children      []Category `gorm:"foreignKey:ParentID" json:"children,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
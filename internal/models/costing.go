@@ -0,0 +1,19 @@
+package models
+
+// Maliyet yöntemleri. Ürün veya kategoride boş bırakılan yöntem en yakın üst
+// kategoriden devralınır; hiçbirinde tanımlı değilse FIFO kullanılır.
+const (
+	CostingFIFO     = "fifo"
+	CostingLIFO     = "lifo"
+	CostingAverage  = "average"
+	CostingSpecific = "specific"
+)
+
+// ValidCostingMethod yöntemin tanımlı olup olmadığını kontrol eder; boş değer devralma demektir
+func ValidCostingMethod(method string) bool {
+	switch method {
+	case "", CostingFIFO, CostingLIFO, CostingAverage, CostingSpecific:
+		return true
+	}
+	return false
+}
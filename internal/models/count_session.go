@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+)
+
+// Sayım oturumu durumları
+const (
+	CountOpen      = "open"
+	CountApproved  = "approved"
+	CountCancelled = "cancelled"
+)
+
+// CountSession fiziksel stok sayımıdır. Açılışta kapsamdaki ürünlerin parti bazında
+// beklenen miktarları satırlara kopyalanır; sayılan miktarlar birden çok turda girilir
+// ve onayda farklar sayım düzeltmesi olarak stoğa işlenir.
+type CountSession struct {
+	ID           uint        `gorm:"primaryKey" json:"id"`
+	Name         string      `json:"name" binding:"required"`
+	CategoryID   *uint       `json:"categoryId,omitempty"`
+	LocationID   *uint       `json:"locationId,omitempty"`
+	Status       string      `gorm:"index;default:open" json:"status"`
+	Note         string      `json:"note"`
+	OpenedAt     time.Time   `json:"openedAt"`
+	ApprovedAt   *time.Time  `json:"approvedAt,omitempty"`
+	VarianceCost float64     `json:"varianceCost"`
+	Lines        []CountLine `gorm:"foreignKey:CountSessionID" json:"lines,omitempty"`
+	CreatedAt    time.Time   `json:"createdAt"`
+	UpdatedAt    time.Time   `json:"updatedAt"`
+}
+
+// CountLine bir ürünün tek bir partisinin (StockMovementID) sayımıdır. Partisi olmayan
+// ürünler ve sayımda bulunan yeni stok için StockMovementID boş olan satır açılır.
+// Miktarlar ürünün kendi birimindedir; CountedQuantity sayılmamış satırda boştur.
+type CountLine struct {
+	ID                uint             `gorm:"primaryKey" json:"id"`
+	CountSessionID    uint             `gorm:"index" json:"countSessionId"`
+	ProductID         uint             `gorm:"index" json:"productId"`
+	Product           *Product         `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	StockMovementID   *uint            `json:"stockMovementId,omitempty"`
+	PurchaseLotID     *uint            `json:"purchaseLotId,omitempty"`
+	ExpiryDate        *time.Time       `json:"expiryDate,omitempty"`
+	ExpectedQuantity  float64          `json:"expectedQuantity"`
+	CountedQuantity   *float64         `json:"countedQuantity"`
+	UnitCost          float64          `json:"unitCost"`
+	VarianceQuantity  float64          `json:"varianceQuantity"`
+	VarianceCost      float64          `json:"varianceCost"`
+	CountedAt         *time.Time       `json:"countedAt,omitempty"`
+	StockAdjustmentID *uint            `json:"stockAdjustmentId,omitempty"`
+	StockAdjustment   *StockAdjustment `gorm:"foreignKey:StockAdjustmentID" json:"stockAdjustment,omitempty"`
+	CreatedAt         time.Time        `json:"createdAt"`
+	UpdatedAt         time.Time        `json:"updatedAt"`
+}
+
+// CalculateVariance sayılan ile beklenen miktar arasındaki farkı ve maliyetini hesaplar;
+// sayılmamış satırda fark sıfırdır
+func (l *CountLine) CalculateVariance() {
+	l.VarianceQuantity = 0
+	if l.CountedQuantity != nil {
+		l.VarianceQuantity = *l.CountedQuantity - l.ExpectedQuantity
+	}
+	l.VarianceCost = l.VarianceQuantity * l.UnitCost
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+type Customer struct {
+	ID        uint              `gorm:"primaryKey" json:"id"`
+	Name      string            `json:"name"`
+	Phone     string            `gorm:"index" json:"phone"`
+	Email     string            `json:"email"`
+	TaxNumber string            `gorm:"index" json:"taxNumber"`
+	TaxOffice string            `json:"taxOffice"`
+	Note      string            `json:"note"`
+	Addresses []CustomerAddress `gorm:"constraint:OnDelete:CASCADE;" json:"addresses"`
+	CreatedAt time.Time         `json:"createdAt"`
+	UpdatedAt time.Time         `json:"updatedAt"`
+}
+
+// CustomerAddress müşterinin teslimat ya da fatura adresidir
+type CustomerAddress struct {
+	ID         uint   `gorm:"primaryKey" json:"id"`
+	CustomerID uint   `gorm:"index" json:"customerId"`
+	Label      string `json:"label"`
+	Address    string `json:"address" binding:"required"`
+	District   string `json:"district"`
+	City       string `json:"city"`
+	PostalCode string `json:"postalCode"`
+	IsDefault  bool   `json:"isDefault"`
+}
+
+// NormalizePhone telefon numarasını yalnızca rakamlardan oluşan ulusal biçime çevirir;
+// "+90 (532) 111-22-33", "0532 111 22 33" ve "5321112233" aynı numaradır
+func NormalizePhone(phone string) string {
+	digits := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) {
+			return r
+		}
+		return -1
+	}, phone)
+
+	switch {
+	case len(digits) == 12 && strings.HasPrefix(digits, "90"):
+		return digits[2:]
+	case len(digits) == 11 && strings.HasPrefix(digits, "0"):
+		return digits[1:]
+	}
+	return digits
+}
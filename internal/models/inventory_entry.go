@@ -0,0 +1,54 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Envanter kaydı türleri
+const (
+	EntryReceipt    = "receipt"
+	EntrySale       = "sale"
+	EntryReturn     = "return"
+	EntryAdjustment = "adjustment"
+	EntryProduction = "production"
+	EntryTransfer   = "transfer"
+)
+
+// ErrJournalImmutable envanter kayıtlarının değiştirilemeyeceğini ve silinemeyeceğini belirtir
+var ErrJournalImmutable = errors.New("envanter kayıtları değiştirilemez")
+
+// InventoryEntry stok defterinin tek bir satırıdır. Defter yalnızca eklenerek büyür;
+// her stok değişikliği bir partinin (StockMovementID) miktarını işaretli olarak
+// değiştiren bir kayıt bırakır. Partilerin RemainingQuantity'si ve ürünlerin
+// CurrentStock'u bu kayıtların önbelleğidir ve defterden yeniden hesaplanabilir.
+// Quantity ürünün kendi birimindedir; Value kaydın parti maliyetiyle değeridir.
+type InventoryEntry struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	Type              string    `gorm:"index" json:"type"`
+	ProductID         uint      `gorm:"index" json:"productId"`
+	LocationID        uint      `gorm:"index" json:"locationId"`
+	StockMovementID   uint      `gorm:"index" json:"stockMovementId"`
+	PurchaseLotID     *uint     `json:"purchaseLotId,omitempty"`
+	SaleID            *uint     `gorm:"index" json:"saleId,omitempty"`
+	StockAdjustmentID *uint     `gorm:"index" json:"stockAdjustmentId,omitempty"`
+	StockTransferID   *uint     `gorm:"index" json:"stockTransferId,omitempty"`
+	ProductionID      *uint     `gorm:"index" json:"productionId,omitempty"`
+	Quantity          float64   `json:"quantity"`
+	UnitCost          float64   `json:"unitCost"`
+	Value             float64   `json:"value"`
+	OccurredAt        time.Time `gorm:"index" json:"occurredAt"`
+	CreatedAt         time.Time `json:"createdAt"`
+}
+
+// BeforeUpdate kayıtların güncellenmesini engeller
+func (e *InventoryEntry) BeforeUpdate(*gorm.DB) error {
+	return ErrJournalImmutable
+}
+
+// BeforeDelete kayıtların silinmesini engeller
+func (e *InventoryEntry) BeforeDelete(*gorm.DB) error {
+	return ErrJournalImmutable
+}
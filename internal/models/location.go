@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Location stokun tutulduğu yerdir (mağaza, mutfak deposu). Konum belirtilmeyen
+// alış, satış ve düzeltmeler varsayılan konumda yapılır.
+type Location struct {
+	ID          uint           `gorm:"primaryKey" json:"id"`
+	Name        string         `gorm:"uniqueIndex" json:"name" binding:"required"`
+	Description string         `json:"description"`
+	IsDefault   bool           `json:"isDefault"`
+	CreatedAt   time.Time      `json:"createdAt"`
+	UpdatedAt   time.Time      `json:"updatedAt"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"`
+}
+
+// DefaultLocation veritabanında konum yokken eklenen varsayılan konumdur
+func DefaultLocation() Location {
+	return Location{Name: "Ana Mağaza", IsDefault: true}
+}
+
+// ProductLocationLevel ürünün bir konumdaki stok seviyeleridir; tanımlı değilse
+// ürünün genel seviyeleri kullanılır
+type ProductLocationLevel struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	ProductID       uint      `gorm:"uniqueIndex:idx_product_location_level" json:"productId"`
+	LocationID      uint      `gorm:"uniqueIndex:idx_product_location_level" json:"locationId" binding:"required"`
+	Location        *Location `gorm:"foreignKey:LocationID" json:"location,omitempty"`
+	MinStock        float64   `json:"minStock" binding:"gte=0"`
+	ReorderQuantity float64   `json:"reorderQuantity" binding:"gte=0"`
+	TargetStock     float64   `json:"targetStock" binding:"gte=0"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
@@ -1,22 +1,49 @@
 package models
 
 import (
+	"strings"
 	"time"
+
+	"gorm.io/gorm"
 )
 
+// Product katalog kalemidir; alış partileri PurchaseLot olarak tutulur.
+// CurrentStock tüm konumların toplamıdır. MinStock, ReorderQuantity ve TargetStock ürün
+// birimindedir; 0 tanımsız demektir. Konuma özel seviyeler ProductLocationLevel ile tutulur.
+// CostingMethod boşsa maliyet yöntemi kategoriden devralınır. NameKey ürün adının
+// karşılaştırma anahtarıdır (bkz. ProductNameKey) ve aktif ürünler arasında tekildir.
 type Product struct {
-	ID           uint      `gorm:"primaryKey" json:"id"`
-	CompanyName  string    `json:"companyName"`
-	Category     string    `json:"category"`
-	ProductName  string    `json:"productName"`
-	Unit         string    `json:"unit"`
-	InvoiceNo    string    `json:"invoiceNo"`
-	InvoiceDate  time.Time `json:"invoiceDate"`
-	InitialStock float64   `json:"initialStock"`
-	CurrentStock float64   `json:"currentStock"`
-	UnitPrice    float64   `json:"unitPrice"`
-	VAT          float64   `json:"vat"`
-	TotalCost    float64   `json:"totalCost"`
-	CreatedAt    time.Time `json:"createdAt"`
-	UpdatedAt    time.Time `json:"updatedAt"`
+	ID              uint             `gorm:"primaryKey" json:"id"`
+	ProductName     string           `json:"productName"`
+	NameKey         string           `json:"-"`
+	SKU             string           `gorm:"index" json:"sku"`
+	Barcodes        []ProductBarcode `gorm:"foreignKey:ProductID" json:"barcodes,omitempty"`
+	CategoryID      *uint            `json:"categoryId"`
+	Category        *Category        `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
+	Unit            string           `json:"unit"`
+	CurrentStock    float64          `json:"currentStock"`
+	MinStock        float64          `json:"minStock"`
+	ReorderQuantity float64          `json:"reorderQuantity"`
+	TargetStock     float64          `json:"targetStock"`
+	CostingMethod   string           `json:"costingMethod" binding:"omitempty,oneof=fifo lifo average specific"`
+	SupplierID      *uint            `json:"supplierId,omitempty"`
+	Supplier        *Supplier        `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+	Lots            []PurchaseLot    `gorm:"foreignKey:ProductID" json:"lots,omitempty"`
+	CreatedAt       time.Time        `json:"createdAt"`
+	UpdatedAt       time.Time        `json:"updatedAt"`
+	DeletedAt       gorm.DeletedAt   `gorm:"index" json:"deletedAt,omitempty"`
+}
+
+// ProductNameKey ürün adlarını karşılaştırmak için anahtar üretir: baştaki ve sondaki
+// boşluklar atılır, ad Unicode kurallarıyla küçük harfe çevrilir ve Türkçe ı harfi i
+// sayılır. Böylece "SÜT", "süt" ve "Süt " aynı ürünü gösterir. SQLite'ın LOWER
+// fonksiyonu yalnızca ASCII harfleri çevirdiği için karşılaştırma bu anahtarla yapılır.
+func ProductNameKey(name string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(name)), "ı", "i")
+}
+
+// BeforeSave gorm hook'u ile ad anahtarını ürün adından güncelle
+func (p *Product) BeforeSave(*gorm.DB) error {
+	p.NameKey = ProductNameKey(p.ProductName)
+	return nil
 }
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+)
+
+// Production reçeteyle yapılan üretimdir. Quantity reçete satışındaki gibi reçete
+// adedidir; malzemeler ürünlerin maliyet yöntemiyle partilerden düşülür ve deftere
+// üretim olarak işlenir. CostOfGoods tüketilen partilerin maliyetidir.
+type Production struct {
+	ID             uint         `gorm:"primaryKey" json:"id"`
+	RecipeID       uint         `gorm:"index" json:"recipeId"`
+	Recipe         *Recipe      `gorm:"foreignKey:RecipeID" json:"recipe,omitempty"`
+	LocationID     *uint        `json:"locationId,omitempty"`
+	Quantity       float64      `json:"quantity"`
+	ProductionDate time.Time    `gorm:"index" json:"productionDate"`
+	CostOfGoods    float64      `json:"costOfGoods"`
+	Note           string       `json:"note"`
+	Usages         []StockUsage `gorm:"foreignKey:ProductionID" json:"usages,omitempty"`
+	CreatedAt      time.Time    `json:"createdAt"`
+	UpdatedAt      time.Time    `json:"updatedAt"`
+}
+
+// ProductionInput reçeteden üretim isteğidir
+type ProductionInput struct {
+	Quantity   float64   `json:"quantity" binding:"required,gt=0"`
+	Date       time.Time `json:"date" binding:"required"`
+	LocationID *uint     `json:"locationId"`
+	Note       string    `json:"note"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// PurchaseInvoice tek başlık altında birden çok alış partisi taşıyan alış faturasıdır
+type PurchaseInvoice struct {
+	ID          uint          `gorm:"primaryKey" json:"id"`
+	SupplierID  uint          `json:"supplierId" binding:"required"`
+	Supplier    *Supplier     `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+	InvoiceNo   string        `json:"invoiceNo" binding:"required"`
+	InvoiceDate time.Time     `json:"invoiceDate" binding:"required"`
+	LocationID  *uint         `json:"locationId,omitempty"`
+	Note        string        `json:"note"`
+	NetAmount   float64       `json:"netAmount"`
+	VatAmount   float64       `json:"vatAmount"`
+	TotalAmount float64       `json:"totalAmount"`
+	Lines       []PurchaseLot `gorm:"foreignKey:PurchaseInvoiceID" json:"lines" binding:"required,min=1,dive"`
+	CreatedAt   time.Time     `json:"createdAt"`
+	UpdatedAt   time.Time     `json:"updatedAt"`
+}
+
+// CalculateTotals satır ve fatura toplamlarını hesaplar
+func (i *PurchaseInvoice) CalculateTotals() {
+	i.NetAmount = 0
+	i.VatAmount = 0
+	for j := range i.Lines {
+		// Net tutar = Birim fiyat × Miktar, KDV tutarı = Net tutar × (KDV oranı / 100)
+		net := i.Lines[j].UnitPrice * i.Lines[j].InitialStock
+		vat := net * (i.Lines[j].VAT / 100.0)
+		i.Lines[j].TotalCost = net + vat
+
+		i.NetAmount += net
+		i.VatAmount += vat
+	}
+	i.TotalAmount = i.NetAmount + i.VatAmount
+}
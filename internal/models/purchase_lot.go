@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PurchaseLot bir katalog ürününe ait tek bir fatura kalemidir (alış partisi)
+type PurchaseLot struct {
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	ProductID         uint           `json:"productId"`
+	Product           *Product       `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	SupplierID        uint           `json:"supplierId"`
+	Supplier          *Supplier      `gorm:"foreignKey:SupplierID" json:"supplier,omitempty"`
+	PurchaseInvoiceID *uint          `json:"purchaseInvoiceId,omitempty"`
+	LocationID        *uint          `json:"locationId,omitempty"`
+	InvoiceNo         string         `json:"invoiceNo"`
+	InvoiceDate       time.Time      `json:"invoiceDate"`
+	ExpiryDate        *time.Time     `json:"expiryDate,omitempty"`
+	InitialStock      float64        `json:"initialStock"`
+	Unit              string         `json:"unit"`
+	UnitFactor        float64        `json:"unitFactor"`
+	UnitPrice         float64        `json:"unitPrice"`
+	VAT               float64        `json:"vat"`
+	TotalCost         float64        `json:"totalCost"`
+	StockMovement     *StockMovement `gorm:"foreignKey:PurchaseLotID" json:"stockMovement,omitempty"`
+	CreatedAt         time.Time      `json:"createdAt"`
+	UpdatedAt         time.Time      `json:"updatedAt"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"`
+}
+
+// StockFactor parti biriminin ürün birimine çarpanıdır; eski kayıtlarda 1 kabul edilir
+func (l *PurchaseLot) StockFactor() float64 {
+	if l.UnitFactor <= 0 {
+		return 1
+	}
+	return l.UnitFactor
+}
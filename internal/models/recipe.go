@@ -10,6 +10,8 @@ type Recipe struct {
 	Description    string       `json:"description"`
 	OutputQuantity float64      `json:"outputQuantity" binding:"required,gt=0"`
 	SuggestedPrice float64      `json:"suggestedPrice" binding:"omitempty,gte=0"`
+	CategoryID     *uint        `json:"categoryId"`
+	Category       *Category    `gorm:"foreignKey:CategoryID" json:"category,omitempty"`
 	RecipeItems    []RecipeItem `gorm:"constraint:OnDelete:CASCADE;" json:"recipeItems"`
 	CreatedAt      time.Time    `json:"createdAt"`
 	UpdatedAt      time.Time    `json:"updatedAt"`
@@ -22,16 +24,21 @@ type RecipeItem struct {
 	Recipe      *Recipe  `gorm:"foreignKey:RecipeID" json:"-"`
 	Product     *Product `gorm:"foreignKey:ProductID" json:"product,omitempty"`
 	Quantity    float64  `json:"quantity" binding:"required,gt=0"`
+	Unit        string   `json:"unit"`
 	Description string   `json:"description"`
 }
 
 type RecipeSale struct {
-	RecipeID  uint      `json:"recipeId" binding:"required"`
-	Quantity  float64   `json:"quantity" binding:"required,gt=0"`
-	SaleDate  time.Time `json:"saleDate" binding:"required"`
-	SalePrice float64   `json:"salePrice" binding:"required,gt=0"`
-	UnitCost  float64   `json:"unitCost" binding:"required,gte=0"`
-	Note      string    `json:"note"`
-	Discount  float64   `json:"discount"`
-	VAT       float64   `json:"vat"`
+	RecipeID      uint      `json:"recipeId" binding:"required"`
+	LocationID    *uint     `json:"locationId"`
+	CustomerID    *uint     `json:"customerId"`
+	CustomerName  string    `json:"customerName"`
+	CustomerPhone string    `json:"customerPhone"`
+	Quantity      float64   `json:"quantity" binding:"required,gt=0"`
+	SaleDate      time.Time `json:"saleDate" binding:"required"`
+	SalePrice     float64   `json:"salePrice" binding:"required,gt=0"`
+	UnitCost      float64   `json:"unitCost" binding:"omitempty,gte=0"`
+	Note          string    `json:"note"`
+	Discount      float64   `json:"discount"`
+	VAT           float64   `json:"vat"`
 }
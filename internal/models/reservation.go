@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+)
+
+// Rezervasyon durumları
+const (
+	ReservationActive    = "active"
+	ReservationConverted = "converted"
+	ReservationReleased  = "released"
+	ReservationExpired   = "expired"
+)
+
+// Reservation teslim edilmemiş bir sipariş için konumdaki stoğun süreli olarak ayrılmasıdır.
+// Rezervasyon partilere dokunmaz; etkin olduğu sürece ayrılan miktar satışlarda ve stok
+// sorgularında kullanılamaz sayılır. Satışa dönüştürülür, elle serbest bırakılır ya da
+// ExpiresAt geçince kendiliğinden düşer. Quantity rezervasyonun biriminde,
+// StockQuantity ürünün kendi birimindedir.
+type Reservation struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	ProductID     uint       `gorm:"index" json:"productId" binding:"required"`
+	Product       *Product   `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	LocationID    *uint      `gorm:"index" json:"locationId,omitempty"`
+	Quantity      float64    `json:"quantity" binding:"required,gt=0"`
+	Unit          string     `json:"unit"`
+	StockQuantity float64    `json:"stockQuantity"`
+	CustomerName  string     `json:"customerName" binding:"required"`
+	CustomerPhone string     `json:"customerPhone"`
+	Note          string     `json:"note"`
+	Status        string     `gorm:"index;default:active" json:"status"`
+	ExpiresAt     time.Time  `gorm:"index" json:"expiresAt" binding:"required"`
+	SaleID        *uint      `json:"saleId,omitempty"`
+	ReleasedAt    *time.Time `json:"releasedAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// IsActive rezervasyonun verilen anda stoğu tuttuğunu belirtir
+func (r *Reservation) IsActive(at time.Time) bool {
+	return r.Status == ReservationActive && r.ExpiresAt.After(at)
+}
@@ -6,27 +6,42 @@ import (
 	"gorm.io/gorm"
 )
 
+// Sale tek ürün ya da reçete satışıdır. UnitCost ve CostOfGoods istemciden alınmaz;
+// stok partilerden düşülürken tüketilen partilerin maliyetinden hesaplanır.
 type Sale struct {
-	ID            uint      `json:"id" gorm:"primarykey"`
-	ProductID     uint      `json:"productId" binding:"required"`
-	RecipeID      *uint     `json:"recipeId,omitempty"`
-	Product       Product   `json:"product" gorm:"foreignKey:ProductID;references:ID"`
-	ProductData   Product   `json:"-" gorm:"-"`
-	Recipe        *Recipe   `json:"recipe,omitempty" gorm:"foreignKey:RecipeID"`
-	Quantity      float64   `json:"quantity" binding:"required,gt=0"`
-	SaleDate      time.Time `json:"saleDate" binding:"required"`
-	SalePrice     float64   `json:"salePrice" binding:"required,gt=0"`
-	Discount      float64   `json:"discount" binding:"omitempty,gte=0"`
-	VAT           float64   `json:"vat" binding:"omitempty,gte=0,lte=100"`
-	NetPrice      float64   `json:"netPrice" gorm:"-"`
-	VatAmount     float64   `json:"vatAmount" gorm:"-"`
-	TotalPrice    float64   `json:"totalPrice" gorm:"-"`
-	CustomerName  string    `json:"customerName" binding:"required"`
-	CustomerPhone string    `json:"customerPhone" binding:"required"`
-	Note          string    `json:"note"`
-	UnitCost      float64   `json:"unitCost" binding:"required,gte=0"`
-	CreatedAt     time.Time `json:"createdAt"`
-	UpdatedAt     time.Time `json:"updatedAt"`
+	ID               uint      `json:"id" gorm:"primarykey"`
+	ProductID        uint      `json:"productId"`
+	Barcode          string    `json:"barcode,omitempty" gorm:"-"`
+	RecipeID         *uint     `json:"recipeId,omitempty"`
+	LocationID       *uint     `json:"locationId,omitempty"`
+	ReservationID    *uint     `json:"reservationId,omitempty"`
+	SalesOrderID     *uint     `json:"salesOrderId,omitempty" gorm:"index"`
+	CustomerID       *uint     `json:"customerId,omitempty" gorm:"index"`
+	Product          Product   `json:"product" gorm:"foreignKey:ProductID;references:ID"`
+	ProductData      Product   `json:"-" gorm:"-"`
+	Recipe           *Recipe   `json:"recipe,omitempty" gorm:"foreignKey:RecipeID"`
+	Quantity         float64   `json:"quantity" binding:"required,gt=0"`
+	Unit             string    `json:"unit"`
+	StockQuantity    float64   `json:"stockQuantity"`
+	SaleDate         time.Time `json:"saleDate" binding:"required"`
+	SalePrice        float64   `json:"salePrice" binding:"required,gt=0"`
+	Discount         float64   `json:"discount" binding:"omitempty,gte=0"`
+	VAT              float64   `json:"vat" binding:"omitempty,gte=0,lte=100"`
+	NetPrice         float64   `json:"netPrice" gorm:"-"`
+	VatAmount        float64   `json:"vatAmount" gorm:"-"`
+	TotalPrice       float64   `json:"totalPrice" gorm:"-"`
+	CustomerName     string    `json:"customerName"`
+	CustomerPhone    string    `json:"customerPhone"`
+	Note             string    `json:"note"`
+	UnitCost         float64   `json:"unitCost" binding:"omitempty,gte=0"`
+	LotID            *uint     `json:"lotId,omitempty"`
+	CostOfGoods      float64   `json:"costOfGoods"`
+	GrossProfit      float64   `json:"grossProfit" gorm:"-"`
+	MarginPercent    float64   `json:"marginPercent" gorm:"-"`
+	ReturnedQuantity float64   `json:"returnedQuantity"`
+	ReturnedCost     float64   `json:"returnedCost"`
+	CreatedAt        time.Time `json:"createdAt"`
+	UpdatedAt        time.Time `json:"updatedAt"`
 }
 
 // CalculatePrices fiyatları hesaplar
@@ -44,6 +59,23 @@ func (s *Sale) CalculatePrices() {
 
 	// Toplam fiyat = Net fiyat + KDV tutarı
 	s.TotalPrice = s.NetPrice + s.VatAmount
+
+	// Brüt kâr = İadeden sonra kalan net fiyat - İadeden sonra kalan maliyet;
+	// marj kalan net fiyata oranıdır
+	keptNet := s.NetPrice * s.KeptFraction()
+	s.GrossProfit = keptNet - (s.CostOfGoods - s.ReturnedCost)
+	s.MarginPercent = 0
+	if keptNet > 0 {
+		s.MarginPercent = s.GrossProfit / keptNet * 100
+	}
+}
+
+// KeptFraction satışın iade edilmeyen kısmının oranıdır
+func (s *Sale) KeptFraction() float64 {
+	if s.Quantity <= 0 {
+		return 1
+	}
+	return (s.Quantity - s.ReturnedQuantity) / s.Quantity
 }
 
 // AfterFind gorm hook'u ile fiyatları hesapla
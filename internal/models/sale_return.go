@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+)
+
+// İade edilen malın akıbeti: stoğa geri döner ya da fire olarak yazılır
+const (
+	ReturnRestock = "restock"
+	ReturnWaste   = "waste"
+)
+
+// SaleReturn satışın bir kısmının iadesidir ve müşteriye kesilen iade belgesidir.
+// Quantity satış birimindedir; tutarlar satışın birim fiyatı, iskontosu ve KDV'si
+// iade miktarına oranlanarak hesaplanır.
+type SaleReturn struct {
+	ID          uint             `gorm:"primaryKey" json:"id"`
+	SaleID      uint             `gorm:"index" json:"saleId"`
+	CustomerID  *uint            `gorm:"index" json:"customerId,omitempty"`
+	CreditNo    string           `gorm:"index" json:"creditNo"`
+	Quantity    float64          `json:"quantity"`
+	Unit        string           `json:"unit"`
+	Disposition string           `json:"disposition"`
+	Reason      string           `json:"reason"`
+	Note        string           `json:"note"`
+	ReturnDate  time.Time        `gorm:"index" json:"returnDate"`
+	NetAmount   float64          `json:"netAmount"`
+	VatAmount   float64          `json:"vatAmount"`
+	TotalAmount float64          `json:"totalAmount"`
+	CostOfGoods float64          `json:"costOfGoods"`
+	Lines       []SaleReturnLine `gorm:"foreignKey:SaleReturnID" json:"lines"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	UpdatedAt   time.Time        `json:"updatedAt"`
+}
+
+// SaleReturnLine iadenin satışta tüketilen bir partiye düşen kısmıdır. Fire olarak
+// yazılan iadelerde StockAdjustmentID fire kaydını gösterir.
+type SaleReturnLine struct {
+	ID                uint    `gorm:"primaryKey" json:"id"`
+	SaleReturnID      uint    `gorm:"index" json:"saleReturnId"`
+	StockUsageID      uint    `gorm:"index" json:"stockUsageId"`
+	StockMovementID   uint    `gorm:"index" json:"stockMovementId"`
+	ProductID         uint    `json:"productId"`
+	Quantity          float64 `json:"quantity"`
+	UnitCost          float64 `json:"unitCost"`
+	StockAdjustmentID *uint   `json:"stockAdjustmentId,omitempty"`
+}
+
+// SaleReturnInput iade isteğidir; Quantity satış birimindedir
+type SaleReturnInput struct {
+	Quantity    float64    `json:"quantity" binding:"required,gt=0"`
+	Disposition string     `json:"disposition" binding:"omitempty,oneof=restock waste"`
+	Reason      string     `json:"reason"`
+	Note        string     `json:"note"`
+	ReturnDate  *time.Time `json:"returnDate"`
+}
@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+)
+
+// SaleRevision satışta yapılan bir düzeltmedir. Number satış içinde 1'den başlayarak
+// artar; her değişen alan ayrı bir satırda eski ve yeni değeriyle saklanır.
+type SaleRevision struct {
+	ID        uint                 `gorm:"primaryKey" json:"id"`
+	SaleID    uint                 `gorm:"index" json:"saleId"`
+	Number    int                  `json:"number"`
+	Note      string               `json:"note"`
+	Changes   []SaleRevisionChange `gorm:"foreignKey:SaleRevisionID" json:"changes"`
+	CreatedAt time.Time            `json:"createdAt"`
+}
+
+// SaleRevisionChange düzeltmede değişen tek bir alandır; değerler metin olarak saklanır
+type SaleRevisionChange struct {
+	ID             uint   `gorm:"primaryKey" json:"id"`
+	SaleRevisionID uint   `gorm:"index" json:"saleRevisionId"`
+	Field          string `json:"field"`
+	OldValue       string `json:"oldValue"`
+	NewValue       string `json:"newValue"`
+}
+
+// SaleUpdateInput satış düzeltme isteğidir; yalnızca verilen alanlar değişir.
+// Quantity satış birimindedir; LotID miktar artışının düşüleceği alış partisidir.
+type SaleUpdateInput struct {
+	Quantity      *float64   `json:"quantity" binding:"omitempty,gt=0"`
+	LotID         *uint      `json:"lotId"`
+	SalePrice     *float64   `json:"salePrice" binding:"omitempty,gt=0"`
+	Discount      *float64   `json:"discount" binding:"omitempty,gte=0"`
+	VAT           *float64   `json:"vat" binding:"omitempty,gte=0,lte=100"`
+	CustomerID    *uint      `json:"customerId"`
+	CustomerName  *string    `json:"customerName"`
+	CustomerPhone *string    `json:"customerPhone"`
+	SaleDate      *time.Time `json:"saleDate"`
+	Note          *string    `json:"note"`
+	RevisionNote  string     `json:"revisionNote"`
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SalesOrder tek müşteriye yapılan, ürün ve reçete satırlarından oluşan satıştır.
+// Her satır bir Sale kaydıdır; iskonto ve KDV sipariş toplamına uygulanır.
+type SalesOrder struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	LocationID    *uint     `gorm:"index" json:"locationId,omitempty"`
+	Location      *Location `gorm:"foreignKey:LocationID" json:"location,omitempty"`
+	CustomerID    *uint     `gorm:"index" json:"customerId,omitempty"`
+	CustomerName  string    `json:"customerName"`
+	CustomerPhone string    `json:"customerPhone"`
+	OrderDate     time.Time `gorm:"index" json:"orderDate"`
+	Discount      float64   `json:"discount"`
+	VAT           float64   `json:"vat"`
+	Note          string    `json:"note"`
+	Lines         []Sale    `gorm:"foreignKey:SalesOrderID" json:"lines"`
+	Subtotal      float64   `gorm:"-" json:"subtotal"`
+	NetPrice      float64   `gorm:"-" json:"netPrice"`
+	VatAmount     float64   `gorm:"-" json:"vatAmount"`
+	TotalPrice    float64   `gorm:"-" json:"totalPrice"`
+	CostOfGoods   float64   `json:"costOfGoods"`
+	GrossProfit   float64   `gorm:"-" json:"grossProfit"`
+	MarginPercent float64   `gorm:"-" json:"marginPercent"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// SalesOrderLineInput sipariş satırıdır; ProductID (ya da Barcode) veya RecipeID verilir
+type SalesOrderLineInput struct {
+	ProductID *uint   `json:"productId"`
+	Barcode   string  `json:"barcode"`
+	RecipeID  *uint   `json:"recipeId"`
+	LotID     *uint   `json:"lotId"`
+	Quantity  float64 `json:"quantity" binding:"required,gt=0"`
+	Unit      string  `json:"unit"`
+	SalePrice float64 `json:"salePrice" binding:"required,gt=0"`
+	Discount  float64 `json:"discount" binding:"omitempty,gte=0"`
+	Note      string  `json:"note"`
+}
+
+// SalesOrderInput sipariş oluşturma isteğidir
+type SalesOrderInput struct {
+	LocationID    *uint                 `json:"locationId"`
+	CustomerID    *uint                 `json:"customerId"`
+	CustomerName  string                `json:"customerName"`
+	CustomerPhone string                `json:"customerPhone"`
+	OrderDate     time.Time             `json:"orderDate" binding:"required"`
+	Discount      float64               `json:"discount" binding:"omitempty,gte=0"`
+	VAT           float64               `json:"vat" binding:"omitempty,gte=0,lte=100"`
+	Note          string                `json:"note"`
+	Lines         []SalesOrderLineInput `json:"lines" binding:"required,min=1,dive"`
+}
+
+// CalculatePrices sipariş toplamlarını satırlardan hesaplar
+func (o *SalesOrder) CalculatePrices() {
+	// Ara toplam = satırların iskontolu tutarları toplamı
+	o.Subtotal = 0
+	for i := range o.Lines {
+		o.Lines[i].CalculatePrices()
+		o.Subtotal += o.Lines[i].NetPrice
+	}
+
+	// Net fiyat = Ara toplam - Sipariş iskontosu
+	o.NetPrice = o.Subtotal - o.Discount
+
+	// KDV tutarı = Net fiyat × (KDV oranı / 100)
+	o.VatAmount = 0
+	if o.VAT > 0 {
+		o.VatAmount = o.NetPrice * (o.VAT / 100.0)
+	}
+
+	// Toplam fiyat = Net fiyat + KDV tutarı
+	o.TotalPrice = o.NetPrice + o.VatAmount
+
+	// Satılan malın maliyeti = Satırların maliyetleri toplamı; satırlar yüklenmemişse
+	// kayıtlı değer kullanılır
+	if len(o.Lines) > 0 {
+		o.CostOfGoods = 0
+		for i := range o.Lines {
+			o.CostOfGoods += o.Lines[i].CostOfGoods
+		}
+	}
+
+	// Brüt kâr = Satırların iadeden sonra kalan net tutarları (sipariş iskontosu
+	// dağıtılarak) - İadeden sonra kalan maliyetleri; marj kalan net tutara oranıdır
+	factor := o.DiscountFactor()
+	var keptNet, keptCost float64
+	for i := range o.Lines {
+		keptNet += o.Lines[i].NetPrice * o.Lines[i].KeptFraction() * factor
+		keptCost += o.Lines[i].CostOfGoods - o.Lines[i].ReturnedCost
+	}
+	o.GrossProfit = keptNet - keptCost
+	o.MarginPercent = 0
+	if keptNet > 0 {
+		o.MarginPercent = o.GrossProfit / keptNet * 100
+	}
+}
+
+// DiscountFactor sipariş iskontosu satırlara net tutarlarıyla orantılı dağıtıldığında
+// satır tutarlarının çarpanıdır: satırın payına düşen net tutar NetPrice × çarpandır.
+// Satırlar siparişin KDV oranını taşıdığından KDV ve toplam tutarlar da aynı çarpanla
+// ölçeklenir. CalculatePrices'tan sonra kullanılır.
+func (o *SalesOrder) DiscountFactor() float64 {
+	if o.Subtotal <= 0 {
+		return 1
+	}
+	return o.NetPrice / o.Subtotal
+}
+
+// AfterFind gorm hook'u ile toplamları hesapla
+func (o *SalesOrder) AfterFind(*gorm.DB) error {
+	o.CalculatePrices()
+	return nil
+}
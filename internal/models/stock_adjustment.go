@@ -0,0 +1,40 @@
+package models
+
+import (
+	"time"
+)
+
+// Stok düzeltme nedenleri; stok yalnızca sayım düzeltmesiyle artırılabilir
+const (
+	AdjustmentWaste           = "waste"
+	AdjustmentBreakage        = "breakage"
+	AdjustmentTheft           = "theft"
+	AdjustmentSampling        = "sampling"
+	AdjustmentCountCorrection = "count_correction"
+)
+
+// StockAdjustment satış dışı stok değişikliğidir. Quantity işaretlidir: negatif
+// değer partilerden düşülür, pozitif değer UnitCost maliyetiyle yeni parti açar.
+// SourceMovementID yalnızca sayım onayında, çıkışın yapılacağı stok hareketini belirtir.
+type StockAdjustment struct {
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	ProductID        uint           `json:"productId" binding:"required"`
+	Product          *Product       `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Reason           string         `gorm:"index" json:"reason" binding:"required,oneof=waste breakage theft sampling count_correction"`
+	Quantity         float64        `json:"quantity" binding:"required"`
+	Unit             string         `json:"unit"`
+	StockQuantity    float64        `json:"stockQuantity"`
+	UnitCost         float64        `json:"unitCost" binding:"gte=0"`
+	TotalCost        float64        `json:"totalCost"`
+	LocationID       *uint          `json:"locationId,omitempty"`
+	LotID            *uint          `json:"lotId,omitempty"`
+	SourceMovementID *uint          `gorm:"-" json:"-"`
+	ExpiryDate       *time.Time     `gorm:"-" json:"expiryDate,omitempty"`
+	Note             string         `json:"note" binding:"required"`
+	AdjustmentDate   time.Time      `json:"adjustmentDate" binding:"required"`
+	StockMovementID  *uint          `json:"stockMovementId,omitempty"`
+	StockMovement    *StockMovement `gorm:"foreignKey:StockMovementID" json:"stockMovement,omitempty"`
+	Usages           []StockUsage   `gorm:"foreignKey:StockAdjustmentID" json:"usages,omitempty"`
+	CreatedAt        time.Time      `json:"createdAt"`
+	UpdatedAt        time.Time      `json:"updatedAt"`
+}
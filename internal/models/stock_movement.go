@@ -2,16 +2,50 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
+)
+
+// Stok hareketi türleri
+const (
+	MovementPurchase   = "purchase"
+	MovementAdjustment = "adjustment"
+	MovementTransfer   = "transfer"
 )
 
+// StockMovement stoğa giren bir partidir; RemainingQuantity tüketildikçe azalır.
+// Stok düzeltmesiyle yapılan çıkışlar geçmişte görünmesi için negatif miktarlı ve
+// kalanı sıfır olan bir hareket olarak kaydedilir. Transferle hedef konuma giren stok,
+// kaynak partinin kimliğini (PurchaseLotID), maliyetini ve son kullanma tarihini taşır.
 type StockMovement struct {
-	ID                uint      `gorm:"primaryKey" json:"id"`
-	ProductID         uint      `json:"productId"`
-	Product           Product   `gorm:"foreignKey:ProductID" json:"product"`
-	InitialQuantity   float64   `json:"initialQuantity"`
-	RemainingQuantity float64   `json:"remainingQuantity"`
-	UnitCost          float64   `json:"unitCost"`
-	MovementDate      time.Time `json:"movementDate"`
-	CreatedAt         time.Time `json:"createdAt"`
-	UpdatedAt         time.Time `json:"updatedAt"`
+	ID                uint           `gorm:"primaryKey" json:"id"`
+	ProductID         uint           `json:"productId"`
+	Product           Product        `gorm:"foreignKey:ProductID" json:"product"`
+	LocationID        uint           `gorm:"index" json:"locationId"`
+	Location          *Location      `gorm:"foreignKey:LocationID" json:"location,omitempty"`
+	Type              string         `gorm:"default:purchase" json:"type"`
+	PurchaseLotID     *uint          `json:"purchaseLotId,omitempty"`
+	StockAdjustmentID *uint          `gorm:"index" json:"stockAdjustmentId,omitempty"`
+	StockTransferID   *uint          `gorm:"index" json:"stockTransferId,omitempty"`
+	InitialQuantity   float64        `json:"initialQuantity"`
+	RemainingQuantity float64        `json:"remainingQuantity"`
+	UnitCost          float64        `json:"unitCost"`
+	MovementDate      time.Time      `json:"movementDate"`
+	ExpiryDate        *time.Time     `gorm:"index" json:"expiryDate,omitempty"`
+	CreatedAt         time.Time      `json:"createdAt"`
+	UpdatedAt         time.Time      `json:"updatedAt"`
+	DeletedAt         gorm.DeletedAt `gorm:"index" json:"deletedAt,omitempty"`
+}
+
+// BeforeCreate konumu verilmemiş hareketi varsayılan konuma yazar
+func (m *StockMovement) BeforeCreate(tx *gorm.DB) error {
+	if m.LocationID != 0 {
+		return nil
+	}
+	return tx.Session(&gorm.Session{NewDB: true}).
+		Model(&Location{}).
+		Select("id").
+		Where("is_default = ?", true).
+		Limit(1).
+		Scan(&m.LocationID).Error
 }
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+)
+
+// Stok transferi durumları
+const (
+	TransferDraft     = "draft"
+	TransferShipped   = "shipped"
+	TransferReceived  = "received"
+	TransferCancelled = "cancelled"
+)
+
+// StockTransfer stoğun bir konumdan diğerine taşınmasıdır. Taslak stoğa dokunmaz;
+// sevkte kaynak partiler FIFO sırasıyla düşülür ve stok teslim alınana kadar yoldadır
+// (hiçbir konumun stoğunda görünmez); teslimde hedef konumda aynı parti, maliyet ve
+// son kullanma tarihiyle yeniden stoğa girer.
+type StockTransfer struct {
+	ID             uint                       `gorm:"primaryKey" json:"id"`
+	FromLocationID uint                       `gorm:"index" json:"fromLocationId" binding:"required"`
+	FromLocation   *Location                  `gorm:"foreignKey:FromLocationID" json:"fromLocation,omitempty"`
+	ToLocationID   uint                       `gorm:"index" json:"toLocationId" binding:"required"`
+	ToLocation     *Location                  `gorm:"foreignKey:ToLocationID" json:"toLocation,omitempty"`
+	Status         string                     `gorm:"index;default:draft" json:"status"`
+	Note           string                     `json:"note"`
+	ShippedAt      *time.Time                 `json:"shippedAt,omitempty"`
+	ReceivedAt     *time.Time                 `json:"receivedAt,omitempty"`
+	Lines          []StockTransferLine        `gorm:"foreignKey:StockTransferID" json:"lines" binding:"required,min=1,dive"`
+	Discrepancies  []StockTransferDiscrepancy `gorm:"foreignKey:StockTransferID" json:"discrepancies,omitempty"`
+	CreatedAt      time.Time                  `json:"createdAt"`
+	UpdatedAt      time.Time                  `json:"updatedAt"`
+}
+
+// StockTransferLine transferdeki bir üründür. Quantity satırın biriminde, StockQuantity,
+// ShippedQuantity ve ReceivedQuantity ürünün kendi birimindedir.
+type StockTransferLine struct {
+	ID               uint                `gorm:"primaryKey" json:"id"`
+	StockTransferID  uint                `gorm:"index" json:"stockTransferId"`
+	ProductID        uint                `gorm:"index" json:"productId" binding:"required"`
+	Product          *Product            `gorm:"foreignKey:ProductID" json:"product,omitempty"`
+	Quantity         float64             `json:"quantity" binding:"required,gt=0"`
+	Unit             string              `json:"unit"`
+	StockQuantity    float64             `json:"stockQuantity"`
+	ShippedQuantity  float64             `json:"shippedQuantity"`
+	ShippedCost      float64             `json:"shippedCost"`
+	ReceivedQuantity float64             `json:"receivedQuantity"`
+	Items            []StockTransferItem `gorm:"foreignKey:StockTransferLineID" json:"items,omitempty"`
+	CreatedAt        time.Time           `json:"createdAt"`
+	UpdatedAt        time.Time           `json:"updatedAt"`
+}
+
+// StockTransferItem sevkte tek bir kaynak partiden düşülen miktardır; teslimde
+// DestinationMovementID hedef konumda açılan stok hareketini gösterir
+type StockTransferItem struct {
+	ID                    uint       `gorm:"primaryKey" json:"id"`
+	StockTransferLineID   uint       `gorm:"index" json:"stockTransferLineId"`
+	SourceMovementID      uint       `json:"sourceMovementId"`
+	PurchaseLotID         *uint      `json:"purchaseLotId,omitempty"`
+	ExpiryDate            *time.Time `json:"expiryDate,omitempty"`
+	Quantity              float64    `json:"quantity"`
+	UnitCost              float64    `json:"unitCost"`
+	ReceivedQuantity      float64    `json:"receivedQuantity"`
+	DestinationMovementID *uint      `json:"destinationMovementId,omitempty"`
+	CreatedAt             time.Time  `json:"createdAt"`
+	UpdatedAt             time.Time  `json:"updatedAt"`
+}
+
+// StockTransferDiscrepancy teslim alınan miktarın sevk edilenden farkıdır. Quantity
+// işaretlidir (eksik teslimde negatif) ve ürünün kendi birimindedir; Cost farkın
+// parti maliyetleriyle değeridir.
+type StockTransferDiscrepancy struct {
+	ID                  uint      `gorm:"primaryKey" json:"id"`
+	StockTransferID     uint      `gorm:"index" json:"stockTransferId"`
+	StockTransferLineID uint      `json:"stockTransferLineId"`
+	ProductID           uint      `gorm:"index" json:"productId"`
+	ShippedQuantity     float64   `json:"shippedQuantity"`
+	ReceivedQuantity    float64   `json:"receivedQuantity"`
+	Quantity            float64   `json:"quantity"`
+	Cost                float64   `json:"cost"`
+	Note                string    `json:"note"`
+	CreatedAt           time.Time `json:"createdAt"`
+}
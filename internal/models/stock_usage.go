@@ -4,11 +4,18 @@ import (
 	"time"
 )
 
+// StockUsage bir satışın, stok düzeltmesinin, transferin veya üretimin bir partiden tükettiği
+// miktardır. Satış miktarı azaltıldığında kullanım değiştirilmez; geri verilen miktar
+// aynı partiye eksi miktarlı ters kullanım olarak eklenir.
 type StockUsage struct {
-	ID              uint      `json:"id" gorm:"primarykey"`
-	SaleID          uint      `json:"saleId"`
-	StockMovementID uint      `json:"stockMovementId"`
-	UsedQuantity    float64   `json:"usedQuantity"`
-	CreatedAt       time.Time `json:"createdAt"`
-	UpdatedAt       time.Time `json:"updatedAt"`
+	ID                uint      `json:"id" gorm:"primarykey"`
+	SaleID            uint      `json:"saleId"`
+	StockAdjustmentID *uint     `gorm:"index" json:"stockAdjustmentId,omitempty"`
+	StockTransferID   *uint     `gorm:"index" json:"stockTransferId,omitempty"`
+	ProductionID      *uint     `gorm:"index" json:"productionId,omitempty"`
+	StockMovementID   uint      `json:"stockMovementId"`
+	UsedQuantity      float64   `json:"usedQuantity"`
+	UnitCost          float64   `json:"unitCost"`
+	CreatedAt         time.Time `json:"createdAt"`
+	UpdatedAt         time.Time `json:"updatedAt"`
 }
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+)
+
+type Supplier struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	Name            string    `json:"name"`
+	TaxNumber       string    `json:"taxNumber"`
+	TaxOffice       string    `json:"taxOffice"`
+	ContactName     string    `json:"contactName"`
+	Phone           string    `json:"phone"`
+	Email           string    `json:"email"`
+	Address         string    `json:"address"`
+	PaymentTermDays int       `json:"paymentTermDays"`
+	CreatedAt       time.Time `json:"createdAt"`
+	UpdatedAt       time.Time `json:"updatedAt"`
+}
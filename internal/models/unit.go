@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+)
+
+// Birim boyutları; dönüşüm yalnızca aynı boyuttaki birimler arasında yapılır
+const (
+	DimensionMass   = "mass"
+	DimensionVolume = "volume"
+	DimensionCount  = "count"
+)
+
+// Unit birim kaydıdır; Factor boyutun temel birimine (g, ml, adet) çarpandır
+type Unit struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Code      string    `gorm:"uniqueIndex" json:"code" binding:"required"`
+	Name      string    `json:"name"`
+	Dimension string    `json:"dimension" binding:"required,oneof=mass volume count"`
+	Factor    float64   `json:"factor" binding:"required,gt=0"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ProductUnit ürüne özel ambalaj dönüşümüdür (1 koli = 24 adet);
+// Factor bu birimin ürünün kendi birimi cinsinden karşılığıdır
+type ProductUnit struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ProductID uint      `gorm:"uniqueIndex:idx_product_unit" json:"productId"`
+	UnitCode  string    `gorm:"uniqueIndex:idx_product_unit" json:"unitCode" binding:"required"`
+	Factor    float64   `json:"factor" binding:"required,gt=0"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// DefaultUnits veritabanı boşken eklenen birimlerdir
+func DefaultUnits() []Unit {
+	return []Unit{
+		{Code: "mg", Name: "Miligram", Dimension: DimensionMass, Factor: 0.001},
+		{Code: "g", Name: "Gram", Dimension: DimensionMass, Factor: 1},
+		{Code: "kg", Name: "Kilogram", Dimension: DimensionMass, Factor: 1000},
+		{Code: "ml", Name: "Mililitre", Dimension: DimensionVolume, Factor: 1},
+		{Code: "cl", Name: "Santilitre", Dimension: DimensionVolume, Factor: 10},
+		{Code: "l", Name: "Litre", Dimension: DimensionVolume, Factor: 1000},
+		{Code: "adet", Name: "Adet", Dimension: DimensionCount, Factor: 1},
+		{Code: "düzine", Name: "Düzine", Dimension: DimensionCount, Factor: 12},
+	}
+}
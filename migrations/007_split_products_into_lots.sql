@@ -0,0 +1,52 @@
+-- Ürün tablosunu katalog kalemleri ve alış partileri olarak ayırır
+CREATE TABLE IF NOT EXISTS purchase_lots (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER,
+    company_name TEXT,
+    invoice_no TEXT,
+    invoice_date DATETIME,
+    initial_stock REAL,
+    unit_price REAL,
+    vat REAL,
+    total_cost REAL,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+ALTER TABLE stock_movements ADD COLUMN purchase_lot_id INTEGER;
+
+-- Her eski ürün satırı bir alış partisi olur
+INSERT INTO purchase_lots (id, product_id, company_name, invoice_no, invoice_date,
+    initial_stock, unit_price, vat, total_cost, created_at, updated_at)
+SELECT id, id, company_name, invoice_no, invoice_date,
+    initial_stock, unit_price, vat, total_cost, created_at, updated_at
+FROM products;
+
+UPDATE stock_movements SET purchase_lot_id = product_id WHERE purchase_lot_id IS NULL;
+
+-- Aynı isimli ürünler en küçük ID'li katalog kaleminde birleşir. İsimler TRIM ve LOWER
+-- ile karşılaştırılır; LOWER yalnızca ASCII harfleri çevirdiği için "SÜT" ile "süt" gibi
+-- farklar burada birleşmez. Kalan ürün adlarının baştaki/sondaki boşlukları atılır.
+CREATE TEMP TABLE product_merge AS
+SELECT p.id AS old_id,
+    (SELECT MIN(p2.id) FROM products p2
+     WHERE LOWER(TRIM(p2.product_name)) = LOWER(TRIM(p.product_name))) AS new_id
+FROM products p;
+
+UPDATE purchase_lots SET product_id = (SELECT new_id FROM product_merge WHERE old_id = purchase_lots.product_id);
+UPDATE stock_movements SET product_id = (SELECT new_id FROM product_merge WHERE old_id = stock_movements.product_id)
+WHERE product_id IN (SELECT old_id FROM product_merge);
+UPDATE sales SET product_id = (SELECT new_id FROM product_merge WHERE old_id = sales.product_id)
+WHERE product_id IN (SELECT old_id FROM product_merge);
+UPDATE recipe_items SET product_id = (SELECT new_id FROM product_merge WHERE old_id = recipe_items.product_id)
+WHERE product_id IN (SELECT old_id FROM product_merge);
+
+DELETE FROM products WHERE id NOT IN (SELECT new_id FROM product_merge);
+UPDATE products SET product_name = TRIM(product_name);
+UPDATE products SET current_stock = (
+    SELECT COALESCE(SUM(remaining_quantity), 0) FROM stock_movements WHERE stock_movements.product_id = products.id
+);
+
+DROP TABLE product_merge;
+
+-- Geri alma
+-- DROP TABLE purchase_lots;
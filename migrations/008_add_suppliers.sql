@@ -0,0 +1,39 @@
+-- Serbest metin firma adlarını tedarikçi kayıtlarına taşır
+CREATE TABLE IF NOT EXISTS suppliers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT,
+    tax_number TEXT,
+    tax_office TEXT,
+    contact_name TEXT,
+    phone TEXT,
+    email TEXT,
+    address TEXT,
+    payment_term_days INTEGER DEFAULT 0,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+ALTER TABLE purchase_lots ADD COLUMN supplier_id INTEGER;
+ALTER TABLE products ADD COLUMN supplier_id INTEGER;
+
+-- Büyük/küçük harf ve boşluk farkları aynı tedarikçi sayılır
+INSERT INTO suppliers (name, created_at, updated_at)
+SELECT MIN(TRIM(company_name)), CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+FROM purchase_lots
+WHERE TRIM(COALESCE(company_name, '')) != ''
+GROUP BY LOWER(TRIM(company_name));
+
+UPDATE purchase_lots SET supplier_id = (
+    SELECT s.id FROM suppliers s WHERE LOWER(s.name) = LOWER(TRIM(purchase_lots.company_name))
+);
+
+-- Ürünün varsayılan tedarikçisi en son alış partisinin tedarikçisidir
+UPDATE products SET supplier_id = (
+    SELECT pl.supplier_id FROM purchase_lots pl
+    WHERE pl.product_id = products.id AND pl.supplier_id IS NOT NULL
+    ORDER BY pl.invoice_date DESC, pl.id DESC
+    LIMIT 1
+)
+WHERE supplier_id IS NULL;
+
+-- Geri alma
+-- DROP TABLE suppliers;
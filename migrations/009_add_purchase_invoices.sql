@@ -0,0 +1,17 @@
+-- Çok satırlı alış faturaları
+CREATE TABLE IF NOT EXISTS purchase_invoices (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    supplier_id INTEGER,
+    invoice_no TEXT,
+    invoice_date DATETIME,
+    note TEXT,
+    net_amount REAL DEFAULT 0,
+    vat_amount REAL DEFAULT 0,
+    total_amount REAL DEFAULT 0,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+ALTER TABLE purchase_lots ADD COLUMN purchase_invoice_id INTEGER;
+
+-- Geri alma
+-- DROP TABLE purchase_invoices;
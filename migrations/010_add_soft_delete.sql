@@ -0,0 +1,12 @@
+-- Ürün, parti ve stok hareketleri için arşivleme (soft delete)
+ALTER TABLE products ADD COLUMN deleted_at DATETIME;
+ALTER TABLE purchase_lots ADD COLUMN deleted_at DATETIME;
+ALTER TABLE stock_movements ADD COLUMN deleted_at DATETIME;
+CREATE INDEX IF NOT EXISTS idx_products_deleted_at ON products(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_purchase_lots_deleted_at ON purchase_lots(deleted_at);
+CREATE INDEX IF NOT EXISTS idx_stock_movements_deleted_at ON stock_movements(deleted_at);
+
+-- Geri alma
+-- ALTER TABLE products DROP COLUMN deleted_at;
+-- ALTER TABLE purchase_lots DROP COLUMN deleted_at;
+-- ALTER TABLE stock_movements DROP COLUMN deleted_at;
@@ -0,0 +1,32 @@
+-- Birim kayıtları ve ürüne özel ambalaj dönüşümleri
+CREATE TABLE IF NOT EXISTS units (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    code TEXT UNIQUE,
+    name TEXT,
+    dimension TEXT,
+    factor REAL,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE TABLE IF NOT EXISTS product_units (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER,
+    unit_code TEXT,
+    factor REAL,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_unit ON product_units(product_id, unit_code);
+
+ALTER TABLE recipe_items ADD COLUMN unit TEXT;
+ALTER TABLE sales ADD COLUMN unit TEXT;
+ALTER TABLE sales ADD COLUMN stock_quantity REAL DEFAULT 0;
+ALTER TABLE purchase_lots ADD COLUMN unit TEXT;
+ALTER TABLE purchase_lots ADD COLUMN unit_factor REAL DEFAULT 1;
+
+-- Eski satışlar ürün birimiyle yapılmıştır
+UPDATE sales SET stock_quantity = quantity WHERE recipe_id IS NULL;
+
+-- Geri alma
+-- DROP TABLE product_units;
+-- DROP TABLE units;
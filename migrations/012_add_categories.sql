@@ -0,0 +1,27 @@
+-- Serbest metin kategorileri kategori ağacına taşır (eski değerler kök düğüm olur)
+CREATE TABLE IF NOT EXISTS categories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT,
+    parent_id INTEGER,
+    path TEXT,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_categories_path ON categories(path);
+ALTER TABLE products ADD COLUMN category_id INTEGER;
+ALTER TABLE recipes ADD COLUMN category_id INTEGER;
+
+INSERT INTO categories (name, created_at, updated_at)
+SELECT MIN(TRIM(category)), CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+FROM products
+WHERE TRIM(COALESCE(category, '')) != ''
+GROUP BY LOWER(TRIM(category));
+
+UPDATE categories SET path = '/' || id || '/' WHERE path IS NULL;
+
+UPDATE products SET category_id = (
+    SELECT c.id FROM categories c WHERE LOWER(c.name) = LOWER(TRIM(products.category))
+);
+
+-- Geri alma
+-- DROP TABLE categories;
@@ -0,0 +1,17 @@
+-- SKU ve ürün barkodları
+ALTER TABLE products ADD COLUMN sku TEXT;
+CREATE INDEX IF NOT EXISTS idx_products_sku ON products(sku);
+CREATE TABLE IF NOT EXISTS product_barcodes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER,
+    barcode TEXT,
+    type TEXT,
+    unit_code TEXT,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_barcodes_barcode ON product_barcodes(barcode);
+CREATE INDEX IF NOT EXISTS idx_product_barcodes_product_id ON product_barcodes(product_id);
+
+-- Geri alma
+-- DROP TABLE product_barcodes;
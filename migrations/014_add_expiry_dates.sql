@@ -0,0 +1,11 @@
+-- Parti son kullanma tarihleri ve bozulabilir kategoriler (FEFO)
+ALTER TABLE purchase_lots ADD COLUMN expiry_date DATETIME;
+ALTER TABLE stock_movements ADD COLUMN expiry_date DATETIME;
+CREATE INDEX IF NOT EXISTS idx_stock_movements_expiry_date ON stock_movements(expiry_date);
+ALTER TABLE categories ADD COLUMN perishable NUMERIC DEFAULT false;
+
+-- Geri alma
+-- DROP INDEX idx_stock_movements_expiry_date;
+-- ALTER TABLE categories DROP COLUMN perishable;
+-- ALTER TABLE stock_movements DROP COLUMN expiry_date;
+-- ALTER TABLE purchase_lots DROP COLUMN expiry_date;
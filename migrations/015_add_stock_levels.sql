@@ -0,0 +1,9 @@
+-- Ürün bazında minimum stok, sipariş miktarı ve hedef stok seviyeleri
+ALTER TABLE products ADD COLUMN min_stock REAL DEFAULT 0;
+ALTER TABLE products ADD COLUMN reorder_quantity REAL DEFAULT 0;
+ALTER TABLE products ADD COLUMN target_stock REAL DEFAULT 0;
+
+-- Geri alma
+-- ALTER TABLE products DROP COLUMN target_stock;
+-- ALTER TABLE products DROP COLUMN reorder_quantity;
+-- ALTER TABLE products DROP COLUMN min_stock;
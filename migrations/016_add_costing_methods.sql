@@ -0,0 +1,21 @@
+-- Ürün ve kategori bazında maliyet yöntemi (fifo, lifo, average, specific)
+ALTER TABLE products ADD COLUMN costing_method TEXT DEFAULT '';
+ALTER TABLE categories ADD COLUMN costing_method TEXT DEFAULT '';
+
+-- Stok kullanımları uygulanan birim maliyeti, satışlar satılan malın maliyetini taşır
+ALTER TABLE stock_usages ADD COLUMN unit_cost REAL DEFAULT 0;
+ALTER TABLE sales ADD COLUMN cost_of_goods REAL DEFAULT 0;
+
+UPDATE stock_usages SET unit_cost = (
+    SELECT sm.unit_cost FROM stock_movements sm WHERE sm.id = stock_usages.stock_movement_id
+);
+
+UPDATE sales SET cost_of_goods = COALESCE((
+    SELECT SUM(su.used_quantity * su.unit_cost) FROM stock_usages su WHERE su.sale_id = sales.id
+), 0);
+
+-- Geri alma
+-- ALTER TABLE sales DROP COLUMN cost_of_goods;
+-- ALTER TABLE stock_usages DROP COLUMN unit_cost;
+-- ALTER TABLE categories DROP COLUMN costing_method;
+-- ALTER TABLE products DROP COLUMN costing_method;
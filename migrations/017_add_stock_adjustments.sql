@@ -0,0 +1,35 @@
+-- Nedenli manuel stok düzeltmeleri (fire, kırılma, kayıp, numune, sayım düzeltmesi)
+CREATE TABLE IF NOT EXISTS stock_adjustments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER,
+    reason TEXT,
+    quantity REAL,
+    unit TEXT,
+    stock_quantity REAL,
+    unit_cost REAL,
+    total_cost REAL,
+    lot_id INTEGER,
+    note TEXT,
+    adjustment_date DATETIME,
+    stock_movement_id INTEGER,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_stock_adjustments_reason ON stock_adjustments(reason);
+
+-- Stok hareketleri türünü ve bağlı düzeltmeyi taşır; mevcut hareketler alıştır
+ALTER TABLE stock_movements ADD COLUMN type TEXT DEFAULT 'purchase';
+ALTER TABLE stock_movements ADD COLUMN stock_adjustment_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_stock_movements_stock_adjustment_id ON stock_movements(stock_adjustment_id);
+
+-- Düzeltmeyle düşülen stok kullanımları
+ALTER TABLE stock_usages ADD COLUMN stock_adjustment_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_stock_usages_stock_adjustment_id ON stock_usages(stock_adjustment_id);
+
+-- Geri alma
+-- DROP INDEX idx_stock_usages_stock_adjustment_id;
+-- ALTER TABLE stock_usages DROP COLUMN stock_adjustment_id;
+-- DROP INDEX idx_stock_movements_stock_adjustment_id;
+-- ALTER TABLE stock_movements DROP COLUMN stock_adjustment_id;
+-- ALTER TABLE stock_movements DROP COLUMN type;
+-- DROP TABLE stock_adjustments;
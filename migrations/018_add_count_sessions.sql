@@ -0,0 +1,38 @@
+-- Fiziksel stok sayımı oturumları ve parti bazında sayım satırları
+CREATE TABLE IF NOT EXISTS count_sessions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT,
+    category_id INTEGER,
+    status TEXT DEFAULT 'open',
+    note TEXT,
+    opened_at DATETIME,
+    approved_at DATETIME,
+    variance_cost REAL DEFAULT 0,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_count_sessions_status ON count_sessions(status);
+
+CREATE TABLE IF NOT EXISTS count_lines (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    count_session_id INTEGER,
+    product_id INTEGER,
+    stock_movement_id INTEGER,
+    purchase_lot_id INTEGER,
+    expiry_date DATETIME,
+    expected_quantity REAL DEFAULT 0,
+    counted_quantity REAL,
+    unit_cost REAL DEFAULT 0,
+    variance_quantity REAL DEFAULT 0,
+    variance_cost REAL DEFAULT 0,
+    counted_at DATETIME,
+    stock_adjustment_id INTEGER,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_count_lines_count_session_id ON count_lines(count_session_id);
+CREATE INDEX IF NOT EXISTS idx_count_lines_product_id ON count_lines(product_id);
+
+-- Geri alma
+-- DROP TABLE count_lines;
+-- DROP TABLE count_sessions;
@@ -0,0 +1,54 @@
+-- Stok konumları (mağaza, mutfak deposu) ve konum bazında stok seviyeleri
+CREATE TABLE IF NOT EXISTS locations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT,
+    description TEXT,
+    is_default NUMERIC DEFAULT false,
+    created_at DATETIME,
+    updated_at DATETIME,
+    deleted_at DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_locations_name ON locations(name);
+CREATE INDEX IF NOT EXISTS idx_locations_deleted_at ON locations(deleted_at);
+INSERT INTO locations (name, is_default, created_at, updated_at)
+SELECT 'Ana Mağaza', true, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP
+WHERE NOT EXISTS (SELECT 1 FROM locations WHERE is_default = true);
+
+CREATE TABLE IF NOT EXISTS product_location_levels (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER,
+    location_id INTEGER,
+    min_stock REAL DEFAULT 0,
+    reorder_quantity REAL DEFAULT 0,
+    target_stock REAL DEFAULT 0,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_product_location_level ON product_location_levels(product_id, location_id);
+
+-- Mevcut partiler, hareketler, satışlar ve düzeltmeler varsayılan konuma aittir
+ALTER TABLE stock_movements ADD COLUMN location_id INTEGER;
+ALTER TABLE purchase_lots ADD COLUMN location_id INTEGER;
+ALTER TABLE purchase_invoices ADD COLUMN location_id INTEGER;
+ALTER TABLE sales ADD COLUMN location_id INTEGER;
+ALTER TABLE stock_adjustments ADD COLUMN location_id INTEGER;
+ALTER TABLE count_sessions ADD COLUMN location_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_stock_movements_location_id ON stock_movements(location_id);
+
+UPDATE stock_movements SET location_id = (SELECT id FROM locations WHERE is_default = true) WHERE location_id IS NULL;
+UPDATE purchase_lots SET location_id = (SELECT id FROM locations WHERE is_default = true) WHERE location_id IS NULL;
+UPDATE purchase_invoices SET location_id = (SELECT id FROM locations WHERE is_default = true) WHERE location_id IS NULL;
+UPDATE sales SET location_id = (SELECT id FROM locations WHERE is_default = true) WHERE location_id IS NULL;
+UPDATE stock_adjustments SET location_id = (SELECT id FROM locations WHERE is_default = true) WHERE location_id IS NULL;
+UPDATE count_sessions SET location_id = (SELECT id FROM locations WHERE is_default = true) WHERE location_id IS NULL;
+
+-- Geri alma
+-- DROP INDEX idx_stock_movements_location_id;
+-- ALTER TABLE count_sessions DROP COLUMN location_id;
+-- ALTER TABLE stock_adjustments DROP COLUMN location_id;
+-- ALTER TABLE sales DROP COLUMN location_id;
+-- ALTER TABLE purchase_invoices DROP COLUMN location_id;
+-- ALTER TABLE purchase_lots DROP COLUMN location_id;
+-- ALTER TABLE stock_movements DROP COLUMN location_id;
+-- DROP TABLE product_location_levels;
+-- DROP TABLE locations;
@@ -0,0 +1,77 @@
+-- Konumlar arası stok transferleri, sevk edilen partiler ve teslim farkları
+CREATE TABLE IF NOT EXISTS stock_transfers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    from_location_id INTEGER,
+    to_location_id INTEGER,
+    status TEXT DEFAULT 'draft',
+    note TEXT,
+    shipped_at DATETIME,
+    received_at DATETIME,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_stock_transfers_from_location_id ON stock_transfers(from_location_id);
+CREATE INDEX IF NOT EXISTS idx_stock_transfers_to_location_id ON stock_transfers(to_location_id);
+CREATE INDEX IF NOT EXISTS idx_stock_transfers_status ON stock_transfers(status);
+
+CREATE TABLE IF NOT EXISTS stock_transfer_lines (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    stock_transfer_id INTEGER,
+    product_id INTEGER,
+    quantity REAL,
+    unit TEXT,
+    stock_quantity REAL,
+    shipped_quantity REAL DEFAULT 0,
+    shipped_cost REAL DEFAULT 0,
+    received_quantity REAL DEFAULT 0,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_stock_transfer_lines_stock_transfer_id ON stock_transfer_lines(stock_transfer_id);
+CREATE INDEX IF NOT EXISTS idx_stock_transfer_lines_product_id ON stock_transfer_lines(product_id);
+
+CREATE TABLE IF NOT EXISTS stock_transfer_items (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    stock_transfer_line_id INTEGER,
+    source_movement_id INTEGER,
+    purchase_lot_id INTEGER,
+    expiry_date DATETIME,
+    quantity REAL,
+    unit_cost REAL,
+    received_quantity REAL DEFAULT 0,
+    destination_movement_id INTEGER,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_stock_transfer_items_stock_transfer_line_id ON stock_transfer_items(stock_transfer_line_id);
+
+CREATE TABLE IF NOT EXISTS stock_transfer_discrepancies (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    stock_transfer_id INTEGER,
+    stock_transfer_line_id INTEGER,
+    product_id INTEGER,
+    shipped_quantity REAL,
+    received_quantity REAL,
+    quantity REAL,
+    cost REAL,
+    note TEXT,
+    created_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_stock_transfer_discrepancies_stock_transfer_id ON stock_transfer_discrepancies(stock_transfer_id);
+CREATE INDEX IF NOT EXISTS idx_stock_transfer_discrepancies_product_id ON stock_transfer_discrepancies(product_id);
+
+-- Transferle giren stok hareketleri ve transfer için yapılan stok kullanımları
+ALTER TABLE stock_movements ADD COLUMN stock_transfer_id INTEGER;
+ALTER TABLE stock_usages ADD COLUMN stock_transfer_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_stock_movements_stock_transfer_id ON stock_movements(stock_transfer_id);
+CREATE INDEX IF NOT EXISTS idx_stock_usages_stock_transfer_id ON stock_usages(stock_transfer_id);
+
+-- Geri alma
+-- DROP INDEX idx_stock_usages_stock_transfer_id;
+-- DROP INDEX idx_stock_movements_stock_transfer_id;
+-- ALTER TABLE stock_usages DROP COLUMN stock_transfer_id;
+-- ALTER TABLE stock_movements DROP COLUMN stock_transfer_id;
+-- DROP TABLE stock_transfer_discrepancies;
+-- DROP TABLE stock_transfer_items;
+-- DROP TABLE stock_transfer_lines;
+-- DROP TABLE stock_transfers;
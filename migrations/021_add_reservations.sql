@@ -0,0 +1,29 @@
+-- Teslim edilmemiş siparişler için süreli stok rezervasyonları
+CREATE TABLE IF NOT EXISTS reservations (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    product_id INTEGER,
+    location_id INTEGER,
+    quantity REAL,
+    unit TEXT,
+    stock_quantity REAL,
+    customer_name TEXT,
+    customer_phone TEXT,
+    note TEXT,
+    status TEXT DEFAULT 'active',
+    expires_at DATETIME,
+    sale_id INTEGER,
+    released_at DATETIME,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_reservations_product_id ON reservations(product_id);
+CREATE INDEX IF NOT EXISTS idx_reservations_location_id ON reservations(location_id);
+CREATE INDEX IF NOT EXISTS idx_reservations_status ON reservations(status);
+CREATE INDEX IF NOT EXISTS idx_reservations_expires_at ON reservations(expires_at);
+
+-- Rezervasyondan yapılan satışlar
+ALTER TABLE sales ADD COLUMN reservation_id INTEGER;
+
+-- Geri alma
+-- ALTER TABLE sales DROP COLUMN reservation_id;
+-- DROP TABLE reservations;
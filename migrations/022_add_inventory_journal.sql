@@ -0,0 +1,40 @@
+-- Yalnızca eklenebilen envanter defteri; parti kalanları ve ürün stokları bu kayıtlardan
+-- yeniden hesaplanabilir (cmd/rebuild)
+CREATE TABLE IF NOT EXISTS inventory_entries (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    type TEXT,
+    product_id INTEGER,
+    location_id INTEGER,
+    stock_movement_id INTEGER,
+    purchase_lot_id INTEGER,
+    sale_id INTEGER,
+    stock_adjustment_id INTEGER,
+    stock_transfer_id INTEGER,
+    quantity REAL,
+    unit_cost REAL,
+    value REAL,
+    occurred_at DATETIME,
+    created_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_inventory_entries_type ON inventory_entries(type);
+CREATE INDEX IF NOT EXISTS idx_inventory_entries_product_id ON inventory_entries(product_id);
+CREATE INDEX IF NOT EXISTS idx_inventory_entries_location_id ON inventory_entries(location_id);
+CREATE INDEX IF NOT EXISTS idx_inventory_entries_stock_movement_id ON inventory_entries(stock_movement_id);
+CREATE INDEX IF NOT EXISTS idx_inventory_entries_sale_id ON inventory_entries(sale_id);
+CREATE INDEX IF NOT EXISTS idx_inventory_entries_stock_adjustment_id ON inventory_entries(stock_adjustment_id);
+CREATE INDEX IF NOT EXISTS idx_inventory_entries_stock_transfer_id ON inventory_entries(stock_transfer_id);
+CREATE INDEX IF NOT EXISTS idx_inventory_entries_occurred_at ON inventory_entries(occurred_at);
+
+-- Kayıtlar değiştirilemez ve silinemez
+CREATE TRIGGER IF NOT EXISTS inventory_entries_no_update BEFORE UPDATE ON inventory_entries
+BEGIN SELECT RAISE(ABORT, 'envanter kayıtları değiştirilemez'); END;
+CREATE TRIGGER IF NOT EXISTS inventory_entries_no_delete BEFORE DELETE ON inventory_entries
+BEGIN SELECT RAISE(ABORT, 'envanter kayıtları değiştirilemez'); END;
+
+-- Açılış kayıtları burada yazılmaz: defter boşsa uygulama açılışta (database.InitDB)
+-- inventory.BackfillJournal ile mevcut partilerden ve kullanımlardan oluşturur
+
+-- Geri alma
+-- DROP TRIGGER inventory_entries_no_delete;
+-- DROP TRIGGER inventory_entries_no_update;
+-- DROP TABLE inventory_entries;
@@ -0,0 +1,24 @@
+-- Ürün ve reçete satırlarından oluşan satış siparişleri; satırlar satış kayıtlarıdır
+CREATE TABLE IF NOT EXISTS sales_orders (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    location_id INTEGER,
+    customer_name TEXT,
+    customer_phone TEXT,
+    order_date DATETIME,
+    discount REAL DEFAULT 0,
+    vat REAL DEFAULT 0,
+    note TEXT,
+    cost_of_goods REAL DEFAULT 0,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_sales_orders_location_id ON sales_orders(location_id);
+CREATE INDEX IF NOT EXISTS idx_sales_orders_order_date ON sales_orders(order_date);
+
+ALTER TABLE sales ADD COLUMN sales_order_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_sales_sales_order_id ON sales(sales_order_id);
+
+-- Geri alma
+-- DROP INDEX idx_sales_sales_order_id;
+-- ALTER TABLE sales DROP COLUMN sales_order_id;
+-- DROP TABLE sales_orders;
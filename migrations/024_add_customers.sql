@@ -0,0 +1,65 @@
+-- Müşteriler ve adresleri
+CREATE TABLE IF NOT EXISTS customers (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT,
+    phone TEXT,
+    email TEXT,
+    tax_number TEXT,
+    tax_office TEXT,
+    note TEXT,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_customers_phone ON customers(phone);
+CREATE INDEX IF NOT EXISTS idx_customers_tax_number ON customers(tax_number);
+
+CREATE TABLE IF NOT EXISTS customer_addresses (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    customer_id INTEGER REFERENCES customers(id) ON DELETE CASCADE,
+    label TEXT,
+    address TEXT,
+    district TEXT,
+    city TEXT,
+    postal_code TEXT,
+    is_default NUMERIC DEFAULT 0
+);
+CREATE INDEX IF NOT EXISTS idx_customer_addresses_customer_id ON customer_addresses(customer_id);
+
+-- Satışlar ve siparişler müşteriye bağlanır
+ALTER TABLE sales ADD COLUMN customer_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_sales_customer_id ON sales(customer_id);
+ALTER TABLE sales_orders ADD COLUMN customer_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_sales_orders_customer_id ON sales_orders(customer_id);
+
+-- Mevcut satışlardaki telefonlar rakamlara indirgenip (ülke kodu ve baştaki 0 atılarak)
+-- her numara için tek müşteri oluşturulur; ad numaranın son satışındaki addır
+CREATE TEMP TABLE sale_phones AS
+SELECT id, sale_date, customer_name, REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(
+    customer_phone, ' ', ''), '-', ''), '(', ''), ')', ''), '+', ''), '.', '') AS phone
+FROM sales;
+UPDATE sale_phones SET phone = SUBSTR(phone, 3) WHERE LENGTH(phone) = 12 AND phone LIKE '90%';
+UPDATE sale_phones SET phone = SUBSTR(phone, 2) WHERE LENGTH(phone) = 11 AND phone LIKE '0%';
+
+INSERT INTO customers (name, phone, created_at, updated_at)
+SELECT (SELECT TRIM(sp2.customer_name) FROM sale_phones sp2 WHERE sp2.phone = sp.phone
+        ORDER BY sp2.sale_date DESC, sp2.id DESC LIMIT 1),
+    sp.phone, MIN(sp.sale_date), CURRENT_TIMESTAMP
+FROM sale_phones sp
+WHERE sp.phone != '' AND sp.phone NOT IN (SELECT phone FROM customers WHERE phone IS NOT NULL)
+GROUP BY sp.phone;
+
+UPDATE sales SET customer_id = (
+    SELECT customers.id FROM sale_phones JOIN customers ON customers.phone = sale_phones.phone
+    WHERE sale_phones.id = sales.id
+) WHERE customer_id IS NULL;
+UPDATE sales_orders SET customer_id = (
+    SELECT sales.customer_id FROM sales WHERE sales.sales_order_id = sales_orders.id LIMIT 1
+) WHERE customer_id IS NULL;
+
+DROP TABLE sale_phones;
+
+-- Geri alma
+-- ALTER TABLE sales_orders DROP COLUMN customer_id;
+-- ALTER TABLE sales DROP COLUMN customer_id;
+-- DROP TABLE customer_addresses;
+-- DROP TABLE customers;
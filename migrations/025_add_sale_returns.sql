@@ -0,0 +1,48 @@
+-- Satışların kısmi iadeleri ve iade belgeleri
+CREATE TABLE IF NOT EXISTS sale_returns (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sale_id INTEGER,
+    customer_id INTEGER,
+    credit_no TEXT,
+    quantity REAL,
+    unit TEXT,
+    disposition TEXT DEFAULT 'restock',
+    reason TEXT,
+    note TEXT,
+    return_date DATETIME,
+    net_amount REAL DEFAULT 0,
+    vat_amount REAL DEFAULT 0,
+    total_amount REAL DEFAULT 0,
+    cost_of_goods REAL DEFAULT 0,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_sale_returns_sale_id ON sale_returns(sale_id);
+CREATE INDEX IF NOT EXISTS idx_sale_returns_customer_id ON sale_returns(customer_id);
+CREATE INDEX IF NOT EXISTS idx_sale_returns_credit_no ON sale_returns(credit_no);
+CREATE INDEX IF NOT EXISTS idx_sale_returns_return_date ON sale_returns(return_date);
+
+-- İadenin satışta tüketilen partilere dağılımı
+CREATE TABLE IF NOT EXISTS sale_return_lines (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sale_return_id INTEGER REFERENCES sale_returns(id),
+    stock_usage_id INTEGER,
+    stock_movement_id INTEGER,
+    product_id INTEGER,
+    quantity REAL,
+    unit_cost REAL,
+    stock_adjustment_id INTEGER
+);
+CREATE INDEX IF NOT EXISTS idx_sale_return_lines_sale_return_id ON sale_return_lines(sale_return_id);
+CREATE INDEX IF NOT EXISTS idx_sale_return_lines_stock_usage_id ON sale_return_lines(stock_usage_id);
+CREATE INDEX IF NOT EXISTS idx_sale_return_lines_stock_movement_id ON sale_return_lines(stock_movement_id);
+
+-- Satıştan iade edilen toplam miktar ve maliyeti
+ALTER TABLE sales ADD COLUMN returned_quantity REAL DEFAULT 0;
+ALTER TABLE sales ADD COLUMN returned_cost REAL DEFAULT 0;
+
+-- Geri alma
+-- ALTER TABLE sales DROP COLUMN returned_cost;
+-- ALTER TABLE sales DROP COLUMN returned_quantity;
+-- DROP TABLE sale_return_lines;
+-- DROP TABLE sale_returns;
@@ -0,0 +1,27 @@
+-- Satış düzeltmelerinin geçmişi
+CREATE TABLE IF NOT EXISTS sale_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sale_id INTEGER,
+    number INTEGER,
+    note TEXT,
+    created_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_sale_revisions_sale_id ON sale_revisions(sale_id);
+
+-- Düzeltmede değişen alanlar
+CREATE TABLE IF NOT EXISTS sale_revision_changes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    sale_revision_id INTEGER REFERENCES sale_revisions(id),
+    field TEXT,
+    old_value TEXT,
+    new_value TEXT
+);
+CREATE INDEX IF NOT EXISTS idx_sale_revision_changes_sale_revision_id ON sale_revision_changes(sale_revision_id);
+
+-- Satışta seçilen alış partisi; miktar artışı aynı partiden düşülür
+ALTER TABLE sales ADD COLUMN lot_id INTEGER;
+
+-- Geri alma
+-- ALTER TABLE sales DROP COLUMN lot_id;
+-- DROP TABLE sale_revision_changes;
+-- DROP TABLE sale_revisions;
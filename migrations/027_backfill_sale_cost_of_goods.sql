@@ -0,0 +1,21 @@
+-- Satılan malın maliyeti istemciden değil, satışın tükettiği partilerden hesaplanır.
+-- Kullanımları olan satışların maliyeti ve satış birimi başına maliyeti yeniden yazılır.
+UPDATE sales
+SET cost_of_goods = (
+    SELECT SUM(used_quantity * unit_cost) FROM stock_usages WHERE stock_usages.sale_id = sales.id
+)
+WHERE EXISTS (SELECT 1 FROM stock_usages WHERE stock_usages.sale_id = sales.id);
+
+UPDATE sales
+SET unit_cost = cost_of_goods / quantity
+WHERE quantity > 0
+  AND EXISTS (SELECT 1 FROM stock_usages WHERE stock_usages.sale_id = sales.id);
+
+-- Siparişin maliyeti satırlarının maliyetleri toplamıdır
+UPDATE sales_orders
+SET cost_of_goods = (
+    SELECT COALESCE(SUM(cost_of_goods), 0) FROM sales
+    WHERE sales.sales_order_id = sales_orders.id
+);
+
+-- Geri alma: istemcinin gönderdiği birim maliyetler geri getirilemez
@@ -0,0 +1,27 @@
+-- Reçeteden üretimler; malzeme tüketimi deftere üretim olarak işlenir
+CREATE TABLE IF NOT EXISTS productions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    recipe_id INTEGER,
+    location_id INTEGER,
+    quantity REAL,
+    production_date DATETIME,
+    cost_of_goods REAL DEFAULT 0,
+    note TEXT,
+    created_at DATETIME,
+    updated_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_productions_recipe_id ON productions(recipe_id);
+CREATE INDEX IF NOT EXISTS idx_productions_production_date ON productions(production_date);
+
+ALTER TABLE stock_usages ADD COLUMN production_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_stock_usages_production_id ON stock_usages(production_id);
+
+ALTER TABLE inventory_entries ADD COLUMN production_id INTEGER;
+CREATE INDEX IF NOT EXISTS idx_inventory_entries_production_id ON inventory_entries(production_id);
+
+-- Geri alma
+-- DROP INDEX idx_inventory_entries_production_id;
+-- ALTER TABLE inventory_entries DROP COLUMN production_id;
+-- DROP INDEX idx_stock_usages_production_id;
+-- ALTER TABLE stock_usages DROP COLUMN production_id;
+-- DROP TABLE productions;
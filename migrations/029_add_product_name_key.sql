@@ -0,0 +1,12 @@
+-- Ürün adlarının karşılaştırma anahtarı; aynı isimde iki aktif ürün açılamaz
+ALTER TABLE products ADD COLUMN name_key TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_products_name_key
+    ON products(name_key) WHERE deleted_at IS NULL AND name_key != '';
+
+-- Anahtarlar burada doldurulmaz: SQLite'ın LOWER fonksiyonu yalnızca ASCII harfleri
+-- çevirir. Uygulama açılışta (database.InitDB) boş anahtarları models.ProductNameKey ile
+-- doldurur.
+
+-- Geri alma
+-- DROP INDEX idx_products_name_key;
+-- ALTER TABLE products DROP COLUMN name_key;
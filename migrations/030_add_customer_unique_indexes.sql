@@ -0,0 +1,9 @@
+-- Aynı telefon ya da vergi numarasıyla iki müşteri kaydedilemez; boş değerler indekse girmez
+CREATE UNIQUE INDEX IF NOT EXISTS idx_customers_phone_unique
+    ON customers(phone) WHERE phone != '';
+CREATE UNIQUE INDEX IF NOT EXISTS idx_customers_tax_number_unique
+    ON customers(tax_number) WHERE tax_number != '';
+
+-- Geri alma
+-- DROP INDEX idx_customers_tax_number_unique;
+-- DROP INDEX idx_customers_phone_unique;
@@ -3,68 +3,2836 @@ package tests
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"stock-api/internal/api"
+	"stock-api/internal/api/middleware"
 	"stock-api/internal/database"
+	"stock-api/internal/inventory"
 	"stock-api/internal/models"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
 )
 
+func setupRouter(db *gorm.DB) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	api.SetupRouter(router.Group("/api/v1"), db)
+	return router
+}
+
 func TestCreateProduct(t *testing.T) {
 	db, err := database.InitDB()
 	assert.NoError(t, err)
 
-	router := api.SetupRouter(db)
+	router := setupRouter(db)
+
+	category := models.Category{Name: "Test Category"}
+	db.Create(&category)
 
 	product := models.Product{
-		CompanyName:  "Test Company",
-		Category:     "Test Category",
-		ProductName:  "Test Product",
-		Unit:         "Adet",
+		ProductName: fmt.Sprintf("Test Product %d", time.Now().UnixNano()),
+		CategoryID:  &category.ID,
+		Unit:        "Adet",
+	}
+
+	jsonValue, _ := json.Marshal(product)
+	req, _ := http.NewRequest("POST", "/api/v1/products", bytes.NewBuffer(jsonValue))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestProductNameKey(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	send := func(method, url string, body interface{}) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	category := models.Category{Name: "Test Category"}
+	db.Create(&category)
+	suffix := time.Now().UnixNano()
+
+	w := send("POST", "/api/v1/products", gin.H{"productName": fmt.Sprintf("SÜT IŞIK %d", suffix), "categoryId": category.ID, "unit": "Adet"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Data models.Product `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+
+	// Türkçe büyük/küçük harf ve boşluk farkları aynı isim sayılır
+	for _, name := range []string{fmt.Sprintf("süt ışık %d", suffix), fmt.Sprintf("  Süt Işık %d ", suffix)} {
+		w = send("POST", "/api/v1/products", gin.H{"productName": name, "categoryId": category.ID, "unit": "Adet"})
+		assert.Equal(t, http.StatusConflict, w.Code, name)
+	}
+
+	// Ortalama fiyat ad ile sorgulanırken de aynı anahtar kullanılır
+	w = send("GET", "/api/v1/products/average-price?name="+url.QueryEscape(fmt.Sprintf("süt ışık %d", suffix)), nil)
+	if assert.Equal(t, http.StatusOK, w.Code) {
+		var result struct {
+			Data struct {
+				ProductID uint `json:"productId"`
+			} `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		assert.Equal(t, created.Data.ID, result.Data.ProductID)
+	}
+
+	// Arşivdeyken aynı isimle açılan ürün varsa eskisi geri yüklenmez
+	w = send("DELETE", fmt.Sprintf("/api/v1/products/%d", created.Data.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send("POST", "/api/v1/products", gin.H{"productName": fmt.Sprintf("süt ışık %d", suffix), "categoryId": category.ID, "unit": "Adet"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", fmt.Sprintf("/api/v1/products/%d/restore", created.Data.ID), nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Kontrolü atlayan eşzamanlı kayıt veritabanındaki tekil indekse takılır
+	err = db.Create(&models.Product{ProductName: fmt.Sprintf("SÜT IŞIK %d", suffix), Unit: "Adet"}).Error
+	assert.True(t, database.IsUniqueViolation(err, "products.name_key"))
+}
+
+func TestCreateLot(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	product := models.Product{
+		ProductName: fmt.Sprintf("Test Product %d", time.Now().UnixNano()),
+		Unit:        "Adet",
+	}
+	db.Create(&product)
+
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+
+	lot := models.PurchaseLot{
+		SupplierID:   supplier.ID,
 		InvoiceNo:    "INV001",
+		InvoiceDate:  time.Now(),
 		InitialStock: 100,
-		CurrentStock: 100,
 		UnitPrice:    10.5,
 		VAT:          18,
 		TotalCost:    1239.0,
 	}
 
-	jsonValue, _ := json.Marshal(product)
-	req, _ := http.NewRequest("POST", "/api/v1/products", bytes.NewBuffer(jsonValue))
+	jsonValue, _ := json.Marshal(lot)
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/products/%d/lots", product.ID), bytes.NewBuffer(jsonValue))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var updated models.Product
+	db.First(&updated, product.ID)
+	assert.Equal(t, 100.0, updated.CurrentStock)
+}
+
+func TestGetSupplierPurchases(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+
+	product := models.Product{
+		ProductName: fmt.Sprintf("Test Product %d", time.Now().UnixNano()),
+		Unit:        "Adet",
+	}
+	db.Create(&product)
+
+	db.Create(&models.PurchaseLot{ProductID: product.ID, SupplierID: supplier.ID, InitialStock: 10, UnitPrice: 5, VAT: 20})
+	db.Create(&models.PurchaseLot{ProductID: product.ID, SupplierID: supplier.ID, InitialStock: 4, UnitPrice: 10, VAT: 10})
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/suppliers/%d/purchases", supplier.ID), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data struct {
+			Totals struct {
+				NetAmount   float64 `json:"netAmount"`
+				VatAmount   float64 `json:"vatAmount"`
+				TotalAmount float64 `json:"totalAmount"`
+			} `json:"totals"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.InDelta(t, 90.0, response.Data.Totals.NetAmount, 0.001)
+	assert.InDelta(t, 14.0, response.Data.Totals.VatAmount, 0.001)
+	assert.InDelta(t, 104.0, response.Data.Totals.TotalAmount, 0.001)
+}
+
+func TestCreatePurchaseInvoice(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+
+	product := models.Product{
+		ProductName: fmt.Sprintf("Test Product %d", time.Now().UnixNano()),
+		Unit:        "Adet",
+	}
+	db.Create(&product)
+
+	invoice := models.PurchaseInvoice{
+		SupplierID:  supplier.ID,
+		InvoiceNo:   fmt.Sprintf("INV-%d", time.Now().UnixNano()),
+		InvoiceDate: time.Now(),
+		Lines: []models.PurchaseLot{
+			{ProductID: product.ID, InitialStock: 10, UnitPrice: 5, VAT: 20},
+			{ProductID: product.ID, InitialStock: 4, UnitPrice: 10, VAT: 10},
+		},
+	}
+
+	jsonValue, _ := json.Marshal(invoice)
+	req, _ := http.NewRequest("POST", "/api/v1/purchase-invoices", bytes.NewBuffer(jsonValue))
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 
 	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created models.PurchaseInvoice
+	db.Preload("Lines").Where("invoice_no = ?", invoice.InvoiceNo).First(&created)
+	assert.Len(t, created.Lines, 2)
+	assert.InDelta(t, 104.0, created.TotalAmount, 0.001)
+
+	var updated models.Product
+	db.First(&updated, product.ID)
+	assert.Equal(t, 14.0, updated.CurrentStock)
+
+	// Satırlardan biri geçersizse hiçbir şey kaydedilmez
+	invoice.InvoiceNo = fmt.Sprintf("INV-%d", time.Now().UnixNano())
+	invoice.Lines = append(invoice.Lines, models.PurchaseLot{ProductID: 999999, InitialStock: 1, UnitPrice: 1})
+	jsonValue, _ = json.Marshal(invoice)
+	req, _ = http.NewRequest("POST", "/api/v1/purchase-invoices", bytes.NewBuffer(jsonValue))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	db.First(&updated, product.ID)
+	assert.Equal(t, 14.0, updated.CurrentStock)
+}
+
+func TestUpdateLot(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+
+	product := models.Product{
+		ProductName:  fmt.Sprintf("Test Product %d", time.Now().UnixNano()),
+		Unit:         "Adet",
+		CurrentStock: 6,
+	}
+	db.Create(&product)
+
+	lot := models.PurchaseLot{ProductID: product.ID, SupplierID: supplier.ID, InvoiceNo: "INV001", InitialStock: 10, UnitPrice: 5}
+	db.Create(&lot)
+	movement := models.StockMovement{ProductID: product.ID, PurchaseLotID: &lot.ID, InitialQuantity: 10, RemainingQuantity: 6, UnitCost: 5}
+	db.Create(&movement)
+	db.Create(&models.StockUsage{StockMovementID: movement.ID, UsedQuantity: 4})
+
+	// Kullanılan miktarın altına düzeltme reddedilir
+	req, _ := http.NewRequest("PATCH", fmt.Sprintf("/api/v1/lots/%d", lot.ID), bytes.NewBufferString(`{"initialStock": 3}`))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	req, _ = http.NewRequest("PATCH", fmt.Sprintf("/api/v1/lots/%d", lot.ID), bytes.NewBufferString(`{"initialStock": 12, "unitPrice": 6}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	db.First(&movement, movement.ID)
+	assert.Equal(t, 12.0, movement.InitialQuantity)
+	assert.Equal(t, 8.0, movement.RemainingQuantity)
+	assert.Equal(t, 6.0, movement.UnitCost)
+
+	var updated models.Product
+	db.First(&updated, product.ID)
+	assert.Equal(t, 8.0, updated.CurrentStock)
 }
 
 func TestDeleteProduct(t *testing.T) {
 	db, err := database.InitDB()
 	assert.NoError(t, err)
 
-	router := api.SetupRouter(db)
+	router := setupRouter(db)
 
 	// Önce test için bir ürün oluştur
 	product := models.Product{
-		CompanyName:  "Test Company",
-		Category:     "Test Category",
-		ProductName:  "Test Product",
+		ProductName: fmt.Sprintf("Test Product %d", time.Now().UnixNano()),
+		Unit:        "Adet",
+	}
+
+	db.Create(&product)
+
+	req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/v1/products/%d", product.ID), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestDeleteAndRestoreLot(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	product := models.Product{
+		ProductName:  fmt.Sprintf("Test Product %d", time.Now().UnixNano()),
 		Unit:         "Adet",
-		InvoiceNo:    "INV001",
-		InitialStock: 100,
-		CurrentStock: 100,
-		UnitPrice:    10.5,
-		VAT:          18,
-		TotalCost:    1239.0,
+		CurrentStock: 6,
+	}
+	db.Create(&product)
+
+	lot := models.PurchaseLot{ProductID: product.ID, InvoiceNo: "INV001", InitialStock: 10, UnitPrice: 5}
+	db.Create(&lot)
+	movement := models.StockMovement{ProductID: product.ID, PurchaseLotID: &lot.ID, InitialQuantity: 10, RemainingQuantity: 6, UnitCost: 5}
+	db.Create(&movement)
+	db.Create(&models.StockUsage{SaleID: 1, StockMovementID: movement.ID, UsedQuantity: 4})
+
+	// Satışta kullanılmış parti force olmadan silinemez
+	req, _ := http.NewRequest("DELETE", fmt.Sprintf("/api/v1/lots/%d", lot.ID), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/api/v1/lots/%d?force=true", lot.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var updated models.Product
+	db.First(&updated, product.ID)
+	assert.Equal(t, 0.0, updated.CurrentStock)
+
+	// Stoğundan düşüm yapılmış ürün de force olmadan silinemez
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/api/v1/products/%d", product.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/api/v1/products/%d?force=true", product.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/v1/products/%d/restore", product.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/v1/lots/%d/restore", lot.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Stok düzeltmesinde kullanılmış parti de force olmadan silinemez
+	adjustedLot := models.PurchaseLot{ProductID: product.ID, InvoiceNo: "INV002", InitialStock: 5, UnitPrice: 5}
+	db.Create(&adjustedLot)
+	adjusted := models.StockMovement{ProductID: product.ID, PurchaseLotID: &adjustedLot.ID, InitialQuantity: 5, RemainingQuantity: 3, UnitCost: 5}
+	db.Create(&adjusted)
+	adjustmentID := uint(1)
+	db.Create(&models.StockUsage{StockAdjustmentID: &adjustmentID, StockMovementID: adjusted.ID, UsedQuantity: 2})
+
+	req, _ = http.NewRequest("DELETE", fmt.Sprintf("/api/v1/lots/%d", adjustedLot.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var conflict struct {
+		References struct {
+			Sales       int64 `json:"sales"`
+			Adjustments int64 `json:"adjustments"`
+		} `json:"references"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &conflict))
+	assert.Equal(t, int64(0), conflict.References.Sales)
+	assert.Equal(t, int64(1), conflict.References.Adjustments)
+
+	db.First(&updated, product.ID)
+	assert.Equal(t, 6.0, updated.CurrentStock)
+}
+
+func TestUnitConversion(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+
+	product := models.Product{
+		ProductName: fmt.Sprintf("Test Product %d", time.Now().UnixNano()),
+		Unit:        "kg",
+	}
+	db.Create(&product)
+	db.Create(&models.ProductUnit{ProductID: product.ID, UnitCode: "çuval", Factor: 25})
+
+	// 2 çuval = 50 kg, birim maliyet kg başına 4
+	lot := models.PurchaseLot{SupplierID: supplier.ID, InvoiceNo: "INV001", InvoiceDate: time.Now(), InitialStock: 2, Unit: "çuval", UnitPrice: 100}
+	jsonValue, _ := json.Marshal(lot)
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/products/%d/lots", product.ID), bytes.NewBuffer(jsonValue))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var movement models.StockMovement
+	db.Where("product_id = ?", product.ID).First(&movement)
+	assert.Equal(t, 50.0, movement.InitialQuantity)
+	assert.Equal(t, 4.0, movement.UnitCost)
+
+	// 200 g satış stoktan 0.2 kg düşer
+	sale := models.Sale{
+		ProductID:     product.ID,
+		Quantity:      200,
+		Unit:          "g",
+		SaleDate:      time.Now(),
+		SalePrice:     0.01,
+		CustomerName:  "Test",
+		CustomerPhone: "555",
+		UnitCost:      4,
+	}
+	jsonValue, _ = json.Marshal(sale)
+	req, _ = http.NewRequest("POST", "/api/v1/sales", bytes.NewBuffer(jsonValue))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	db.First(&movement, movement.ID)
+	assert.InDelta(t, 49.8, movement.RemainingQuantity, 0.0001)
+
+	// Farklı boyuttaki birim reddedilir
+	sale.Unit = "l"
+	jsonValue, _ = json.Marshal(sale)
+	req, _ = http.NewRequest("POST", "/api/v1/sales", bytes.NewBuffer(jsonValue))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCategoryTree(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	createCategory := func(name string, parentID *uint) models.Category {
+		body, _ := json.Marshal(gin.H{"name": name, "parentId": parentID})
+		req, _ := http.NewRequest("POST", "/api/v1/categories", bytes.NewBuffer(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response struct {
+			Data models.Category `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Data
 	}
 
+	beverages := createCategory(fmt.Sprintf("Beverages %d", time.Now().UnixNano()), nil)
+	hot := createCategory("Hot", &beverages.ID)
+	coffee := createCategory("Coffee", &hot.ID)
+	cold := createCategory("Cold", &beverages.ID)
+
+	product := models.Product{
+		ProductName: fmt.Sprintf("Test Product %d", time.Now().UnixNano()),
+		CategoryID:  &coffee.ID,
+		Unit:        "Adet",
+	}
 	db.Create(&product)
 
-	req, _ := http.NewRequest("DELETE", "/api/v1/products/1", nil)
+	countProducts := func(categoryID uint) int {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/products?categoryId=%d", categoryID), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response struct {
+			Data []models.Product `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return len(response.Data)
+	}
+
+	// Üst kategori filtresi alt kategorilerdeki ürünleri de getirir
+	assert.Equal(t, 1, countProducts(beverages.ID))
+	assert.Equal(t, 0, countProducts(cold.ID))
+
+	// Düğüm kendi alt kategorisine taşınamaz
+	body, _ := json.Marshal(gin.H{"parentId": coffee.ID})
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/categories/%d/move", hot.ID), bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	body, _ = json.Marshal(gin.H{"parentId": cold.ID})
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/v1/categories/%d/move", hot.ID), bytes.NewBuffer(body))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var moved models.Category
+	db.First(&moved, coffee.ID)
+	assert.Equal(t, fmt.Sprintf("/%d/%d/%d/%d/", beverages.ID, cold.ID, hot.ID, coffee.ID), moved.Path)
+	assert.Equal(t, 1, countProducts(cold.ID))
+}
+
+// testEAN13 her çalıştırmada farklı, kontrol hanesi geçerli bir EAN-13 üretir
+func testEAN13() string {
+	data := fmt.Sprintf("%012d", time.Now().UnixNano()%1000000000000)
+	sum := 0
+	for i, r := range data {
+		digit := int(r - '0')
+		if i%2 == 1 {
+			sum += 3 * digit
+		} else {
+			sum += digit
+		}
+	}
+	return fmt.Sprintf("%s%d", data, (10-sum%10)%10)
+}
+
+func TestBarcodeLookupAndSale(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	category := models.Category{Name: "Test Category"}
+	db.Create(&category)
+
+	barcode := testEAN13()
+	product := models.Product{
+		ProductName: fmt.Sprintf("Test Product %d", time.Now().UnixNano()),
+		SKU:         fmt.Sprintf("SKU-%d", time.Now().UnixNano()),
+		CategoryID:  &category.ID,
+		Unit:        "adet",
+		Barcodes:    []models.ProductBarcode{{Barcode: barcode}},
+	}
+	jsonValue, _ := json.Marshal(product)
+	req, _ := http.NewRequest("POST", "/api/v1/products", bytes.NewBuffer(jsonValue))
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// Aynı SKU ikinci kez kullanılamaz
+	product.ProductName += " 2"
+	product.Barcodes = nil
+	jsonValue, _ = json.Marshal(product)
+	req, _ = http.NewRequest("POST", "/api/v1/products", bytes.NewBuffer(jsonValue))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	req, _ = http.NewRequest("GET", "/api/v1/products/lookup?barcode="+barcode, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data struct {
+			Product models.Product `json:"product"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	productID := response.Data.Product.ID
+	assert.NotZero(t, productID)
+
+	// Kontrol hanesi hatalı barkod reddedilir
+	req, _ = http.NewRequest("POST", fmt.Sprintf("/api/v1/products/%d/barcodes", productID), bytes.NewBufferString(`{"barcode": "4006381333932"}`))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	db.Create(&models.StockMovement{ProductID: productID, InitialQuantity: 5, RemainingQuantity: 5, UnitCost: 1})
+
+	sale := gin.H{
+		"barcode":       barcode,
+		"quantity":      2,
+		"saleDate":      time.Now(),
+		"salePrice":     3,
+		"customerName":  "Test",
+		"customerPhone": "555",
+		"unitCost":      1,
+	}
+	jsonValue, _ = json.Marshal(sale)
+	req, _ = http.NewRequest("POST", "/api/v1/sales", bytes.NewBuffer(jsonValue))
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var created models.Sale
+	db.Where("product_id = ?", productID).First(&created)
+	assert.Equal(t, 2.0, created.Quantity)
+}
 
+func TestExpiryAndFEFO(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	// Bozulabilirlik üst kategoriden alt kategorilere geçer
+	dairy := models.Category{Name: fmt.Sprintf("Dairy %d", time.Now().UnixNano()), Perishable: true}
+	db.Create(&dairy)
+	db.Model(&dairy).Update("path", fmt.Sprintf("/%d/", dairy.ID))
+	milk := models.Category{Name: "Milk", ParentID: &dairy.ID}
+	db.Create(&milk)
+	db.Model(&milk).Update("path", fmt.Sprintf("/%d/%d/", dairy.ID, milk.ID))
+
+	product := models.Product{
+		ProductName: fmt.Sprintf("Test Product %d", time.Now().UnixNano()),
+		CategoryID:  &milk.ID,
+		Unit:        "adet",
+	}
+	db.Create(&product)
+
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+
+	now := time.Now()
+	createLot := func(invoiceAgo, expiresIn int) models.PurchaseLot {
+		expiry := now.AddDate(0, 0, expiresIn)
+		lot := models.PurchaseLot{
+			SupplierID:   supplier.ID,
+			InvoiceNo:    "INV-FEFO",
+			InvoiceDate:  now.AddDate(0, 0, -invoiceAgo),
+			ExpiryDate:   &expiry,
+			InitialStock: 5,
+			UnitPrice:    2,
+		}
+		jsonValue, _ := json.Marshal(lot)
+		req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/products/%d/lots", product.ID), bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		var response struct {
+			Data models.PurchaseLot `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		return response.Data
+	}
+
+	longLife := createLot(10, 20)
+	shortLife := createLot(5, 3)
+	expired := createLot(20, -2)
+
+	sell := func(quantity float64) int {
+		jsonValue, _ := json.Marshal(gin.H{
+			"productId":     product.ID,
+			"quantity":      quantity,
+			"saleDate":      now,
+			"salePrice":     3,
+			"customerName":  "Test",
+			"customerPhone": "555",
+			"unitCost":      2,
+		})
+		req, _ := http.NewRequest("POST", "/api/v1/sales", bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Alış tarihi daha yeni olsa da son kullanma tarihi en yakın parti önce tüketilir
+	assert.Equal(t, http.StatusCreated, sell(4))
+
+	var shortMovement, longMovement models.StockMovement
+	db.Where("purchase_lot_id = ?", shortLife.ID).First(&shortMovement)
+	assert.Equal(t, 1.0, shortMovement.RemainingQuantity)
+	db.Where("purchase_lot_id = ?", longLife.ID).First(&longMovement)
+	assert.Equal(t, 5.0, longMovement.RemainingQuantity)
+
+	// Süresi geçmiş parti satılabilir stoğa sayılmaz
+	assert.Equal(t, http.StatusBadRequest, sell(7))
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/stock/expiring?days=7&categoryId=%d", dairy.ID), nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data struct {
+			Lots []struct {
+				PurchaseLotID *uint `json:"purchaseLotId"`
+				Expired       bool  `json:"expired"`
+			} `json:"lots"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.Len(t, response.Data.Lots, 2) {
+		assert.Equal(t, expired.ID, *response.Data.Lots[0].PurchaseLotID)
+		assert.True(t, response.Data.Lots[0].Expired)
+		assert.Equal(t, shortLife.ID, *response.Data.Lots[1].PurchaseLotID)
+		assert.False(t, response.Data.Lots[1].Expired)
+	}
+}
+
+func TestLowStockAlerts(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	category := models.Category{Name: fmt.Sprintf("Alerts %d", time.Now().UnixNano())}
+	db.Create(&category)
+	db.Model(&category).Update("path", fmt.Sprintf("/%d/", category.ID))
+
+	milk := models.Product{
+		ProductName: fmt.Sprintf("Milk %d", time.Now().UnixNano()),
+		CategoryID:  &category.ID,
+		Unit:        "l",
+	}
+	db.Create(&milk)
+	sugar := models.Product{
+		ProductName:     fmt.Sprintf("Sugar %d", time.Now().UnixNano()),
+		CategoryID:      &category.ID,
+		Unit:            "kg",
+		MinStock:        20,
+		ReorderQuantity: 5,
+		TargetStock:     30,
+	}
+	db.Create(&sugar)
+	coffee := models.Product{
+		ProductName: fmt.Sprintf("Coffee %d", time.Now().UnixNano()),
+		CategoryID:  &category.ID,
+		Unit:        "kg",
+		MinStock:    2,
+	}
+	db.Create(&coffee)
+
+	for _, p := range []models.Product{milk, sugar, coffee} {
+		db.Create(&models.StockMovement{ProductID: p.ID, InitialQuantity: 10, RemainingQuantity: 10, UnitCost: 1, MovementDate: time.Now()})
+	}
+
+	recipe := models.Recipe{
+		Name:           fmt.Sprintf("Latte %d", time.Now().UnixNano()),
+		OutputQuantity: 1,
+		RecipeItems:    []models.RecipeItem{{ProductID: milk.ID, Quantity: 3}},
+	}
+	db.Create(&recipe)
+
+	jsonValue, _ := json.Marshal(gin.H{
+		"recipeId":  recipe.ID,
+		"quantity":  3,
+		"saleDate":  time.Now(),
+		"salePrice": 5,
+		"unitCost":  1,
+	})
+	req, _ := http.NewRequest("POST", "/api/v1/recipe-sales", bytes.NewBuffer(jsonValue))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Less(t, w.Code, 300)
+
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/alerts/low-stock?categoryId=%d", category.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data struct {
+			Alerts []struct {
+				ProductID      uint     `json:"productId"`
+				AvailableStock float64  `json:"availableStock"`
+				RecipeDemand   float64  `json:"recipeDemand"`
+				ProjectedStock float64  `json:"projectedStock"`
+				SuggestedOrder float64  `json:"suggestedOrder"`
+				Recipes        []string `json:"recipes"`
+			} `json:"alerts"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+
+	// Şeker minimumun 10 altında; sütün seviyesi tanımlı değil ama reçete tüketimi kalan
+	// stoğu 8 aşıyor; kahve minimumun üstünde
+	if assert.Len(t, response.Data.Alerts, 2) {
+		first, second := response.Data.Alerts[0], response.Data.Alerts[1]
+		assert.Equal(t, sugar.ID, first.ProductID)
+		assert.Equal(t, 20.0, first.SuggestedOrder)
+
+		assert.Equal(t, milk.ID, second.ProductID)
+		assert.Equal(t, 1.0, second.AvailableStock)
+		assert.Equal(t, 9.0, second.RecipeDemand)
+		assert.Equal(t, -8.0, second.ProjectedStock)
+		assert.Equal(t, []string{recipe.Name}, second.Recipes)
+	}
+}
+
+func TestStockAdjustments(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	product := models.Product{
+		ProductName: fmt.Sprintf("Flour %d", time.Now().UnixNano()),
+		Unit:        "kg",
+	}
+	db.Create(&product)
+	db.Create(&models.StockMovement{ProductID: product.ID, InitialQuantity: 4, RemainingQuantity: 4, UnitCost: 2, MovementDate: time.Now().AddDate(0, 0, -2)})
+	db.Create(&models.StockMovement{ProductID: product.ID, InitialQuantity: 4, RemainingQuantity: 4, UnitCost: 3, MovementDate: time.Now().AddDate(0, 0, -1)})
+	db.Model(&product).Update("current_stock", 8)
+
+	post := func(body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest("POST", "/api/v1/stock-adjustments", bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Fire eski partiden başlayarak düşülür
+	w := post(gin.H{
+		"productId":      product.ID,
+		"reason":         models.AdjustmentWaste,
+		"quantity":       -5,
+		"note":           "Nem aldı",
+		"adjustmentDate": time.Now(),
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var response struct {
+		Data models.StockAdjustment `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, -11.0, response.Data.TotalCost)
+	assert.Len(t, response.Data.Usages, 2)
+
+	// Sayım düzeltmesi dışındaki nedenlerle stok artırılamaz
+	w = post(gin.H{
+		"productId":      product.ID,
+		"reason":         models.AdjustmentSampling,
+		"quantity":       2,
+		"unitCost":       1,
+		"note":           "Numune iadesi",
+		"adjustmentDate": time.Now(),
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Eldeki stoktan fazlası düşülemez
+	w = post(gin.H{
+		"productId":      product.ID,
+		"reason":         models.AdjustmentTheft,
+		"quantity":       -10,
+		"note":           "Depo açığı",
+		"adjustmentDate": time.Now(),
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Sayım fazlası seçilen maliyetle yeni parti açar
+	w = post(gin.H{
+		"productId":      product.ID,
+		"reason":         models.AdjustmentCountCorrection,
+		"quantity":       2,
+		"unitCost":       5,
+		"note":           "Sayım fazlası",
+		"adjustmentDate": time.Now(),
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var updated models.Product
+	db.First(&updated, product.ID)
+	assert.Equal(t, 5.0, updated.CurrentStock)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/stock-movements?productId=%d&type=adjustment", product.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var movements struct {
+		Data []models.StockMovement `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &movements))
+	if assert.Len(t, movements.Data, 2) {
+		assert.Equal(t, -5.0, movements.Data[0].InitialQuantity)
+		assert.Equal(t, 0.0, movements.Data[0].RemainingQuantity)
+		assert.Equal(t, 2.0, movements.Data[1].RemainingQuantity)
+		assert.Equal(t, 5.0, movements.Data[1].UnitCost)
+	}
+}
+
+func TestCountSession(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	category := models.Category{Name: fmt.Sprintf("Count %d", time.Now().UnixNano())}
+	db.Create(&category)
+	db.Model(&category).Update("path", fmt.Sprintf("/%d/", category.ID))
+
+	// Ürün kartındaki stok partilerden sapmış durumda
+	rice := models.Product{
+		ProductName:  fmt.Sprintf("Rice %d", time.Now().UnixNano()),
+		CategoryID:   &category.ID,
+		Unit:         "kg",
+		CurrentStock: 15,
+	}
+	db.Create(&rice)
+	first := models.StockMovement{ProductID: rice.ID, InitialQuantity: 4, RemainingQuantity: 4, UnitCost: 2, MovementDate: time.Now().AddDate(0, 0, -2)}
+	db.Create(&first)
+	second := models.StockMovement{ProductID: rice.ID, InitialQuantity: 6, RemainingQuantity: 6, UnitCost: 3, MovementDate: time.Now().AddDate(0, 0, -1)}
+	db.Create(&second)
+
+	salt := models.Product{
+		ProductName: fmt.Sprintf("Salt %d", time.Now().UnixNano()),
+		CategoryID:  &category.ID,
+		Unit:        "kg",
+	}
+	db.Create(&salt)
+
+	send := func(method, url string, body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/api/v1/count-sessions", gin.H{"name": "Ay sonu", "categoryId": category.ID})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var opened struct {
+		Data models.CountSession `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &opened))
+	assert.Len(t, opened.Data.Lines, 3)
+	url := fmt.Sprintf("/api/v1/count-sessions/%d", opened.Data.ID)
+
+	// İlk tur: ilk partide eksik, ikinci partinin bir kısmı sayıldı
+	w = send("POST", url+"/counts", gin.H{"counts": []gin.H{
+		{"productId": rice.ID, "stockMovementId": first.ID, "quantity": 3},
+		{"productId": rice.ID, "stockMovementId": second.ID, "quantity": 2},
+	}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// İkinci tur: ikinci partinin kalanı eklenir, hiç partisi olmayan üründen stok bulunur
+	w = send("POST", url+"/counts", gin.H{"add": true, "counts": []gin.H{
+		{"productId": rice.ID, "stockMovementId": second.ID, "quantity": 4},
+		{"productId": salt.ID, "quantity": 2, "unitCost": 5},
+	}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	req, _ := http.NewRequest("GET", url, nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var summary struct {
+		Data struct {
+			Products []struct {
+				ProductID        uint    `json:"productId"`
+				BookStock        float64 `json:"bookStock"`
+				VarianceQuantity float64 `json:"varianceQuantity"`
+			} `json:"products"`
+			VarianceCost   float64 `json:"varianceCost"`
+			UncountedLines int     `json:"uncountedLines"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+	assert.Equal(t, 8.0, summary.Data.VarianceCost)
+	assert.Zero(t, summary.Data.UncountedLines)
+	if assert.Len(t, summary.Data.Products, 2) {
+		assert.Equal(t, 15.0, summary.Data.Products[0].BookStock)
+		assert.Equal(t, -1.0, summary.Data.Products[0].VarianceQuantity)
+	}
+
+	w = send("POST", url+"/approve", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	db.First(&first, first.ID)
+	assert.Equal(t, 3.0, first.RemainingQuantity)
+
+	var updatedRice, updatedSalt models.Product
+	db.First(&updatedRice, rice.ID)
+	assert.Equal(t, 9.0, updatedRice.CurrentStock)
+	db.First(&updatedSalt, salt.ID)
+	assert.Equal(t, 2.0, updatedSalt.CurrentStock)
+
+	var adjustments int64
+	db.Model(&models.StockAdjustment{}).Where("product_id IN ?", []uint{rice.ID, salt.ID}).Count(&adjustments)
+	assert.Equal(t, int64(2), adjustments)
+
+	// Onaylanan oturuma sayım girilemez
+	w = send("POST", url+"/counts", gin.H{"counts": []gin.H{{"productId": salt.ID, "quantity": 1}}})
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestCountSessionSalesDuringCount(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	send := func(method, url string, body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now()
+	category := models.Category{Name: fmt.Sprintf("Count %d", now.UnixNano())}
+	db.Create(&category)
+	db.Model(&category).Update("path", fmt.Sprintf("/%d/", category.ID))
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+	flour := models.Product{ProductName: fmt.Sprintf("Flour %d", now.UnixNano()), CategoryID: &category.ID, Unit: "kg"}
+	db.Create(&flour)
+	w := send("POST", fmt.Sprintf("/api/v1/products/%d/lots", flour.ID), gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-1", "invoiceDate": now.AddDate(0, 0, -1), "initialStock": 10, "unitPrice": 2})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var lot models.StockMovement
+	assert.NoError(t, db.Where("product_id = ?", flour.ID).First(&lot).Error)
+
+	sell := func(quantity float64) {
+		w := send("POST", "/api/v1/sales", gin.H{"productId": flour.ID, "quantity": quantity, "saleDate": time.Now(), "salePrice": 5, "customerName": "Test", "customerPhone": "555"})
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	w = send("POST", "/api/v1/count-sessions", gin.H{"name": "Gün sonu", "categoryId": category.ID})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var opened struct {
+		Data models.CountSession `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &opened))
+	url := fmt.Sprintf("/api/v1/count-sessions/%d", opened.Data.ID)
+
+	// Açılıştan sonra 2 kg satılır; rafta 7 kg sayılır, yani 1 kg eksik
+	sell(2)
+	w = send("POST", url+"/counts", gin.H{"counts": []gin.H{{"productId": flour.ID, "stockMovementId": lot.ID, "quantity": 7}}})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Sayımdan sonraki 1 kg satış farkı değiştirmez
+	sell(1)
+	w = send("POST", url+"/approve", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var approved struct {
+		Data models.CountSession `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &approved))
+	if assert.Len(t, approved.Data.Lines, 1) {
+		assert.InDelta(t, 8, approved.Data.Lines[0].ExpectedQuantity, 1e-9)
+		assert.InDelta(t, -1, approved.Data.Lines[0].VarianceQuantity, 1e-9)
+	}
+
+	db.First(&lot, lot.ID)
+	assert.InDelta(t, 6, lot.RemainingQuantity, 1e-9)
+	var updated models.Product
+	db.First(&updated, flour.ID)
+	assert.InDelta(t, 6, updated.CurrentStock, 1e-9)
+}
+
+func TestMultiLocation(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	send := func(method, url string, body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("POST", "/api/v1/locations", gin.H{"name": fmt.Sprintf("Kitchen %d", time.Now().UnixNano())})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Data models.Location `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	kitchen := created.Data
+
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+	product := models.Product{
+		ProductName: fmt.Sprintf("Butter %d", time.Now().UnixNano()),
+		Unit:        "kg",
+		MinStock:    1,
+	}
+	db.Create(&product)
+
+	// Konumsuz parti varsayılan konuma, diğeri mutfağa girer
+	lotURL := fmt.Sprintf("/api/v1/products/%d/lots", product.ID)
+	w = send("POST", lotURL, gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-MAIN", "invoiceDate": time.Now().AddDate(0, 0, -2), "initialStock": 10, "unitPrice": 2})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", lotURL, gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-KITCHEN", "invoiceDate": time.Now().AddDate(0, 0, -1), "initialStock": 3, "unitPrice": 4, "locationId": kitchen.ID})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	sale := gin.H{
+		"productId":     product.ID,
+		"quantity":      4,
+		"saleDate":      time.Now(),
+		"salePrice":     10,
+		"unitCost":      1,
+		"customerName":  "Test",
+		"customerPhone": "555",
+		"locationId":    kitchen.ID,
+	}
+
+	// Toplam stok yetse de mutfaktaki stok yetmez
+	w = send("POST", "/api/v1/sales", sale)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	sale["quantity"] = 2
+	w = send("POST", "/api/v1/sales", sale)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var movements struct {
+		Data []models.StockMovement `json:"data"`
+	}
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/stock-movements?productId=%d&locationId=%d", product.ID, kitchen.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &movements))
+	if assert.Len(t, movements.Data, 1) {
+		assert.Equal(t, 1.0, movements.Data[0].RemainingQuantity)
+	}
+
+	var price struct {
+		Data struct {
+			TotalStock   float64 `json:"totalStock"`
+			AveragePrice float64 `json:"averagePrice"`
+		} `json:"data"`
+	}
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/products/average-price?productId=%d&locationId=%d", product.ID, kitchen.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &price))
+	assert.Equal(t, 1.0, price.Data.TotalStock)
+	assert.Equal(t, 4.0, price.Data.AveragePrice)
+
+	var updated models.Product
+	db.First(&updated, product.ID)
+	assert.Equal(t, 11.0, updated.CurrentStock)
+
+	// Mutfağın kendi seviyesi ürünün genel seviyesinin yerine geçer
+	w = send("PUT", fmt.Sprintf("/api/v1/products/%d/location-levels", product.ID), gin.H{"locationId": kitchen.ID, "minStock": 2, "targetStock": 5})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var alerts struct {
+		Data struct {
+			Alerts []struct {
+				ProductID      uint    `json:"productId"`
+				MinStock       float64 `json:"minStock"`
+				SuggestedOrder float64 `json:"suggestedOrder"`
+			} `json:"alerts"`
+		} `json:"data"`
+	}
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/alerts/low-stock?locationId=%d", kitchen.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &alerts))
+
+	found := false
+	for _, alert := range alerts.Data.Alerts {
+		if alert.ProductID == product.ID {
+			found = true
+			assert.Equal(t, 2.0, alert.MinStock)
+			assert.Equal(t, 6.0, alert.SuggestedOrder)
+		}
+	}
+	assert.True(t, found)
+
+	// Stoğu olan konum silinemez
+	w = send("DELETE", fmt.Sprintf("/api/v1/locations/%d", kitchen.ID), nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestStockTransfers(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	send := func(method, url string, body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	var store models.Location
+	assert.NoError(t, db.Where("is_default = ?", true).First(&store).Error)
+
+	w := send("POST", "/api/v1/locations", gin.H{"name": fmt.Sprintf("Kitchen %d", time.Now().UnixNano())})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created struct {
+		Data models.Location `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	kitchen := created.Data
+
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+	product := models.Product{
+		ProductName: fmt.Sprintf("Flour %d", time.Now().UnixNano()),
+		Unit:        "kg",
+	}
+	db.Create(&product)
+
+	// Eski parti 4 kg x 2, yeni parti 10 kg x 3; ikisi de mağazada
+	lotURL := fmt.Sprintf("/api/v1/products/%d/lots", product.ID)
+	var lots [2]models.PurchaseLot
+	for i, body := range []gin.H{
+		{"supplierId": supplier.ID, "invoiceNo": "INV-OLD", "invoiceDate": time.Now().AddDate(0, 0, -2), "initialStock": 4, "unitPrice": 2},
+		{"supplierId": supplier.ID, "invoiceNo": "INV-NEW", "invoiceDate": time.Now().AddDate(0, 0, -1), "initialStock": 10, "unitPrice": 3},
+	} {
+		w = send("POST", lotURL, body)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		var lot struct {
+			Data models.PurchaseLot `json:"data"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &lot))
+		lots[i] = lot.Data
+	}
+
+	w = send("POST", "/api/v1/stock-transfers", gin.H{
+		"fromLocationId": store.ID,
+		"toLocationId":   store.ID,
+		"lines":          []gin.H{{"productId": product.ID, "quantity": 6}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Taslak stoğa dokunmaz
+	w = send("POST", "/api/v1/stock-transfers", gin.H{
+		"fromLocationId": store.ID,
+		"toLocationId":   kitchen.ID,
+		"lines":          []gin.H{{"productId": product.ID, "quantity": 6}},
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var transfer struct {
+		Data models.StockTransfer `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &transfer))
+	assert.Equal(t, models.TransferDraft, transfer.Data.Status)
+	transferURL := fmt.Sprintf("/api/v1/stock-transfers/%d", transfer.Data.ID)
+
+	var updated models.Product
+	db.First(&updated, product.ID)
+	assert.Equal(t, 14.0, updated.CurrentStock)
+
+	// Sevkte partiler FIFO sırasıyla düşülür ve stok yola çıkar
+	w = send("POST", transferURL+"/ship", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var shipped struct {
+		Data models.StockTransfer `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &shipped))
+	assert.Equal(t, models.TransferShipped, shipped.Data.Status)
+	if assert.Len(t, shipped.Data.Lines, 1) && assert.Len(t, shipped.Data.Lines[0].Items, 2) {
+		assert.Equal(t, 14.0, shipped.Data.Lines[0].ShippedCost)
+		assert.Equal(t, lots[0].ID, *shipped.Data.Lines[0].Items[0].PurchaseLotID)
+		assert.Equal(t, 4.0, shipped.Data.Lines[0].Items[0].Quantity)
+	}
+
+	w = send("POST", transferURL+"/ship", nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var updatedAfterShip models.Product
+	db.First(&updatedAfterShip, product.ID)
+	assert.Equal(t, 8.0, updatedAfterShip.CurrentStock)
+
+	var inTransit struct {
+		Data struct {
+			Items []struct {
+				ProductID uint    `json:"productId"`
+				Quantity  float64 `json:"quantity"`
+				Cost      float64 `json:"cost"`
+			} `json:"items"`
+		} `json:"data"`
+	}
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/stock-transfers/in-transit?locationId=%d", kitchen.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &inTransit))
+	if assert.Len(t, inTransit.Data.Items, 1) {
+		assert.Equal(t, 6.0, inTransit.Data.Items[0].Quantity)
+		assert.Equal(t, 14.0, inTransit.Data.Items[0].Cost)
+	}
+
+	var asOf struct {
+		Data struct {
+			TotalValue     float64 `json:"totalValue"`
+			InTransitValue float64 `json:"inTransitValue"`
+		} `json:"data"`
+	}
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/inventory/as-of?productId=%d&date=%s", product.ID, time.Now().Add(time.Second).Format(time.RFC3339)), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &asOf))
+	assert.Equal(t, 24.0, asOf.Data.TotalValue)
+	assert.Equal(t, 14.0, asOf.Data.InTransitValue)
+
+	// 1 kg eksik teslim alınır; eksik son partiden düşer ve fark kaydı açılır
+	w = send("POST", transferURL+"/receive", gin.H{
+		"lines": []gin.H{{"lineId": shipped.Data.Lines[0].ID, "receivedQuantity": 5, "note": "Bir paket yırtık"}},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var received struct {
+		Data models.StockTransfer `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &received))
+	assert.Equal(t, models.TransferReceived, received.Data.Status)
+	if assert.Len(t, received.Data.Discrepancies, 1) {
+		assert.Equal(t, -1.0, received.Data.Discrepancies[0].Quantity)
+		assert.Equal(t, -3.0, received.Data.Discrepancies[0].Cost)
+	}
+
+	var movements struct {
+		Data []models.StockMovement `json:"data"`
+	}
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/stock-movements?productId=%d&locationId=%d", product.ID, kitchen.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &movements))
+	if assert.Len(t, movements.Data, 2) {
+		byLot := make(map[uint]models.StockMovement)
+		for _, m := range movements.Data {
+			assert.Equal(t, models.MovementTransfer, m.Type)
+			byLot[*m.PurchaseLotID] = m
+		}
+		assert.Equal(t, 4.0, byLot[lots[0].ID].RemainingQuantity)
+		assert.Equal(t, 2.0, byLot[lots[0].ID].UnitCost)
+		assert.Equal(t, 1.0, byLot[lots[1].ID].RemainingQuantity)
+		assert.Equal(t, 3.0, byLot[lots[1].ID].UnitCost)
+	}
+
+	var updatedAfterReceive models.Product
+	db.First(&updatedAfterReceive, product.ID)
+	assert.Equal(t, 13.0, updatedAfterReceive.CurrentStock)
+
+	// Partinin alış hareketi transferden sonra da partiye bağlı kalır
+	var lot struct {
+		Data models.PurchaseLot `json:"data"`
+	}
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/lots/%d", lots[0].ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &lot))
+	if assert.NotNil(t, lot.Data.StockMovement) {
+		assert.Equal(t, models.MovementPurchase, lot.Data.StockMovement.Type)
+		assert.Equal(t, store.ID, lot.Data.StockMovement.LocationID)
+	}
+
+	// Taşınan parti hedef konumda parti numarasıyla satılabilir
+	w = send("POST", "/api/v1/sales", gin.H{
+		"productId":     product.ID,
+		"lotId":         lots[0].ID,
+		"quantity":      3,
+		"saleDate":      time.Now(),
+		"salePrice":     10,
+		"unitCost":      2,
+		"customerName":  "Test",
+		"customerPhone": "555",
+		"locationId":    kitchen.ID,
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+}
+
+func TestReservations(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	send := func(method, url string, body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+	product := models.Product{
+		ProductName: fmt.Sprintf("Cake %d", time.Now().UnixNano()),
+		Unit:        "adet",
+	}
+	db.Create(&product)
+
+	lotURL := fmt.Sprintf("/api/v1/products/%d/lots", product.ID)
+	w := send("POST", lotURL, gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-CAKE", "invoiceDate": time.Now().AddDate(0, 0, -1), "initialStock": 10, "unitPrice": 5})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	reserve := func(quantity float64) (*httptest.ResponseRecorder, models.Reservation) {
+		w := send("POST", "/api/v1/reservations", gin.H{
+			"productId":    product.ID,
+			"quantity":     quantity,
+			"customerName": "Catering",
+			"expiresAt":    time.Now().Add(48 * time.Hour),
+		})
+		var created struct {
+			Data models.Reservation `json:"data"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &created)
+		return w, created.Data
+	}
+	sale := func(quantity float64, reservationID *uint) *httptest.ResponseRecorder {
+		return send("POST", "/api/v1/sales", gin.H{
+			"productId":     product.ID,
+			"quantity":      quantity,
+			"saleDate":      time.Now(),
+			"salePrice":     20,
+			"unitCost":      5,
+			"customerName":  "Test",
+			"customerPhone": "555",
+			"reservationId": reservationID,
+		})
+	}
+
+	w, catering := reserve(7)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, models.ReservationActive, catering.Status)
+
+	// Ayrılmış stok yeniden ayrılamaz ve peşin satışa açık değildir
+	w, _ = reserve(4)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sale(4, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = sale(3, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var price struct {
+		Data struct {
+			TotalStock     float64 `json:"totalStock"`
+			ReservedStock  float64 `json:"reservedStock"`
+			AvailableStock float64 `json:"availableStock"`
+		} `json:"data"`
+	}
+	req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/products/average-price?productId=%d", product.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &price))
+	assert.Equal(t, 7.0, price.Data.TotalStock)
+	assert.Equal(t, 7.0, price.Data.ReservedStock)
+	assert.Equal(t, 0.0, price.Data.AvailableStock)
+
+	// Rezervasyon satışa dönüştürülünce ayrılan stok satılır
+	w = sale(7, &catering.ID)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = sale(1, &catering.ID)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	var converted struct {
+		Data models.Reservation `json:"data"`
+	}
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/reservations/%d", catering.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &converted))
+	assert.Equal(t, models.ReservationConverted, converted.Data.Status)
+	assert.NotNil(t, converted.Data.SaleID)
+
+	w = send("POST", lotURL, gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-CAKE-2", "invoiceDate": time.Now(), "initialStock": 5, "unitPrice": 5})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// Serbest bırakılan rezervasyon bir daha bırakılamaz
+	w, pickup := reserve(2)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	releaseURL := fmt.Sprintf("/api/v1/reservations/%d/release", pickup.ID)
+	w = send("POST", releaseURL, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = send("POST", releaseURL, nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Süresi dolan rezervasyon stok tutmaz ve listelemede düşmüş görünür
+	w, stale := reserve(5)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	db.Model(&models.Reservation{}).Where("id = ?", stale.ID).Update("expires_at", time.Now().Add(-time.Minute))
+	w = sale(5, nil)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var reservations struct {
+		Data []models.Reservation `json:"data"`
+	}
+	req, _ = http.NewRequest("GET", fmt.Sprintf("/api/v1/reservations?productId=%d", product.ID), nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &reservations))
+	statuses := make(map[uint]string)
+	for _, reservation := range reservations.Data {
+		statuses[reservation.ID] = reservation.Status
+	}
+	assert.Equal(t, models.ReservationReleased, statuses[pickup.ID])
+	assert.Equal(t, models.ReservationExpired, statuses[stale.ID])
+}
+
+func TestConcurrentSalesNeverOversell(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+	product := models.Product{
+		ProductName: fmt.Sprintf("Sandwich %d", time.Now().UnixNano()),
+		Unit:        "adet",
+	}
+	db.Create(&product)
+
+	jsonValue, _ := json.Marshal(gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-LUNCH", "invoiceDate": time.Now(), "initialStock": 10, "unitPrice": 3})
+	req, _ := http.NewRequest("POST", fmt.Sprintf("/api/v1/products/%d/lots", product.ID), bytes.NewBuffer(jsonValue))
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// 10 adetlik tek partiye aynı anda 25 kasadan birer adet satış
+	const terminals = 25
+	codes := make([]int, terminals)
+	var wg sync.WaitGroup
+	for i := 0; i < terminals; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			jsonValue, _ := json.Marshal(gin.H{
+				"productId":     product.ID,
+				"quantity":      1,
+				"saleDate":      time.Now(),
+				"salePrice":     8,
+				"unitCost":      3,
+				"customerName":  fmt.Sprintf("Kasa %d", i),
+				"customerPhone": "555",
+			})
+			// Veritabanı meşgulse (503) kasa, satış ya da stok yetersizliği yanıtı alana
+			// kadar tekrar dener
+			for attempt := 0; attempt < 100; attempt++ {
+				req, _ := http.NewRequest("POST", "/api/v1/sales", bytes.NewBuffer(jsonValue))
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+				codes[i] = w.Code
+				if w.Code != http.StatusServiceUnavailable {
+					break
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	sold := 0
+	for _, code := range codes {
+		if code == http.StatusCreated {
+			sold++
+			continue
+		}
+		assert.Equal(t, http.StatusBadRequest, code)
+	}
+	assert.Equal(t, 10, sold)
+
+	var movement models.StockMovement
+	assert.NoError(t, db.Where("product_id = ?", product.ID).First(&movement).Error)
+	assert.Equal(t, 0.0, movement.RemainingQuantity)
+
+	var used float64
+	db.Model(&models.StockUsage{}).
+		Select("COALESCE(SUM(used_quantity), 0)").
+		Where("stock_movement_id = ?", movement.ID).
+		Scan(&used)
+	assert.Equal(t, 10.0, used)
+
+	var updated models.Product
+	db.First(&updated, product.ID)
+	assert.Equal(t, 0.0, updated.CurrentStock)
+}
+
+func TestInventoryAsOf(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	send := func(method, url string, body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now()
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+	product := models.Product{
+		ProductName: fmt.Sprintf("Sugar %d", now.UnixNano()),
+		Unit:        "kg",
+	}
+	db.Create(&product)
+
+	// 10 gün önce 10 kg x 2, 3 gün önce 5 kg x 4
+	lotURL := fmt.Sprintf("/api/v1/products/%d/lots", product.ID)
+	w := send("POST", lotURL, gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-1", "invoiceDate": now.AddDate(0, 0, -10), "initialStock": 10, "unitPrice": 2})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", lotURL, gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-2", "invoiceDate": now.AddDate(0, 0, -3), "initialStock": 5, "unitPrice": 4})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	// 5 gün önce 4 kg satış, 2 gün önce 1 kg fire; ikisi de eski partiden düşer
+	w = send("POST", "/api/v1/sales", gin.H{
+		"productId":     product.ID,
+		"quantity":      4,
+		"saleDate":      now.AddDate(0, 0, -5),
+		"salePrice":     5,
+		"unitCost":      2,
+		"customerName":  "Test",
+		"customerPhone": "555",
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/stock-adjustments", gin.H{
+		"productId":      product.ID,
+		"reason":         models.AdjustmentWaste,
+		"quantity":       -1,
+		"note":           "Nem aldı",
+		"adjustmentDate": now.AddDate(0, 0, -2),
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	type asOf struct {
+		Data struct {
+			Products []struct {
+				ProductID uint    `json:"productId"`
+				Quantity  float64 `json:"quantity"`
+				Value     float64 `json:"value"`
+				Lots      []struct {
+					Quantity float64 `json:"quantity"`
+					UnitCost float64 `json:"unitCost"`
+				} `json:"lots"`
+			} `json:"products"`
+			TotalValue float64 `json:"totalValue"`
+		} `json:"data"`
+	}
+	get := func(date string) asOf {
+		req, _ := http.NewRequest("GET", fmt.Sprintf("/api/v1/inventory/as-of?productId=%d&date=%s", product.ID, date), nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var result asOf
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+		return result
+	}
+
+	// İlk alıştan önce stok yoktur
+	assert.Empty(t, get(now.AddDate(0, 0, -11).Format("2006-01-02")).Data.Products)
+
+	// Satıştan önce yalnızca ilk parti
+	result := get(now.AddDate(0, 0, -6).Format("2006-01-02"))
+	if assert.Len(t, result.Data.Products, 1) {
+		assert.Equal(t, 10.0, result.Data.Products[0].Quantity)
+		assert.Equal(t, 20.0, result.Data.Products[0].Value)
+	}
+
+	// Satıştan ve ikinci alıştan sonra, fireden önce
+	result = get(now.AddDate(0, 0, -3).Add(time.Minute).Format(time.RFC3339))
+	if assert.Len(t, result.Data.Products, 1) {
+		assert.Equal(t, 11.0, result.Data.Products[0].Quantity)
+		assert.Equal(t, 32.0, result.Data.Products[0].Value)
+		assert.Len(t, result.Data.Products[0].Lots, 2)
+	}
+
+	// Bugün kalan miktarlarla aynıdır
+	result = get(now.Format("2006-01-02"))
+	if assert.Len(t, result.Data.Products, 1) {
+		assert.Equal(t, 10.0, result.Data.Products[0].Quantity)
+		assert.Equal(t, 30.0, result.Data.Products[0].Value)
+		assert.Equal(t, 30.0, result.Data.TotalValue)
+	}
+
+	// Parti fiyatının düzeltilmesi geçmiş değerleri değiştirmez
+	var firstLot models.PurchaseLot
+	assert.NoError(t, db.Where("product_id = ? AND invoice_no = ?", product.ID, "INV-1").First(&firstLot).Error)
+	w = send("PATCH", fmt.Sprintf("/api/v1/lots/%d", firstLot.ID), gin.H{"unitPrice": 3})
+	assert.Equal(t, http.StatusOK, w.Code)
+	result = get(now.AddDate(0, 0, -3).Add(time.Minute).Format(time.RFC3339))
+	if assert.Len(t, result.Data.Products, 1) {
+		assert.Equal(t, 32.0, result.Data.Products[0].Value)
+		assert.Equal(t, 2.0, result.Data.Products[0].Lots[0].UnitCost)
+	}
+
+	// Kullanılmış partinin fatura tarihi değiştirilemez; kullanılmamış partinin girişi
+	// yeni tarihe taşınır
+	w = send("PATCH", fmt.Sprintf("/api/v1/lots/%d", firstLot.ID), gin.H{"invoiceDate": now.AddDate(0, 0, -9)})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	var secondLot models.PurchaseLot
+	assert.NoError(t, db.Where("product_id = ? AND invoice_no = ?", product.ID, "INV-2").First(&secondLot).Error)
+	result = get(now.AddDate(0, 0, -4).Add(time.Minute).Format(time.RFC3339))
+	if assert.Len(t, result.Data.Products, 1) {
+		assert.Equal(t, 6.0, result.Data.Products[0].Quantity)
+	}
+	w = send("PATCH", fmt.Sprintf("/api/v1/lots/%d", secondLot.ID), gin.H{"invoiceDate": now.AddDate(0, 0, -4)})
+	assert.Equal(t, http.StatusOK, w.Code)
+	result = get(now.AddDate(0, 0, -4).Add(time.Minute).Format(time.RFC3339))
+	if assert.Len(t, result.Data.Products, 1) {
+		assert.Equal(t, 11.0, result.Data.Products[0].Quantity)
+		assert.Equal(t, 32.0, result.Data.Products[0].Value)
+	}
+
+	// Partinin arşivlenmesi ve satışın silinmesi geçmiş bakiyeleri değiştirmez
+	w = send("DELETE", fmt.Sprintf("/api/v1/lots/%d", secondLot.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var sale models.Sale
+	assert.NoError(t, db.Where("product_id = ?", product.ID).First(&sale).Error)
+	w = send("DELETE", fmt.Sprintf("/api/v1/sales/%d", sale.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	result = get(now.AddDate(0, 0, -3).Add(time.Minute).Format(time.RFC3339))
+	if assert.Len(t, result.Data.Products, 1) {
+		assert.Equal(t, 11.0, result.Data.Products[0].Quantity)
+		assert.Equal(t, 32.0, result.Data.Products[0].Value)
+		assert.Len(t, result.Data.Products[0].Lots, 2)
+	}
+
+	// Silinen satışın miktarı silindiği gün partiye döner ve partinin düzeltilen
+	// maliyetine yeniden değerlenir; arşivlenen parti düşer
+	result = get(now.AddDate(0, 0, 1).Format("2006-01-02"))
+	if assert.Len(t, result.Data.Products, 1) {
+		assert.Equal(t, 9.0, result.Data.Products[0].Quantity)
+		assert.InDelta(t, 27, result.Data.Products[0].Value, 1e-9)
+	}
+
+	req, _ := http.NewRequest("GET", "/api/v1/inventory/as-of?date=31-01-2026", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestInventoryJournal(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	send := func(method, url string, body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now()
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+	product := models.Product{
+		ProductName: fmt.Sprintf("Rice %d", now.UnixNano()),
+		Unit:        "kg",
+	}
+	db.Create(&product)
+
+	// 10 kg giriş, 4 kg satış, 1 kg fire, 2 kg sayım fazlası; satış sonra silinir
+	w := send("POST", fmt.Sprintf("/api/v1/products/%d/lots", product.ID), gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-1", "invoiceDate": now.AddDate(0, 0, -3), "initialStock": 10, "unitPrice": 2})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/sales", gin.H{
+		"productId":     product.ID,
+		"quantity":      4,
+		"saleDate":      now.AddDate(0, 0, -2),
+		"salePrice":     5,
+		"unitCost":      2,
+		"customerName":  "Test",
+		"customerPhone": "555",
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var sale models.Sale
+	assert.NoError(t, db.Where("product_id = ?", product.ID).First(&sale).Error)
+	w = send("POST", "/api/v1/stock-adjustments", gin.H{"productId": product.ID, "reason": models.AdjustmentWaste, "quantity": -1, "note": "Döküldü", "adjustmentDate": now.AddDate(0, 0, -1)})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/stock-adjustments", gin.H{"productId": product.ID, "reason": models.AdjustmentCountCorrection, "quantity": 2, "unitCost": 3, "note": "Sayım fazlası", "adjustmentDate": now})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("DELETE", fmt.Sprintf("/api/v1/sales/%d", sale.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = send("GET", fmt.Sprintf("/api/v1/inventory/journal?productId=%d", product.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var journal struct {
+		Data []models.InventoryEntry `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &journal))
+
+	var types []string
+	var total float64
+	for _, entry := range journal.Data {
+		types = append(types, entry.Type)
+		total += entry.Quantity
+	}
+	assert.Equal(t, []string{models.EntryReceipt, models.EntrySale, models.EntryAdjustment, models.EntryAdjustment, models.EntryReturn}, types)
+	assert.Equal(t, -8.0, journal.Data[1].Value)
+	assert.InDelta(t, 11, total, 1e-9)
+
+	var stored models.Product
+	db.First(&stored, product.ID)
+	assert.InDelta(t, 11, stored.CurrentStock, 1e-9)
+
+	// Kayıtlar değiştirilemez
+	assert.Error(t, db.Exec("UPDATE inventory_entries SET quantity = 0 WHERE id = ?", journal.Data[0].ID).Error)
+	assert.Error(t, db.Exec("DELETE FROM inventory_entries WHERE id = ?", journal.Data[0].ID).Error)
+
+	// Bozulan önbellekler defterden yeniden hesaplanır
+	db.Model(&models.StockMovement{}).Where("id = ?", journal.Data[0].StockMovementID).Update("remaining_quantity", 0)
+	db.Model(&stored).Update("current_stock", 100)
+	_, err = inventory.Rebuild(db)
+	assert.NoError(t, err)
+
+	var movement models.StockMovement
+	db.First(&movement, journal.Data[0].StockMovementID)
+	assert.InDelta(t, 9, movement.RemainingQuantity, 1e-9)
+	db.First(&stored, product.ID)
+	assert.InDelta(t, 11, stored.CurrentStock, 1e-9)
+}
+
+func TestRecipeProduction(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	send := func(method, url string, body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now()
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+	flour := models.Product{ProductName: fmt.Sprintf("Flour %d", now.UnixNano()), Unit: "g"}
+	db.Create(&flour)
+	w := send("POST", fmt.Sprintf("/api/v1/products/%d/lots", flour.ID), gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-1", "invoiceDate": now.AddDate(0, 0, -1), "initialStock": 1000, "unitPrice": 0.01})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	bread := models.Recipe{
+		Name:           fmt.Sprintf("Bread %d", now.UnixNano()),
+		OutputQuantity: 1,
+		RecipeItems:    []models.RecipeItem{{ProductID: flour.ID, Quantity: 300}},
+	}
+	db.Create(&bread)
+	produceURL := fmt.Sprintf("/api/v1/recipes/%d/produce", bread.ID)
+
+	// 2 ekmek için 600 g un düşülür ve deftere üretim olarak işlenir
+	w = send("POST", produceURL, gin.H{"quantity": 2, "date": now, "note": "Sabah"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var produced struct {
+		Data models.Production `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &produced))
+	assert.InDelta(t, 6, produced.Data.CostOfGoods, 1e-9)
+	assert.Len(t, produced.Data.Usages, 1)
+
+	var stored models.Product
+	db.First(&stored, flour.ID)
+	assert.InDelta(t, 400, stored.CurrentStock, 1e-9)
+
+	var entries []models.InventoryEntry
+	db.Where("production_id = ?", produced.Data.ID).Find(&entries)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, models.EntryProduction, entries[0].Type)
+		assert.InDelta(t, -600, entries[0].Quantity, 1e-9)
+	}
+
+	// Stok yetmezse üretim kaydedilmez
+	w = send("POST", produceURL, gin.H{"quantity": 2, "date": now})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	db.First(&stored, flour.ID)
+	assert.InDelta(t, 400, stored.CurrentStock, 1e-9)
+
+	// Üretimde kullanılmış parti silinemez
+	var lot models.PurchaseLot
+	assert.NoError(t, db.Where("product_id = ?", flour.ID).First(&lot).Error)
+	w = send("DELETE", fmt.Sprintf("/api/v1/lots/%d", lot.ID), nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = send("POST", "/api/v1/recipes/999999/produce", gin.H{"quantity": 1, "date": now})
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestSalesOrders(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	// Satış listesi yanıtını sunucudaki gibi middleware yazar
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(middleware.ResponseMiddleware())
+	api.SetupRouter(router.Group("/api/v1"), db)
+
+	send := func(method, url string, body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now()
+	category := models.Category{Name: fmt.Sprintf("Cafe %d", now.UnixNano())}
+	db.Create(&category)
+	db.Model(&category).Update("path", fmt.Sprintf("/%d/", category.ID))
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+	cake := models.Product{ProductName: fmt.Sprintf("Cake %d", now.UnixNano()), Unit: "piece", CategoryID: &category.ID}
+	db.Create(&cake)
+	beans := models.Product{ProductName: fmt.Sprintf("Beans %d", now.UnixNano()), Unit: "g"}
+	db.Create(&beans)
+	for _, lot := range []gin.H{
+		{"productId": cake.ID, "initialStock": 10, "unitPrice": 2},
+		{"productId": beans.ID, "initialStock": 100, "unitPrice": 0.5},
+	} {
+		lot["supplierId"] = supplier.ID
+		lot["invoiceNo"] = "INV-1"
+		lot["invoiceDate"] = now.AddDate(0, 0, -1)
+		w := send("POST", fmt.Sprintf("/api/v1/products/%d/lots", lot["productId"]), lot)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+	coffee := models.Recipe{
+		Name:           fmt.Sprintf("Espresso %d", now.UnixNano()),
+		OutputQuantity: 1,
+		CategoryID:     &category.ID,
+		RecipeItems:    []models.RecipeItem{{ProductID: beans.ID, Quantity: 8}},
+	}
+	db.Create(&coffee)
+
+	stock := func(productID uint) float64 {
+		var product models.Product
+		db.First(&product, productID)
+		return product.CurrentStock
+	}
+
+	type order struct {
+		Data struct {
+			ID          uint    `json:"id"`
+			Subtotal    float64 `json:"subtotal"`
+			NetPrice    float64 `json:"netPrice"`
+			VatAmount   float64 `json:"vatAmount"`
+			TotalPrice  float64 `json:"totalPrice"`
+			CostOfGoods float64 `json:"costOfGoods"`
+			Lines       []struct {
+				ID           uint    `json:"id"`
+				SalesOrderID uint    `json:"salesOrderId"`
+				CustomerName string  `json:"customerName"`
+				NetPrice     float64 `json:"netPrice"`
+				CostOfGoods  float64 `json:"costOfGoods"`
+				Product      struct {
+					ProductName string `json:"productName"`
+				} `json:"product"`
+			} `json:"lines"`
+		} `json:"data"`
+	}
+
+	// 3 kek (1 iskontolu) ve 2 espresso; sipariş iskontosu 2, KDV %10
+	w := send("POST", "/api/v1/sales-orders", gin.H{
+		"customerName":  "Test",
+		"customerPhone": "555",
+		"orderDate":     now,
+		"discount":      2,
+		"vat":           10,
+		"lines": []gin.H{
+			{"productId": cake.ID, "quantity": 3, "salePrice": 5, "discount": 1},
+			{"recipeId": coffee.ID, "quantity": 2, "salePrice": 10},
+		},
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var created order
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Len(t, created.Data.Lines, 2)
+	assert.InDelta(t, 34, created.Data.Subtotal, 1e-9)
+	assert.InDelta(t, 32, created.Data.NetPrice, 1e-9)
+	assert.InDelta(t, 3.2, created.Data.VatAmount, 1e-9)
+	assert.InDelta(t, 35.2, created.Data.TotalPrice, 1e-9)
+	assert.InDelta(t, 14, created.Data.CostOfGoods, 1e-9)
+	assert.InDelta(t, 6, created.Data.Lines[0].CostOfGoods, 1e-9)
+	assert.InDelta(t, 8, created.Data.Lines[1].CostOfGoods, 1e-9)
+	assert.Equal(t, "Test", created.Data.Lines[1].CustomerName)
+	assert.Equal(t, "Reçete: "+coffee.Name, created.Data.Lines[1].Product.ProductName)
+	assert.InDelta(t, 7, stock(cake.ID), 1e-9)
+	assert.InDelta(t, 84, stock(beans.ID), 1e-9)
+
+	// Bir satırın stoğu yetmezse sipariş hiç kaydedilmez
+	w = send("POST", "/api/v1/sales-orders", gin.H{
+		"customerName":  "Test",
+		"customerPhone": "555",
+		"orderDate":     now,
+		"lines": []gin.H{
+			{"productId": cake.ID, "quantity": 2, "salePrice": 5},
+			{"recipeId": coffee.ID, "quantity": 20, "salePrice": 10},
+		},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.InDelta(t, 7, stock(cake.ID), 1e-9)
+
+	// Satır ya ürün ya reçete içerir
+	w = send("POST", "/api/v1/sales-orders", gin.H{
+		"customerName":  "Test",
+		"customerPhone": "555",
+		"orderDate":     now,
+		"lines":         []gin.H{{"productId": cake.ID, "recipeId": coffee.ID, "quantity": 1, "salePrice": 5}},
+	})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Satış listesi siparişleri satırlarıyla gösterir
+	w = send("GET", "/api/v1/sales?groupBy=order", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var grouped struct {
+		Sales []struct {
+			SalesOrderID *uint `json:"salesOrderId"`
+		} `json:"sales"`
+		Orders []struct {
+			ID    uint `json:"id"`
+			Lines []struct {
+				ID uint `json:"id"`
+			} `json:"lines"`
+		} `json:"orders"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &grouped))
+	for _, sale := range grouped.Sales {
+		assert.Nil(t, sale.SalesOrderID)
+	}
+	found := false
+	for _, o := range grouped.Orders {
+		if o.ID == created.Data.ID {
+			found = true
+			assert.Len(t, o.Lines, 2)
+		}
+	}
+	assert.True(t, found)
+
+	// Kategori raporu sipariş iskontosunu satırlara dağıtır
+	w = send("GET", fmt.Sprintf("/api/v1/reports/categories?categoryId=%d", category.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var report struct {
+		Data struct {
+			Categories []struct {
+				Own struct {
+					SaleCount  int64   `json:"saleCount"`
+					NetSales   float64 `json:"netSales"`
+					VatAmount  float64 `json:"vatAmount"`
+					TotalSales float64 `json:"totalSales"`
+				} `json:"own"`
+			} `json:"categories"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	if assert.Len(t, report.Data.Categories, 1) {
+		assert.Equal(t, int64(2), report.Data.Categories[0].Own.SaleCount)
+		assert.InDelta(t, 32, report.Data.Categories[0].Own.NetSales, 1e-9)
+		assert.InDelta(t, 3.2, report.Data.Categories[0].Own.VatAmount, 1e-9)
+		assert.InDelta(t, 35.2, report.Data.Categories[0].Own.TotalSales, 1e-9)
+	}
+
+	// Tek başına satış siparişe bağlanamaz
+	w = send("POST", "/api/v1/sales", gin.H{
+		"productId":     cake.ID,
+		"quantity":      1,
+		"saleDate":      now,
+		"salePrice":     5,
+		"customerName":  "Other",
+		"customerPhone": "556",
+		"salesOrderId":  created.Data.ID,
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var standalone models.Sale
+	assert.NoError(t, db.Where("product_id = ? AND customer_phone = ?", cake.ID, "556").First(&standalone).Error)
+	assert.Nil(t, standalone.SalesOrderID)
+	w = send("DELETE", fmt.Sprintf("/api/v1/sales/%d", standalone.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Satır düzeltilince siparişin maliyeti satırlardan yeniden hesaplanır
+	w = send("PATCH", fmt.Sprintf("/api/v1/sales/%d", created.Data.Lines[0].ID), gin.H{"quantity": 4})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var stored models.SalesOrder
+	db.First(&stored, created.Data.ID)
+	assert.InDelta(t, 16, stored.CostOfGoods, 1e-9)
+	w = send("PATCH", fmt.Sprintf("/api/v1/sales/%d", created.Data.Lines[1].ID), gin.H{"quantity": 1})
+	assert.Equal(t, http.StatusOK, w.Code)
+	db.First(&stored, created.Data.ID)
+	assert.InDelta(t, 12, stored.CostOfGoods, 1e-9)
+
+	// Siparişin maliyeti ve brüt kârı yüklenen satırlardan hesaplanır
+	db.Model(&models.SalesOrder{}).Where("id = ?", created.Data.ID).Update("cost_of_goods", 0)
+	w = send("GET", fmt.Sprintf("/api/v1/sales-orders/%d", created.Data.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var fetched struct {
+		Data struct {
+			NetPrice    float64 `json:"netPrice"`
+			CostOfGoods float64 `json:"costOfGoods"`
+			GrossProfit float64 `json:"grossProfit"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &fetched))
+	assert.InDelta(t, 27, fetched.Data.NetPrice, 1e-9)
+	assert.InDelta(t, 12, fetched.Data.CostOfGoods, 1e-9)
+	assert.InDelta(t, 15, fetched.Data.GrossProfit, 1e-9)
+
+	// Satır iskontosu sipariş tutarını eksiye düşüremez
+	w = send("PATCH", fmt.Sprintf("/api/v1/sales/%d", created.Data.Lines[0].ID), gin.H{"discount": 30})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send("GET", fmt.Sprintf("/api/v1/sales-orders/%d", created.Data.ID), nil)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &fetched))
+	assert.InDelta(t, 27, fetched.Data.NetPrice, 1e-9)
+
+	// Sipariş satırı tek başına silinemez; sipariş silinince stok iade edilir
+	w = send("DELETE", fmt.Sprintf("/api/v1/sales/%d", created.Data.Lines[0].ID), nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = send("DELETE", fmt.Sprintf("/api/v1/sales-orders/%d", created.Data.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.InDelta(t, 10, stock(cake.ID), 1e-9)
+	assert.InDelta(t, 100, stock(beans.ID), 1e-9)
+	w = send("GET", fmt.Sprintf("/api/v1/sales-orders/%d", created.Data.ID), nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestCustomers(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	send := func(method, url string, body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now()
+	number := fmt.Sprintf("5%09d", now.UnixNano()%1000000000)
+	taxNumber := fmt.Sprintf("T%d", now.UnixNano())
+
+	type customerResponse struct {
+		Data struct {
+			ID        uint   `json:"id"`
+			Name      string `json:"name"`
+			Phone     string `json:"phone"`
+			Addresses []struct {
+				Address   string `json:"address"`
+				IsDefault bool   `json:"isDefault"`
+			} `json:"addresses"`
+		} `json:"data"`
+		CustomerID uint `json:"customerId"`
+	}
+
+	// Telefon ulusal biçimde saklanır; ilk adres varsayılan olur
+	w := send("POST", "/api/v1/customers", gin.H{
+		"name":      "Ayşe Yılmaz",
+		"phone":     fmt.Sprintf("+90 (%s) %s-%s", number[:3], number[3:6], number[6:]),
+		"taxNumber": taxNumber,
+		"addresses": []gin.H{{"label": "Ev", "address": "Moda Cad. 1", "city": "İstanbul"}, {"label": "İş", "address": "Büyükdere Cad. 5"}},
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var customer customerResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &customer))
+	assert.Equal(t, number, customer.Data.Phone)
+	assert.Len(t, customer.Data.Addresses, 2)
+	assert.True(t, customer.Data.Addresses[0].IsDefault)
+	id := customer.Data.ID
+
+	// Aynı telefon farklı yazımla ya da aynı vergi numarasıyla tekrar kaydedilemez
+	for _, duplicate := range []gin.H{
+		{"name": "Ayse Yilmaz", "phone": "0" + number},
+		{"name": "Ayşe Y.", "taxNumber": taxNumber},
+	} {
+		w = send("POST", "/api/v1/customers", duplicate)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		var conflict customerResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &conflict))
+		assert.Equal(t, id, conflict.CustomerID)
+	}
+
+	// Kontrolü atlayan eşzamanlı kayıtlar veritabanındaki tekil indekslere takılır
+	err = db.Create(&models.Customer{Name: "Ayşe", Phone: number}).Error
+	assert.True(t, database.IsUniqueViolation(err, "customers.phone"))
+	err = db.Create(&models.Customer{Name: "Ayşe", TaxNumber: taxNumber}).Error
+	assert.True(t, database.IsUniqueViolation(err, "customers.tax_number"))
+	phoneless := models.Customer{Name: "Telefonsuz"}
+	assert.NoError(t, db.Create(&phoneless).Error)
+	db.Delete(&phoneless)
+
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+	product := models.Product{ProductName: fmt.Sprintf("Tea %d", now.UnixNano()), Unit: "piece"}
+	db.Create(&product)
+	w = send("POST", fmt.Sprintf("/api/v1/products/%d/lots", product.ID), gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-1", "invoiceDate": now.AddDate(0, 0, -5), "initialStock": 20, "unitPrice": 1})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	recipe := models.Recipe{
+		Name:           fmt.Sprintf("Tea Pot %d", now.UnixNano()),
+		OutputQuantity: 1,
+		RecipeItems:    []models.RecipeItem{{ProductID: product.ID, Quantity: 2}},
+	}
+	db.Create(&recipe)
+
+	// Müşteri ID ile, farklı yazılmış telefonla, reçete satışında ve siparişte
+	sale := gin.H{"productId": product.ID, "quantity": 2, "saleDate": now.AddDate(0, 0, -3), "salePrice": 5, "unitCost": 1, "customerId": id}
+	w = send("POST", "/api/v1/sales", sale)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	sale = gin.H{"productId": product.ID, "quantity": 1, "saleDate": now.AddDate(0, 0, -2), "salePrice": 5, "unitCost": 1, "customerName": "ayse", "customerPhone": number[:3] + " " + number[3:]}
+	w = send("POST", "/api/v1/sales", sale)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/recipe-sales", gin.H{"recipeId": recipe.ID, "quantity": 1, "saleDate": now.AddDate(0, 0, -1), "salePrice": 10, "unitCost": 2, "customerId": id})
+	assert.Less(t, w.Code, 300)
+	w = send("POST", "/api/v1/sales-orders", gin.H{
+		"customerName":  "Ayşe",
+		"customerPhone": "0" + number,
+		"orderDate":     now,
+		"lines":         []gin.H{{"productId": product.ID, "quantity": 1, "salePrice": 5}},
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var sales []models.Sale
+	db.Where("product_id = ? OR recipe_id = ?", product.ID, recipe.ID).Find(&sales)
+	assert.Len(t, sales, 4)
+	for _, s := range sales {
+		assert.Equal(t, id, *s.CustomerID)
+		assert.Equal(t, "Ayşe Yılmaz", s.CustomerName)
+	}
+
+	// Bilinmeyen müşteriye satış yapılamaz
+	w = send("POST", "/api/v1/sales", gin.H{"productId": product.ID, "quantity": 1, "saleDate": now, "salePrice": 5, "unitCost": 1, "customerId": 999999})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send("GET", fmt.Sprintf("/api/v1/customers/%d/sales", id), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var history struct {
+		Data struct {
+			Sales  []models.Sale `json:"sales"`
+			Orders []struct {
+				Lines []models.Sale `json:"lines"`
+			} `json:"orders"`
+			Totals struct {
+				VisitCount    int       `json:"visitCount"`
+				LifetimeSpend float64   `json:"lifetimeSpend"`
+				LastPurchase  time.Time `json:"lastPurchase"`
+			} `json:"totals"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &history))
+	assert.Len(t, history.Data.Sales, 3)
+	assert.Len(t, history.Data.Orders, 1)
+	assert.Equal(t, 4, history.Data.Totals.VisitCount)
+	assert.InDelta(t, 30, history.Data.Totals.LifetimeSpend, 1e-9)
+	assert.WithinDuration(t, now, history.Data.Totals.LastPurchase, time.Second)
+
+	// Telefon parçasıyla arama
+	w = send("GET", "/api/v1/customers?q="+number[4:9], nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var list struct {
+		Data []models.Customer `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.Data, 1)
+
+	// Güncelleme adresleri değiştirir; satışı olan müşteri silinemez
+	w = send("PUT", fmt.Sprintf("/api/v1/customers/%d", id), gin.H{
+		"name":      "Ayşe Yılmaz",
+		"phone":     number,
+		"addresses": []gin.H{{"address": "Bağdat Cad. 9", "isDefault": true}},
+	})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var addresses int64
+	db.Model(&models.CustomerAddress{}).Where("customer_id = ?", id).Count(&addresses)
+	assert.Equal(t, int64(1), addresses)
+
+	w = send("DELETE", fmt.Sprintf("/api/v1/customers/%d", id), nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
+
+func TestSaleReturns(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	send := func(method, url string, body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now()
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+	category := models.Category{Name: fmt.Sprintf("Kitchen %d", now.UnixNano())}
+	db.Create(&category)
+	db.Model(&category).Update("path", fmt.Sprintf("/%d/", category.ID))
+	product := models.Product{ProductName: fmt.Sprintf("Mug %d", now.UnixNano()), Unit: "piece", CategoryID: &category.ID}
+	db.Create(&product)
+
+	// Eski parti 5 x 2, yeni parti 5 x 4; 8 adetlik satış eski partiyi bitirir, yeniden 3 alır
+	lotURL := fmt.Sprintf("/api/v1/products/%d/lots", product.ID)
+	w := send("POST", lotURL, gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-1", "invoiceDate": now.AddDate(0, 0, -3), "initialStock": 5, "unitPrice": 2})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", lotURL, gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-2", "invoiceDate": now.AddDate(0, 0, -2), "initialStock": 5, "unitPrice": 4})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", "/api/v1/sales", gin.H{
+		"productId":     product.ID,
+		"quantity":      8,
+		"saleDate":      now.AddDate(0, 0, -1),
+		"salePrice":     10,
+		"discount":      8,
+		"vat":           10,
+		"unitCost":      3,
+		"customerName":  "Test",
+		"customerPhone": "555",
+		// İade toplamları istemciden alınmaz
+		"returnedQuantity": 10,
+		"returnedCost":     5,
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var sale models.Sale
+	assert.NoError(t, db.Where("product_id = ?", product.ID).First(&sale).Error)
+	assert.Zero(t, sale.ReturnedQuantity)
+	assert.Zero(t, sale.ReturnedCost)
+
+	var movements []models.StockMovement
+	db.Where("product_id = ?", product.ID).Order("movement_date asc").Find(&movements)
+	lots := func() []float64 {
+		var quantities []float64
+		for _, m := range movements {
+			var current models.StockMovement
+			db.First(&current, m.ID)
+			quantities = append(quantities, current.RemainingQuantity)
+		}
+		return quantities
+	}
+	stock := func() float64 {
+		var current models.Product
+		db.First(&current, product.ID)
+		return current.CurrentStock
+	}
+
+	type returnResponse struct {
+		Data struct {
+			CreditNo    string  `json:"creditNo"`
+			NetAmount   float64 `json:"netAmount"`
+			VatAmount   float64 `json:"vatAmount"`
+			TotalAmount float64 `json:"totalAmount"`
+			CostOfGoods float64 `json:"costOfGoods"`
+			Lines       []struct {
+				StockMovementID   uint    `json:"stockMovementId"`
+				Quantity          float64 `json:"quantity"`
+				StockAdjustmentID *uint   `json:"stockAdjustmentId"`
+			} `json:"lines"`
+		} `json:"data"`
+	}
+	returnsURL := fmt.Sprintf("/api/v1/sales/%d/returns", sale.ID)
+
+	// 2 adet iade en son tüketilen (yeni) partiye döner; tutarlar satışın oranıdır
+	w = send("POST", returnsURL, gin.H{"quantity": 2, "reason": "Vazgeçti"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var first returnResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &first))
+	assert.Contains(t, first.Data.CreditNo, "IADE-")
+	assert.InDelta(t, 18, first.Data.NetAmount, 1e-9)
+	assert.InDelta(t, 1.8, first.Data.VatAmount, 1e-9)
+	assert.InDelta(t, 19.8, first.Data.TotalAmount, 1e-9)
+	assert.InDelta(t, 8, first.Data.CostOfGoods, 1e-9)
+	assert.Equal(t, []float64{0, 4}, lots())
+	assert.InDelta(t, 4, stock(), 1e-9)
+
+	// Kâr iadeden sonra kalan 6 adetin net tutarı (54) ile kalan maliyetinden (14) hesaplanır
+	var partlyReturned models.Sale
+	assert.NoError(t, db.First(&partlyReturned, sale.ID).Error)
+	assert.InDelta(t, 22, partlyReturned.CostOfGoods, 1e-9)
+	assert.InDelta(t, 8, partlyReturned.ReturnedCost, 1e-9)
+	assert.InDelta(t, 40, partlyReturned.GrossProfit, 1e-9)
+	assert.InDelta(t, 40.0/54*100, partlyReturned.MarginPercent, 1e-9)
+
+	// Kategori raporu da iade edilen kısmı satış ve maliyetten düşer
+	w = send("GET", fmt.Sprintf("/api/v1/reports/categories?categoryId=%d", category.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var report struct {
+		Data struct {
+			Categories []struct {
+				Own struct {
+					NetSales    float64 `json:"netSales"`
+					VatAmount   float64 `json:"vatAmount"`
+					CostOfGoods float64 `json:"costOfGoods"`
+				} `json:"own"`
+			} `json:"categories"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	if assert.Len(t, report.Data.Categories, 1) {
+		assert.InDelta(t, 54, report.Data.Categories[0].Own.NetSales, 1e-9)
+		assert.InDelta(t, 5.4, report.Data.Categories[0].Own.VatAmount, 1e-9)
+		assert.InDelta(t, 14, report.Data.Categories[0].Own.CostOfGoods, 1e-9)
+	}
+
+	// Hasarlı 2 adet fireye gider: stok değişmez, fire kaydı oluşur
+	w = send("POST", returnsURL, gin.H{"quantity": 2, "disposition": models.ReturnWaste, "reason": "Kırık"})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var second returnResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &second))
+	assert.InDelta(t, 6, second.Data.CostOfGoods, 1e-9)
+	assert.Len(t, second.Data.Lines, 2)
+	assert.Equal(t, []float64{0, 4}, lots())
+	assert.InDelta(t, 4, stock(), 1e-9)
+
+	var waste models.StockAdjustment
+	assert.NoError(t, db.First(&waste, *second.Data.Lines[0].StockAdjustmentID).Error)
+	assert.Equal(t, models.AdjustmentWaste, waste.Reason)
+	assert.InDelta(t, -6, waste.TotalCost, 1e-9)
+
+	// Toplam iade satılan miktarı aşamaz
+	w = send("POST", returnsURL, gin.H{"quantity": 5})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	// Kalan 4 adet eski partiye döner
+	w = send("POST", returnsURL, gin.H{"quantity": 4})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, []float64{4, 4}, lots())
+	assert.InDelta(t, 8, stock(), 1e-9)
+
+	w = send("GET", returnsURL, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Data struct {
+			Returns    []models.SaleReturn `json:"returns"`
+			Returnable float64             `json:"returnable"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.Len(t, listed.Data.Returns, 3)
+	assert.InDelta(t, 0, listed.Data.Returnable, 1e-9)
+
+	// İadeden sonra partinin miktarı düzeltilirse iade edilen miktar kalanda korunur
+	var newLot models.PurchaseLot
+	assert.NoError(t, db.Where("product_id = ? AND invoice_no = ?", product.ID, "INV-2").First(&newLot).Error)
+	w = send("PATCH", fmt.Sprintf("/api/v1/lots/%d", newLot.ID), gin.H{"initialStock": 6})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []float64{4, 5}, lots())
+	assert.InDelta(t, 9, stock(), 1e-9)
+
+	// Defter partilerin kalanıyla uyumludur
+	for _, m := range movements {
+		var journal float64
+		db.Model(&models.InventoryEntry{}).Where("stock_movement_id = ?", m.ID).Select("COALESCE(SUM(quantity), 0)").Scan(&journal)
+		var current models.StockMovement
+		db.First(&current, m.ID)
+		assert.InDelta(t, current.RemainingQuantity, journal, 1e-9)
+	}
+
+	// İadesi olan satış silinemez
+	w = send("DELETE", fmt.Sprintf("/api/v1/sales/%d", sale.ID), nil)
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	// Sipariş satırının iadesinden sipariş iskontosunun payı düşülür: 2 x 10, iskonto 4, KDV %10
+	w = send("POST", "/api/v1/sales-orders", gin.H{
+		"customerName":  "Test",
+		"customerPhone": "555",
+		"orderDate":     now,
+		"discount":      4,
+		"vat":           10,
+		"lines":         []gin.H{{"productId": product.ID, "quantity": 2, "salePrice": 10}},
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var order struct {
+		Data models.SalesOrder `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	assert.InDelta(t, 17.6, order.Data.TotalPrice, 1e-9)
+	w = send("POST", fmt.Sprintf("/api/v1/sales/%d/returns", order.Data.Lines[0].ID), gin.H{"quantity": 1})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var orderReturn returnResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &orderReturn))
+	assert.InDelta(t, 8, orderReturn.Data.NetAmount, 1e-9)
+	assert.InDelta(t, 0.8, orderReturn.Data.VatAmount, 1e-9)
+	assert.InDelta(t, 8.8, orderReturn.Data.TotalAmount, 1e-9)
+
+	// Siparişin kârı kalan satırın iskontolu net tutarından (8) kalan maliyeti (2) düşer
+	w = send("GET", fmt.Sprintf("/api/v1/sales-orders/%d", order.Data.ID), nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	assert.InDelta(t, 6, order.Data.GrossProfit, 1e-9)
+	assert.InDelta(t, 75, order.Data.MarginPercent, 1e-9)
+}
+
+func TestUpdateSale(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	send := func(method, url string, body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now()
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+	product := models.Product{ProductName: fmt.Sprintf("Plate %d", now.UnixNano()), Unit: "piece"}
+	db.Create(&product)
+
+	// Eski parti 5 x 2, yeni parti 5 x 4; 4 adetlik satış eski partiden karşılanır
+	lotURL := fmt.Sprintf("/api/v1/products/%d/lots", product.ID)
+	w := send("POST", lotURL, gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-1", "invoiceDate": now.AddDate(0, 0, -3), "initialStock": 5, "unitPrice": 2})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("POST", lotURL, gin.H{"supplierId": supplier.ID, "invoiceNo": "INV-2", "invoiceDate": now.AddDate(0, 0, -2), "initialStock": 5, "unitPrice": 4})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	saleDate := now.AddDate(0, 0, -1).UTC().Truncate(time.Second)
+	w = send("POST", "/api/v1/sales", gin.H{
+		"productId":     product.ID,
+		"quantity":      4,
+		"saleDate":      saleDate,
+		"salePrice":     10,
+		"unitCost":      2,
+		"customerName":  "Test",
+		"customerPhone": "555 000 11 22",
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var sale models.Sale
+	assert.NoError(t, db.Where("product_id = ?", product.ID).First(&sale).Error)
+	createdAt := sale.CreatedAt
+
+	var movements []models.StockMovement
+	db.Where("product_id = ?", product.ID).Order("movement_date asc").Find(&movements)
+	lots := func() []float64 {
+		var quantities []float64
+		for _, m := range movements {
+			var current models.StockMovement
+			db.First(&current, m.ID)
+			quantities = append(quantities, current.RemainingQuantity)
+		}
+		return quantities
+	}
+
+	type updateResponse struct {
+		Data struct {
+			Sale     models.Sale          `json:"sale"`
+			Revision *models.SaleRevision `json:"revision"`
+		} `json:"data"`
+	}
+	saleURL := fmt.Sprintf("/api/v1/sales/%d", sale.ID)
+
+	// Miktar 7'ye çıkar: eksik 3 adetin 1'i eski, 2'si yeni partiden düşülür
+	w = send("PATCH", saleURL, gin.H{"quantity": 7, "discount": 5, "revisionNote": "Yanlış miktar"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var increased updateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &increased))
+	assert.InDelta(t, 65, increased.Data.Sale.NetPrice, 1e-9)
+	assert.InDelta(t, 5*2+2*4, increased.Data.Sale.CostOfGoods, 1e-9)
+	assert.Equal(t, 1, increased.Data.Revision.Number)
+	assert.Equal(t, []float64{0, 3}, lots())
+
+	// Miktar 3'e iner: fazla 4 adet en son tüketilen (yeni) partiden başlayarak geri döner
+	w = send("PATCH", saleURL, gin.H{"quantity": 3, "vat": 20})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var decreased updateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &decreased))
+	assert.InDelta(t, 6, decreased.Data.Sale.CostOfGoods, 1e-9)
+	assert.InDelta(t, 25, decreased.Data.Sale.NetPrice, 1e-9)
+	assert.InDelta(t, 5, decreased.Data.Sale.VatAmount, 1e-9)
+	assert.Equal(t, 2, decreased.Data.Revision.Number)
+	assert.Equal(t, []float64{2, 5}, lots())
+
+	var current models.Product
+	db.First(&current, product.ID)
+	assert.InDelta(t, 7, current.CurrentStock, 1e-9)
+
+	// Telefonla yeni müşteri oluşturulur; tarih düzeltilir, oluşturma zamanı korunur
+	newDate := saleDate.Add(-2 * time.Hour)
+	w = send("PATCH", saleURL, gin.H{"customerName": "Yeni", "customerPhone": "555 999 88 77", "saleDate": newDate})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var moved updateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &moved))
+	assert.NotNil(t, moved.Data.Sale.CustomerID)
+	assert.Equal(t, "Yeni", moved.Data.Sale.CustomerName)
+	assert.True(t, newDate.Equal(moved.Data.Sale.SaleDate))
+	assert.True(t, createdAt.Equal(moved.Data.Sale.CreatedAt))
+
+	// Değişiklik yoksa revizyon oluşmaz
+	w = send("PATCH", saleURL, gin.H{"quantity": 3})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var unchanged updateResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &unchanged))
+	assert.Nil(t, unchanged.Data.Revision)
+
+	// Stoktan fazlası istenemez
+	w = send("PATCH", saleURL, gin.H{"quantity": 20})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, []float64{2, 5}, lots())
+
+	w = send("GET", saleURL+"/revisions", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var listed struct {
+		Data struct {
+			Revisions []models.SaleRevision `json:"revisions"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+	assert.Len(t, listed.Data.Revisions, 3)
+	assert.Equal(t, "Yanlış miktar", listed.Data.Revisions[0].Note)
+	fields := make(map[string]models.SaleRevisionChange)
+	for _, change := range listed.Data.Revisions[0].Changes {
+		fields[change.Field] = change
+	}
+	assert.Equal(t, "4", fields["quantity"].OldValue)
+	assert.Equal(t, "7", fields["quantity"].NewValue)
+	assert.Equal(t, "5", fields["discount"].NewValue)
+	assert.Contains(t, fields, "costOfGoods")
+
+	// Düzeltmeler satış tarihine değil düzeltme anına işlenir
+	var backdated int64
+	db.Model(&models.InventoryEntry{}).
+		Where("sale_id = ? AND type = ? AND occurred_at < ?", sale.ID, models.EntryReturn, now.Add(-time.Minute)).
+		Count(&backdated)
+	assert.Zero(t, backdated)
+	var corrections int64
+	db.Model(&models.InventoryEntry{}).Where("sale_id = ? AND occurred_at >= ?", sale.ID, now.Add(-time.Minute)).Count(&corrections)
+	assert.Equal(t, int64(4), corrections)
+
+	// Defter partilerin kalanıyla uyumludur
+	for _, m := range movements {
+		var journal float64
+		db.Model(&models.InventoryEntry{}).Where("stock_movement_id = ?", m.ID).Select("COALESCE(SUM(quantity), 0)").Scan(&journal)
+		var lot models.StockMovement
+		db.First(&lot, m.ID)
+		assert.InDelta(t, lot.RemainingQuantity, journal, 1e-9)
+	}
+
+	// İadesi olan satışın miktarı değiştirilemez, fiyatı düzeltilebilir
+	w = send("POST", saleURL+"/returns", gin.H{"quantity": 1})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	w = send("PATCH", saleURL, gin.H{"quantity": 2})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	w = send("PATCH", saleURL, gin.H{"salePrice": 12})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestUpdateSaleSpecificLot(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	send := func(method, url string, body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now()
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+	product := models.Product{ProductName: fmt.Sprintf("Ring %d", now.UnixNano()), Unit: "piece", CostingMethod: models.CostingSpecific}
+	db.Create(&product)
+
+	// Üç parti: 5 x 2, 5 x 4, 5 x 8
+	lotURL := fmt.Sprintf("/api/v1/products/%d/lots", product.ID)
+	for i, price := range []float64{2, 4, 8} {
+		w := send("POST", lotURL, gin.H{"supplierId": supplier.ID, "invoiceNo": fmt.Sprintf("INV-%d", i), "invoiceDate": now.AddDate(0, 0, -3+i), "initialStock": 5, "unitPrice": price})
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+	var lots []models.PurchaseLot
+	db.Where("product_id = ?", product.ID).Order("id asc").Find(&lots)
+	assert.Len(t, lots, 3)
+	remaining := func() []float64 {
+		var quantities []float64
+		for _, lot := range lots {
+			var movement models.StockMovement
+			db.Where("purchase_lot_id = ?", lot.ID).First(&movement)
+			quantities = append(quantities, movement.RemainingQuantity)
+		}
+		return quantities
+	}
+
+	w := send("POST", "/api/v1/sales", gin.H{
+		"productId":     product.ID,
+		"lotId":         lots[1].ID,
+		"quantity":      2,
+		"saleDate":      now,
+		"salePrice":     10,
+		"customerName":  "Test",
+		"customerPhone": "555 000 11 22",
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var sale models.Sale
+	assert.NoError(t, db.Where("product_id = ?", product.ID).First(&sale).Error)
+	assert.Equal(t, lots[1].ID, *sale.LotID)
+	saleURL := fmt.Sprintf("/api/v1/sales/%d", sale.ID)
+
+	// Artış satışın partisinden karşılanır
+	w = send("PATCH", saleURL, gin.H{"quantity": 4})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []float64{5, 1, 5}, remaining())
+	db.First(&sale, sale.ID)
+	assert.InDelta(t, 16, sale.CostOfGoods, 1e-9)
+
+	// Parti saklanmamış eski satışlarda son tüketilen parti kullanılır
+	db.Model(&models.Sale{}).Where("id = ?", sale.ID).Update("lot_id", nil)
+	w = send("PATCH", saleURL, gin.H{"quantity": 5})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []float64{5, 0, 5}, remaining())
+
+	// Miktar artmadan parti değiştirilemez; kullanımlar eski partide kalırdı
+	w = send("PATCH", saleURL, gin.H{"lotId": lots[2].ID})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send("PATCH", saleURL, gin.H{"quantity": 4, "lotId": lots[2].ID})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, []float64{5, 0, 5}, remaining())
+
+	// Düzeltmede seçilen parti sonraki artışlarda kullanılır
+	w = send("PATCH", saleURL, gin.H{"quantity": 7, "lotId": lots[2].ID})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, []float64{5, 0, 3}, remaining())
+	db.First(&sale, sale.ID)
+	assert.Equal(t, lots[2].ID, *sale.LotID)
+	assert.InDelta(t, 5*4+2*8, sale.CostOfGoods, 1e-9)
+
+	// Seçilen partide yeterli stok yoksa diğer partilere geçilmez
+	w = send("PATCH", saleURL, gin.H{"quantity": 11})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, []float64{5, 0, 3}, remaining())
+}
+
+func TestSaleCostOfGoods(t *testing.T) {
+	db, err := database.InitDB()
+	assert.NoError(t, err)
+
+	router := setupRouter(db)
+
+	send := func(method, url string, body gin.H) *httptest.ResponseRecorder {
+		jsonValue, _ := json.Marshal(body)
+		req, _ := http.NewRequest(method, url, bytes.NewBuffer(jsonValue))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	now := time.Now()
+	supplier := models.Supplier{Name: "Test Company"}
+	db.Create(&supplier)
+	coffee := models.Product{ProductName: fmt.Sprintf("Coffee %d", now.UnixNano()), Unit: "kg"}
+	db.Create(&coffee)
+	milk := models.Product{ProductName: fmt.Sprintf("Milk %d", now.UnixNano()), Unit: "l"}
+	db.Create(&milk)
+
+	// Kahve: 2 kg x 10, sonra 5 kg x 16; süt: 10 l x 2
+	for _, lot := range []struct {
+		product  models.Product
+		days     int
+		quantity float64
+		price    float64
+	}{{coffee, -3, 2, 10}, {coffee, -2, 5, 16}, {milk, -2, 10, 2}} {
+		w := send("POST", fmt.Sprintf("/api/v1/products/%d/lots", lot.product.ID), gin.H{
+			"supplierId": supplier.ID, "invoiceNo": "INV", "invoiceDate": now.AddDate(0, 0, lot.days),
+			"initialStock": lot.quantity, "unitPrice": lot.price,
+		})
+		assert.Equal(t, http.StatusCreated, w.Code)
+	}
+
+	// Birim maliyet gönderilmeden satış: 3 kg = 2 x 10 + 1 x 16
+	w := send("POST", "/api/v1/sales", gin.H{
+		"productId": coffee.ID, "quantity": 3, "saleDate": now.AddDate(0, 0, -1), "salePrice": 20,
+		"customerName": "Test", "customerPhone": "555",
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var sale models.Sale
+	assert.NoError(t, db.Where("product_id = ?", coffee.ID).First(&sale).Error)
+	assert.InDelta(t, 36, sale.CostOfGoods, 1e-9)
+	assert.InDelta(t, 12, sale.UnitCost, 1e-9)
+	assert.InDelta(t, 24, sale.GrossProfit, 1e-9)
+	assert.InDelta(t, 40, sale.MarginPercent, 1e-9)
+
+	// İstemcinin tahmini birim maliyeti dikkate alınmaz
+	w = send("POST", "/api/v1/sales", gin.H{
+		"productId": coffee.ID, "quantity": 1, "saleDate": now.AddDate(0, 0, -1), "salePrice": 20, "unitCost": 1,
+		"customerName": "Test", "customerPhone": "555",
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var guessed models.Sale
+	assert.NoError(t, db.Where("product_id = ?", coffee.ID).Order("id desc").First(&guessed).Error)
+	assert.InDelta(t, 16, guessed.UnitCost, 1e-9)
+	assert.InDelta(t, 16, guessed.CostOfGoods, 1e-9)
+
+	// Reçete maliyeti malzemelerin parti maliyetlerinin toplamıdır: 2 x (0.5 kg x 16 + 1 l x 2)
+	recipe := models.Recipe{
+		Name:           fmt.Sprintf("Latte %d", now.UnixNano()),
+		OutputQuantity: 1,
+		RecipeItems:    []models.RecipeItem{{ProductID: coffee.ID, Quantity: 0.5}, {ProductID: milk.ID, Quantity: 1}},
+	}
+	db.Create(&recipe)
+	w = send("POST", "/api/v1/recipe-sales", gin.H{"recipeId": recipe.ID, "quantity": 2, "saleDate": now, "salePrice": 25})
+	assert.Less(t, w.Code, 300)
+	var recipeSale models.Sale
+	assert.NoError(t, db.Where("recipe_id = ?", recipe.ID).First(&recipeSale).Error)
+	assert.InDelta(t, 20, recipeSale.CostOfGoods, 1e-9)
+	assert.InDelta(t, 10, recipeSale.UnitCost, 1e-9)
+	assert.InDelta(t, 30, recipeSale.GrossProfit, 1e-9)
+	assert.InDelta(t, 60, recipeSale.MarginPercent, 1e-9)
+
+	// Miktar düzeltmesi birim maliyeti de günceller: 1 kg daha 16'dan
+	w = send("PATCH", fmt.Sprintf("/api/v1/sales/%d", sale.ID), gin.H{"quantity": 4})
+	assert.Equal(t, http.StatusOK, w.Code)
+	var updated struct {
+		Data struct {
+			Sale models.Sale `json:"sale"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.InDelta(t, 52, updated.Data.Sale.CostOfGoods, 1e-9)
+	assert.InDelta(t, 13, updated.Data.Sale.UnitCost, 1e-9)
+	assert.InDelta(t, 35, updated.Data.Sale.MarginPercent, 1e-9)
+
+	// Siparişin kârı sipariş iskontosundan sonraki net fiyat üzerinden hesaplanır
+	w = send("POST", "/api/v1/sales-orders", gin.H{
+		"customerName":  "Test",
+		"customerPhone": "555",
+		"orderDate":     now,
+		"discount":      4,
+		"lines":         []gin.H{{"productId": milk.ID, "quantity": 2, "salePrice": 5}},
+	})
+	assert.Equal(t, http.StatusCreated, w.Code)
+	var order struct {
+		Data models.SalesOrder `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &order))
+	assert.InDelta(t, 4, order.Data.CostOfGoods, 1e-9)
+	assert.InDelta(t, 2, order.Data.GrossProfit, 1e-9)
+	assert.InDelta(t, 100.0/3, order.Data.MarginPercent, 1e-9)
 }